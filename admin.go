@@ -0,0 +1,141 @@
+package gocache
+
+import (
+	"context"
+	"gocache/auth"
+	pb "gocache/pb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// adminServer 实现Admin服务，用于运维在线节点
+type adminServer struct {
+	pb.UnimplementedAdminServer
+	server *Server
+}
+
+// WithAdmin 开启Admin服务，picker用于查询哈希环和peer，可以为nil。
+// 所有Admin方法都需要admin权限，未通过WithAuth开启认证时还需要WithInsecureAdmin，否则NewServer返回错误
+func WithAdmin(picker *ClientPicker) ServerOptions {
+	return func(server *Server) {
+		server.admin = true
+		server.picker = picker
+	}
+}
+
+// WithInsecureAdmin 允许在未开启认证时提供Admin服务，任何能连接到节点的客户端都可以清空或缩容group，
+// 只应在节点地址不对外暴露时使用
+func WithInsecureAdmin() ServerOptions {
+	return func(server *Server) {
+		server.insecureAdmin = true
+	}
+}
+
+// resolveAdminOp 将Admin服务的方法映射为admin操作，与group无关的方法使用空group，需要授予Wildcard
+func resolveAdminOp(fullMethod string, req interface{}) (string, auth.Operation, bool) {
+	switch fullMethod {
+	case pb.Admin_ListGroups_FullMethodName, pb.Admin_GroupStats_FullMethodName, pb.Admin_Flush_FullMethodName,
+		pb.Admin_Resize_FullMethodName, pb.Admin_Ring_FullMethodName, pb.Admin_Peers_FullMethodName,
+		pb.Admin_Rebalance_FullMethodName:
+	default:
+		return "", 0, false
+	}
+	r, _ := req.(interface{ GetGroup() string })
+	if r == nil {
+		return "", auth.OpAdmin, true
+	}
+	return r.GetGroup(), auth.OpAdmin, true
+}
+
+func (a *adminServer) ListGroups(ctx context.Context, in *pb.AdminRequest) (*pb.ListGroupsResponse, error) {
+	return &pb.ListGroupsResponse{Groups: ListGroups()}, nil
+}
+
+func (a *adminServer) GroupStats(ctx context.Context, in *pb.AdminRequest) (*pb.ResponseForStats, error) {
+	g, err := adminGroup(in.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	return statsToPB(g.Stats()), nil
+}
+
+func (a *adminServer) Flush(ctx context.Context, in *pb.AdminRequest) (*pb.FlushResponse, error) {
+	g, err := adminGroup(in.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	a.server.logger.Info("admin flush", "group", g.name)
+	return &pb.FlushResponse{Removed: int64(g.Flush())}, nil
+}
+
+func (a *adminServer) Resize(ctx context.Context, in *pb.ResizeRequest) (*pb.ResizeResponse, error) {
+	if in.GetMaxBytes() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "max bytes must not be negative")
+	}
+	g, err := adminGroup(in.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	old, err := g.Resize(in.GetMaxBytes())
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "resize group %s: %v", g.name, err)
+	}
+	return &pb.ResizeResponse{OldMaxBytes: old, MaxBytes: in.GetMaxBytes()}, nil
+}
+
+func (a *adminServer) Ring(ctx context.Context, in *pb.AdminRequest) (*pb.RingResponse, error) {
+	picker, err := a.requirePicker()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.RingResponse{Nodes: ringToPB(picker.Ring())}, nil
+}
+
+func (a *adminServer) Peers(ctx context.Context, in *pb.AdminRequest) (*pb.PeersResponse, error) {
+	picker, err := a.requirePicker()
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.PeersResponse{Self: picker.Self()}
+	for _, peer := range picker.Peers() {
+		resp.Peers = append(resp.Peers, &pb.PeerInfo{
+			Addr:         peer.Addr,
+			Healthy:      peer.Healthy,
+			BreakerState: peer.Breaker.String(),
+		})
+	}
+	return resp, nil
+}
+
+// Rebalance 返回本节点哈希环重新平衡的预览，不会修改哈希环
+func (a *adminServer) Rebalance(ctx context.Context, in *pb.AdminRequest) (*pb.RebalanceResponse, error) {
+	picker, err := a.requirePicker()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.RebalanceResponse{Nodes: ringToPB(picker.RebalancePlan())}, nil
+}
+
+func (a *adminServer) requirePicker() (*ClientPicker, error) {
+	if a.server.picker == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "node has no peer picker")
+	}
+	return a.server.picker, nil
+}
+
+func adminGroup(name string) (*Group, error) {
+	g := GetGroup(name)
+	if g == nil {
+		return nil, status.Errorf(codes.NotFound, "group %s not exist", name)
+	}
+	return g, nil
+}
+
+func ringToPB(nodes []RingNode) []*pb.RingNode {
+	result := make([]*pb.RingNode, 0, len(nodes))
+	for _, n := range nodes {
+		result = append(result, &pb.RingNode{Addr: n.Addr, Replicas: int32(n.Replicas), LoadRatio: n.LoadRatio})
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+package gocache
+
+import (
+	"context"
+	"gocache/auth"
+	pb "gocache/pb"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAdmin_GroupOperations(t *testing.T) {
+	g := NewGroup("admin-test", 1<<10, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		return []byte("0123456789"), true, time.Time{}
+	}))
+	defer DestroyGroup("admin-test")
+	for _, key := range []string{"a", "b", "c"} {
+		g.Get(context.Background(), key)
+	}
+
+	if _, err := NewServer("localhost:9999", WithAdmin(nil)); err == nil {
+		t.Fatal("expect error enabling admin without auth")
+	}
+	server, _ := NewServer("localhost:9999", WithAdmin(nil), WithInsecureAdmin())
+	admin := &adminServer{server: server}
+	ctx := context.Background()
+
+	groups, _ := admin.ListGroups(ctx, &pb.AdminRequest{})
+	found := false
+	for _, name := range groups.GetGroups() {
+		found = found || name == "admin-test"
+	}
+	if !found {
+		t.Fatalf("admin-test not listed in %v", groups.GetGroups())
+	}
+
+	resized, err := admin.Resize(ctx, &pb.ResizeRequest{Group: "admin-test", MaxBytes: 22})
+	if err != nil || resized.GetOldMaxBytes() != 1<<10 {
+		t.Fatalf("resize failed: %v %v", resized, err)
+	}
+	stats, _ := admin.GroupStats(ctx, &pb.AdminRequest{Group: "admin-test"})
+	if stats.GetItems() != 2 || stats.GetEvictions() != 1 {
+		t.Fatalf("expect 2 items and 1 eviction after shrinking, got %v", stats)
+	}
+
+	flushed, _ := admin.Flush(ctx, &pb.AdminRequest{Group: "admin-test"})
+	if flushed.GetRemoved() != 2 || g.Stats().Items != 0 {
+		t.Fatalf("flush failed: %v", flushed)
+	}
+
+	if _, err := admin.Flush(ctx, &pb.AdminRequest{Group: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expect NotFound, got %v", err)
+	}
+	if _, err := admin.Ring(ctx, &pb.AdminRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expect FailedPrecondition without picker, got %v", err)
+	}
+}
+
+func TestAdmin_RequiresAdminOp(t *testing.T) {
+	group, op, ok := resolveServerOp(pb.Admin_Flush_FullMethodName, &pb.AdminRequest{Group: "users"})
+	if !ok || group != "users" || op != auth.OpAdmin {
+		t.Fatalf("unexpected resolve result %s %s %v", group, op, ok)
+	}
+
+	acl := auth.NewACL()
+	acl.Grant("svc", "users", auth.OpRead|auth.OpWrite|auth.OpDelete)
+	if acl.Allowed("svc", group, op) {
+		t.Fatal("non-admin identity should not flush")
+	}
+}
@@ -0,0 +1,479 @@
+package gocache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// aofMagic AOF文件头，最后一个字节为格式版本
+var aofMagic = []byte("GCAOF\x01")
+
+// errAOFRewriting 已有重写在进行
+var errAOFRewriting = errors.New("aof rewrite already in progress")
+
+// FsyncPolicy AOF的刷盘策略
+type FsyncPolicy string
+
+const (
+	FsyncAlways   FsyncPolicy = "always"   // 每条记录写入后fsync
+	FsyncEverySec FsyncPolicy = "everysec" // 每秒fsync一次，宕机时最多丢失1秒的写入
+	FsyncNo       FsyncPolicy = "no"       // 由操作系统决定何时落盘
+)
+
+const (
+	defaultAOFRewriteMinSize    = 64 << 20
+	defaultAOFRewritePercentage = 100
+)
+
+// AOFConfig Group的AOF配置
+type AOFConfig struct {
+	// Path 日志文件路径，目录不存在时自动创建
+	Path string
+	// Fsync 刷盘策略，默认everysec
+	Fsync FsyncPolicy
+	// RewriteMinSize 触发自动重写的最小文件大小，默认64MB
+	RewriteMinSize int64
+	// RewritePercentage 文件比上次重写后增长超过该百分比时自动重写，默认100，为负数时不自动重写
+	RewritePercentage int
+}
+
+// aofOp 日志记录的操作类型
+type aofOp byte
+
+const (
+	aofSet    aofOp = iota + 1
+	aofDelete       // 删除
+	aofExpire       // 条目过期被清理
+	aofClear        // 清空整个Group
+	// aofSetSealed 写入加密的条目，重放时按aofSet处理
+	aofSetSealed
+)
+
+// WithAOF 开启AOF。写入、删除、过期和清空都会追加到日志，NewGroup时重放日志恢复缓存，
+// 日志增长到一定大小后在后台按当前缓存内容重写
+func WithAOF(cfg AOFConfig) GroupOption {
+	return func(g *Group) {
+		if cfg.Fsync == "" {
+			cfg.Fsync = FsyncEverySec
+		}
+		if cfg.RewriteMinSize <= 0 {
+			cfg.RewriteMinSize = defaultAOFRewriteMinSize
+		}
+		if cfg.RewritePercentage == 0 {
+			cfg.RewritePercentage = defaultAOFRewritePercentage
+		}
+		g.mainCache.aof = &aofLog{cfg: cfg, stop: make(chan struct{})}
+	}
+}
+
+// ParseFsyncPolicy 解析刷盘策略，空字符串返回默认的everysec
+func ParseFsyncPolicy(s string) (FsyncPolicy, error) {
+	switch p := FsyncPolicy(s); p {
+	case "":
+		return FsyncEverySec, nil
+	case FsyncAlways, FsyncEverySec, FsyncNo:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown fsync policy %q", s)
+	}
+}
+
+// aofLog 一个Group的追加日志
+type aofLog struct {
+	cfg    AOFConfig
+	logger *slog.Logger
+	stop   chan struct{}
+
+	mu         sync.Mutex
+	f          *os.File // 重放完成前和关闭后为nil，此时不记录
+	size       int64
+	baseSize   int64 // 上次重写后的文件大小
+	dirty      bool  // 有尚未fsync的记录
+	rewriting  bool
+	rewriteBuf []byte // 重写期间追加的记录，重写完成后写入新文件末尾
+	buf        []byte
+}
+
+// append 追加一条记录，写入失败只记录日志，不影响缓存本身。
+// 部分写入的记录被截断，避免重放时在其之后的记录被当作损坏；无法截断时停止记录
+func (l *aofLog) append(op aofOp, e snapshotEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return
+	}
+	l.buf = appendAOFRecord(l.buf[:0], op, e)
+	if _, err := l.f.Write(l.buf); err != nil {
+		l.logger.Warn("append aof failed", "path", l.cfg.Path, "error", err)
+		if err := l.rollback(); err != nil {
+			l.logger.Error("truncate aof failed, aof disabled", "path", l.cfg.Path, "error", err)
+			l.f.Close()
+			l.f = nil
+		}
+		return
+	}
+	l.size += int64(len(l.buf))
+	if l.rewriting {
+		l.rewriteBuf = append(l.rewriteBuf, l.buf...)
+	}
+	if l.cfg.Fsync != FsyncAlways {
+		l.dirty = true
+		return
+	}
+	if err := l.f.Sync(); err != nil {
+		l.logger.Warn("fsync aof failed", "path", l.cfg.Path, "error", err)
+	}
+}
+
+// rollback 截断最后一条完整记录之后的数据，调用方需持有mu
+func (l *aofLog) rollback() error {
+	if err := l.f.Truncate(l.size); err != nil {
+		return err
+	}
+	_, err := l.f.Seek(l.size, io.SeekStart)
+	return err
+}
+
+// sync 将尚未落盘的记录fsync到磁盘
+func (l *aofLog) sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil || !l.dirty {
+		return nil
+	}
+	l.dirty = false
+	return l.f.Sync()
+}
+
+// open 打开日志用于追加，end之后不完整的记录被截断
+func (l *aofLog) open(end int64) error {
+	if err := os.MkdirAll(filepath.Dir(l.cfg.Path), 0o755); err != nil {
+		return fmt.Errorf("create aof dir: %v", err)
+	}
+	f, err := os.OpenFile(l.cfg.Path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("open aof: %v", err)
+	}
+	if end == 0 {
+		_, err = f.Write(aofMagic)
+		end = int64(len(aofMagic))
+	}
+	if err == nil {
+		err = f.Truncate(end)
+	}
+	if err == nil {
+		_, err = f.Seek(end, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("open aof: %v", err)
+	}
+
+	l.mu.Lock()
+	l.f, l.size, l.baseSize = f, end, end
+	l.mu.Unlock()
+	return nil
+}
+
+// close fsync并关闭日志，之后的记录被丢弃
+func (l *aofLog) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return
+	}
+	if err := l.f.Sync(); err != nil {
+		l.logger.Warn("fsync aof failed", "path", l.cfg.Path, "error", err)
+	}
+	l.f.Close()
+	l.f = nil
+}
+
+// needRewrite 日志大小是否达到自动重写的条件
+func (l *aofLog) needRewrite() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil || l.rewriting || l.cfg.RewritePercentage < 0 || l.size < l.cfg.RewriteMinSize {
+		return false
+	}
+	return l.size-l.baseSize >= l.baseSize*int64(l.cfg.RewritePercentage)/100
+}
+
+func (l *aofLog) startRewrite() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return fmt.Errorf("aof is closed")
+	}
+	if l.rewriting {
+		return errAOFRewriting
+	}
+	l.rewriting = true
+	l.rewriteBuf = nil
+	return nil
+}
+
+func (l *aofLog) abortRewrite() {
+	l.mu.Lock()
+	l.rewriting, l.rewriteBuf = false, nil
+	l.mu.Unlock()
+}
+
+// finishRewrite 将重写期间的记录追加到新文件，然后用新文件替换旧日志
+func (l *aofLog) finishRewrite(f *os.File) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	defer func() { l.rewriting, l.rewriteBuf = false, nil }()
+	if l.f == nil {
+		return 0, fmt.Errorf("aof is closed")
+	}
+	if _, err := f.Write(l.rewriteBuf); err != nil {
+		return 0, err
+	}
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Rename(f.Name(), l.cfg.Path); err != nil {
+		return 0, err
+	}
+	l.f.Close()
+	l.f, l.size, l.baseSize, l.dirty = f, size, size, false
+	return size, nil
+}
+
+// startAOF 重放已有的日志并开始记录，由NewGroup调用。日志无法读取时不开启AOF，避免覆盖原文件
+func (g *Group) startAOF() {
+	l := g.mainCache.aof
+	l.logger = g.logger
+	start := time.Now()
+	records, skipped, end, err := g.mainCache.replay(l.cfg.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		g.logger.Error("replay aof failed, aof disabled", "path", l.cfg.Path, "error", err)
+		return
+	}
+	if info, statErr := os.Stat(l.cfg.Path); statErr == nil && info.Size() > end && end > 0 {
+		g.logger.Warn("aof has truncated tail, discarded", "path", l.cfg.Path, "offset", end, "size", info.Size())
+	}
+	if err := l.open(end); err != nil {
+		g.logger.Error("open aof failed, aof disabled", "error", err)
+		return
+	}
+	if skipped > 0 {
+		g.logger.Warn("aof entries could not be stored, skipped", "path", l.cfg.Path, "skipped", skipped)
+	}
+	g.logger.Info("aof replayed", "path", l.cfg.Path, "records", records, "items", g.mainCache.stats().Entries, "elapsed", time.Since(start))
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if l.cfg.Fsync == FsyncEverySec {
+					if err := l.sync(); err != nil {
+						g.logger.Warn("fsync aof failed", "path", l.cfg.Path, "error", err)
+					}
+				}
+				if l.needRewrite() {
+					go func() {
+						if err := g.RewriteAOF(); err != nil && !errors.Is(err, errAOFRewriting) {
+							g.logger.Warn("rewrite aof failed", "path", l.cfg.Path, "error", err)
+						}
+					}()
+				}
+			case <-l.stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopAOF 停止后台任务并关闭日志
+func (g *Group) stopAOF() {
+	close(g.mainCache.aof.stop)
+	g.mainCache.aof.close()
+}
+
+// RewriteAOF 按当前缓存内容重写日志，去掉已被覆盖、删除和过期的记录。
+// 重写期间的写入仍然追加到旧日志，完成后同样追加到新日志末尾
+func (g *Group) RewriteAOF() error {
+	l := g.mainCache.aof
+	if l == nil {
+		return fmt.Errorf("aof is not enabled for group %s", g.name)
+	}
+	start := time.Now()
+	entries, err := g.mainCache.beginRewrite()
+	if err != nil {
+		return err
+	}
+
+	f, err := writeAOFRewrite(l.cfg.Path, entries)
+	if err != nil {
+		l.abortRewrite()
+		return err
+	}
+	size, err := l.finishRewrite(f)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("finish aof rewrite: %v", err)
+	}
+	g.logger.Info("aof rewritten", "path", l.cfg.Path, "items", len(entries), "bytes", size, "elapsed", time.Since(start))
+	return nil
+}
+
+// syncAOFs 将所有Group尚未落盘的AOF记录fsync到磁盘，由Server.Stop调用
+func syncAOFs() {
+	for _, name := range ListGroups() {
+		g := GetGroup(name)
+		if g == nil || g.mainCache.aof == nil {
+			continue
+		}
+		if err := g.mainCache.aof.sync(); err != nil {
+			g.logger.Warn("fsync aof failed", "path", g.mainCache.aof.cfg.Path, "error", err)
+		}
+	}
+}
+
+// writeAOFRewrite 将条目写入与日志同目录的临时文件，返回打开的文件供追加重写期间的记录
+func writeAOFRewrite(path string, entries []snapshotEntry) (*os.File, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".rewrite-*")
+	if err != nil {
+		return nil, fmt.Errorf("create aof rewrite: %v", err)
+	}
+	bw := bufio.NewWriter(f)
+	bw.Write(aofMagic)
+	var buf []byte
+	for _, e := range entries {
+		buf = appendAOFRecord(buf[:0], aofSet, e)
+		bw.Write(buf)
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("write aof rewrite: %v", err)
+	}
+	return f, nil
+}
+
+// appendAOFRecord 记录格式：4字节长度、4字节CRC32、操作类型和操作数据。
+// set的数据为完整条目，delete和expire为key，clear没有数据
+func appendAOFRecord(b []byte, op aofOp, e snapshotEntry) []byte {
+	start := len(b)
+	if op == aofSet && e.value.x {
+		op = aofSetSealed
+	}
+	b = append(b, make([]byte, 8)...)
+	b = append(b, byte(op))
+	switch op {
+	case aofSet, aofSetSealed:
+		b = appendEntry(b, e)
+	case aofDelete, aofExpire:
+		b = appendBytes(b, []byte(e.key))
+	}
+	payload := b[start+8:]
+	binary.BigEndian.PutUint32(b[start:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(b[start+4:], crc32.ChecksumIEEE(payload))
+	return b
+}
+
+// replayAOF 依次读取日志中的记录并调用apply，返回记录数和最后一条完整记录之后的偏移。
+// 文件末尾不完整或校验失败的记录视为写入时中断，停止读取；中间的记录损坏时返回错误，避免截断之后的有效记录
+func replayAOF(path string, apply func(op aofOp, e snapshotEntry)) (int, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	if info.Size() == 0 {
+		return 0, 0, nil
+	}
+
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(aofMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, aofMagic) {
+		return 0, 0, fmt.Errorf("invalid aof header")
+	}
+	end := int64(len(aofMagic))
+	records := 0
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return records, end, nil
+		}
+		n := int64(binary.BigEndian.Uint32(header[:4]))
+		if n == 0 {
+			// 文件系统在宕机后可能在末尾留下补零的数据
+			if rest, err := io.ReadAll(r); err != nil || len(bytes.Trim(rest, "\x00")) != 0 || header != [8]byte{} {
+				return records, end, fmt.Errorf("aof corrupted at offset %d", end)
+			}
+			return records, end, nil
+		}
+		if end+8+n > info.Size() {
+			return records, end, nil
+		}
+		tail := end+8+n == info.Size()
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return records, end, err
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+			if tail {
+				return records, end, nil
+			}
+			return records, end, fmt.Errorf("aof corrupted at offset %d", end)
+		}
+		op, e, err := decodeAOFRecord(payload)
+		if err != nil {
+			if tail {
+				return records, end, nil
+			}
+			return records, end, fmt.Errorf("aof corrupted at offset %d: %v", end, err)
+		}
+		apply(op, e)
+		records++
+		end += 8 + n
+	}
+}
+
+func decodeAOFRecord(payload []byte) (aofOp, snapshotEntry, error) {
+	op := aofOp(payload[0])
+	r := bytes.NewReader(payload[1:])
+	var e snapshotEntry
+	var err error
+	switch op {
+	case aofSet, aofSetSealed:
+		e, err = readEntry(r)
+		e.value.x = op == aofSetSealed
+		op = aofSet
+	case aofDelete, aofExpire:
+		var key []byte
+		key, err = readBytes(r)
+		e.key = string(key)
+	case aofClear:
+	default:
+		return 0, e, fmt.Errorf("unknown aof op %d", op)
+	}
+	if err == nil && r.Len() != 0 {
+		err = fmt.Errorf("unexpected trailing data")
+	}
+	return op, e, err
+}
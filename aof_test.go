@@ -0,0 +1,243 @@
+package gocache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"gocache/store"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newAOFGroup 创建开启AOF的Group，重复调用相当于重启后重放同一个日志
+func newAOFGroup(t *testing.T, name, path string) *Group {
+	t.Helper()
+	DestroyGroup(name)
+	g := NewGroup(name, 1<<20, missGetter(), WithAOF(AOFConfig{Path: path, Fsync: FsyncAlways}))
+	t.Cleanup(func() { DestroyGroup(name) })
+	return g
+}
+
+func TestAOF_Replay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aof", "features.aof")
+	ctx := context.Background()
+	g := newAOFGroup(t, "aof-replay", path)
+
+	g.Set(ctx, "k1", []byte("v1"))
+	g.Write(ctx, "k2", []byte("v2"), WriteOptions{TTL: time.Hour, Flags: 3, ContentType: "text/plain"})
+	g.Set(ctx, "k3", []byte("v3"))
+	g.Delete(ctx, "k3")
+	g.Set(ctx, "k1", []byte("v1-new"))
+	g.Write(ctx, "short", []byte("x"), WriteOptions{TTL: 20 * time.Millisecond})
+	time.Sleep(50 * time.Millisecond)
+	g.Set(ctx, "k4", []byte("v4")) // 写入时清理已过期的short
+
+	var ops []aofOp
+	replayAOF(path, func(op aofOp, e snapshotEntry) { ops = append(ops, op) })
+	if len(ops) != 8 || ops[3] != aofDelete || ops[6] != aofExpire {
+		t.Fatalf("unexpected ops %v", ops)
+	}
+
+	g = newAOFGroup(t, "aof-replay", path)
+	for key, want := range map[string]string{"k1": "v1-new", "k2": "v2", "k4": "v4"} {
+		if v, err := g.Get(ctx, key); err != nil || v.String() != want {
+			t.Fatalf("%s: got %q %v, want %q", key, v.String(), err, want)
+		}
+	}
+	if v, _ := g.Get(ctx, "k2"); v.Flags() != 3 || v.ContentType() != "text/plain" || v.TTL() <= 59*time.Minute {
+		t.Fatalf("unexpected k2 metadata %+v", v)
+	}
+	for _, key := range []string{"k3", "short"} {
+		if _, err := g.Get(ctx, key); err != ErrNotFound {
+			t.Fatalf("%s: expect not found, got %v", key, err)
+		}
+	}
+
+	g.Flush()
+	g.Set(ctx, "after", []byte("flush"))
+	g = newAOFGroup(t, "aof-replay", path)
+	if items := g.Stats().Items; items != 1 {
+		t.Fatalf("expect only the key written after flush, got %d items", items)
+	}
+}
+
+func TestAOF_Rewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rewrite.aof")
+	ctx := context.Background()
+	g := newAOFGroup(t, "aof-rewrite", path)
+	for i := 0; i < 200; i++ {
+		g.Set(ctx, fmt.Sprintf("k%d", i%10), []byte(fmt.Sprintf("v%d", i)))
+	}
+	before, _ := os.Stat(path)
+
+	// 重写期间的写入不能丢失
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			g.Set(ctx, fmt.Sprintf("new%d", i), []byte("x"))
+		}
+	}()
+	if err := g.RewriteAOF(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size() {
+		t.Fatalf("expect smaller log after rewrite, %d -> %d", before.Size(), after.Size())
+	}
+	g.Set(ctx, "k0", []byte("final"))
+
+	g = newAOFGroup(t, "aof-rewrite", path)
+	if items := g.Stats().Items; items != 110 {
+		t.Fatalf("expect 110 items after replay, got %d", items)
+	}
+	if v, err := g.Get(ctx, "k0"); err != nil || v.String() != "final" {
+		t.Fatalf("unexpected k0 %q %v", v.String(), err)
+	}
+	if v, err := g.Get(ctx, "k9"); err != nil || v.String() != "v199" {
+		t.Fatalf("unexpected k9 %q %v", v.String(), err)
+	}
+}
+
+func TestAOF_TruncatedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tail.aof")
+	ctx := context.Background()
+	g := newAOFGroup(t, "aof-tail", path)
+	g.Set(ctx, "k1", []byte("v1"))
+	g.Set(ctx, "k2", []byte("v2"))
+	DestroyGroup("aof-tail")
+
+	// 模拟最后一条记录写到一半时宕机
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, data[:len(data)-3], 0o644)
+
+	g = newAOFGroup(t, "aof-tail", path)
+	if _, err := g.Get(ctx, "k2"); err != ErrNotFound {
+		t.Fatalf("expect truncated record ignored, got %v", err)
+	}
+	g.Set(ctx, "k3", []byte("v3"))
+	g = newAOFGroup(t, "aof-tail", path)
+	for _, key := range []string{"k1", "k3"} {
+		if _, err := g.Get(ctx, key); err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+	}
+
+	os.WriteFile(path, []byte("not an aof"), 0o644)
+	g = newAOFGroup(t, "aof-tail", path)
+	g.Set(ctx, "k", []byte("v"))
+	if data, _ := os.ReadFile(path); string(data) != "not an aof" {
+		t.Fatal("expect invalid aof left untouched")
+	}
+}
+
+func TestParseFsyncPolicy(t *testing.T) {
+	for s, want := range map[string]FsyncPolicy{"": FsyncEverySec, "always": FsyncAlways, "no": FsyncNo} {
+		if got, err := ParseFsyncPolicy(s); err != nil || got != want {
+			t.Errorf("ParseFsyncPolicy(%q) = %q %v, want %q", s, got, err, want)
+		}
+	}
+	if _, err := ParseFsyncPolicy("sometimes"); err == nil {
+		t.Error("expect error for unknown policy")
+	}
+}
+
+func TestAOF_StoreError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store-error.aof")
+	ctx := context.Background()
+	g := NewGroup("aof-store-error", 1<<10, missGetter(), WithCacheType(store.Arena), WithAOF(AOFConfig{Path: path, Fsync: FsyncAlways}))
+	t.Cleanup(func() { DestroyGroup("aof-store-error") })
+
+	g.Set(ctx, "k", []byte("v"))
+	if err := g.Set(ctx, "k", make([]byte, 2<<10)); err == nil {
+		t.Fatal("expect error for entry larger than the arena")
+	}
+	// 写入失败时不记录写入，只记录删除旧值
+	var ops []aofOp
+	replayAOF(path, func(op aofOp, e snapshotEntry) { ops = append(ops, op) })
+	if len(ops) != 2 || ops[0] != aofSet || ops[1] != aofDelete {
+		t.Fatalf("unexpected ops %v", ops)
+	}
+}
+
+func TestAOF_CorruptedMiddle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "middle.aof")
+	ctx := context.Background()
+	g := newAOFGroup(t, "aof-middle", path)
+	g.Set(ctx, "k1", []byte("v1"))
+	g.Set(ctx, "k2", []byte("v2"))
+	g.Set(ctx, "k3", []byte("v3"))
+	DestroyGroup("aof-middle")
+
+	// 损坏中间的记录，之后的有效记录不能被截断
+	data, _ := os.ReadFile(path)
+	var offsets []int64
+	end := int64(len(aofMagic))
+	for i := 0; i < 3; i++ {
+		offsets = append(offsets, end)
+		end += 8 + int64(binary.BigEndian.Uint32(data[end:]))
+	}
+	data[offsets[1]+10] ^= 0xff
+	os.WriteFile(path, data, 0o644)
+	if _, _, err := replayAOF(path, func(op aofOp, e snapshotEntry) {}); err == nil {
+		t.Fatal("expect error for corrupted record in the middle")
+	}
+
+	g = newAOFGroup(t, "aof-middle", path)
+	g.Set(ctx, "k4", []byte("v4"))
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, data) {
+		t.Fatal("expect corrupted aof left untouched")
+	}
+
+	// 末尾补零视为写入中断
+	os.WriteFile(path, append(data[:offsets[1]], make([]byte, 32)...), 0o644)
+	if records, end, err := replayAOF(path, func(op aofOp, e snapshotEntry) {}); err != nil || records != 1 || end != offsets[1] {
+		t.Fatalf("expect zero tail ignored, got %d %d %v", records, end, err)
+	}
+}
+
+func TestAOF_AppendRollback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rollback.aof")
+	g := newAOFGroup(t, "aof-rollback", path)
+	g.Set(context.Background(), "k1", []byte("v1"))
+	l := g.mainCache.aof
+
+	// 模拟写入一半失败后回滚
+	l.mu.Lock()
+	l.f.Write([]byte("partial"))
+	err := l.rollback()
+	l.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Set(context.Background(), "k2", []byte("v2"))
+	if records, _, err := replayAOF(path, func(op aofOp, e snapshotEntry) {}); err != nil || records != 2 {
+		t.Fatalf("expect 2 records, got %d %v", records, err)
+	}
+
+	// 无法写入也无法截断时停止记录
+	ro, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.mu.Lock()
+	l.f.Close()
+	l.f = ro
+	l.mu.Unlock()
+	g.Set(context.Background(), "k3", []byte("v3"))
+	l.mu.Lock()
+	disabled := l.f == nil
+	l.mu.Unlock()
+	if !disabled {
+		t.Fatal("expect aof disabled after failed rollback")
+	}
+	if records, _, err := replayAOF(path, func(op aofOp, e snapshotEntry) {}); err != nil || records != 2 {
+		t.Fatalf("expect 2 records, got %d %v", records, err)
+	}
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Operation 操作类型，可以按位组合
+type Operation uint8
+
+const (
+	OpRead Operation = 1 << iota
+	OpWrite
+	OpDelete
+	OpAdmin
+
+	OpAll = OpRead | OpWrite | OpDelete | OpAdmin
+)
+
+// Wildcard 匹配任意身份或任意group
+const Wildcard = "*"
+
+var opNames = []struct {
+	op   Operation
+	name string
+}{
+	{OpRead, "read"},
+	{OpWrite, "write"},
+	{OpDelete, "delete"},
+	{OpAdmin, "admin"},
+}
+
+func (op Operation) String() string {
+	if op == OpAll {
+		return "all"
+	}
+	var names []string
+	for _, n := range opNames {
+		if op&n.op != 0 {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// ParseOperation 解析 read/write/delete/admin/all，多个操作用逗号或|分隔
+func ParseOperation(s string) (Operation, error) {
+	var op Operation
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '|' }) {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "all" {
+			op |= OpAll
+			continue
+		}
+		found := false
+		for _, n := range opNames {
+			if n.name == part {
+				op |= n.op
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown operation %q", part)
+		}
+	}
+	return op, nil
+}
+
+// ACL 身份到group和操作的授权表，并发安全
+type ACL struct {
+	mu    sync.RWMutex
+	rules map[string]map[string]Operation // identity -> group -> ops
+}
+
+// NewACL 创建空的授权表，默认拒绝所有请求
+func NewACL() *ACL {
+	return &ACL{
+		rules: make(map[string]map[string]Operation),
+	}
+}
+
+// Grant 授予identity在group上的操作权限，identity和group都可以是Wildcard
+func (a *ACL) Grant(identity, group string, ops Operation) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	groups, ok := a.rules[identity]
+	if !ok {
+		groups = make(map[string]Operation)
+		a.rules[identity] = groups
+	}
+	groups[group] |= ops
+}
+
+// Revoke 收回identity在group上的操作权限
+func (a *ACL) Revoke(identity, group string, ops Operation) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if groups, ok := a.rules[identity]; ok {
+		groups[group] &^= ops
+		if groups[group] == 0 {
+			delete(groups, group)
+		}
+	}
+}
+
+// Allowed 判断identity是否可以在group上执行op
+func (a *ACL) Allowed(identity, group string, op Operation) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, id := range []string{identity, Wildcard} {
+		groups := a.rules[id]
+		if groups == nil {
+			continue
+		}
+		if groups[group]&op == op || groups[Wildcard]&op == op {
+			return true
+		}
+	}
+	return false
+}
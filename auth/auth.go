@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gocache/tlsutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// 请求元数据中使用的key
+const (
+	HeaderAuthorization = "authorization"
+	HeaderKeyID         = "x-gocache-key-id"
+	HeaderTimestamp     = "x-gocache-timestamp"
+	HeaderSignature     = "x-gocache-signature"
+)
+
+// ErrNoCredentials 请求中没有当前认证方式需要的凭证，认证链会继续尝试下一种方式
+var ErrNoCredentials = errors.New("no credentials")
+
+// Identity 认证后的调用方身份
+type Identity struct {
+	Name   string // 身份名，用于ACL匹配
+	Method string // 认证方式
+}
+
+type identityKey struct{}
+
+// NewContext 将身份放入ctx
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext 从ctx中取出身份
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok
+}
+
+// Authenticator 认证器接口
+type Authenticator interface {
+	// Authenticate 认证一次调用，请求中没有对应凭证时返回ErrNoCredentials
+	Authenticate(ctx context.Context, fullMethod string, req interface{}) (*Identity, error)
+}
+
+// Chain 依次尝试多个认证器，返回第一个认证成功的身份
+func Chain(authenticators ...Authenticator) Authenticator {
+	return chain(authenticators)
+}
+
+type chain []Authenticator
+
+func (c chain) Authenticate(ctx context.Context, fullMethod string, req interface{}) (*Identity, error) {
+	for _, a := range c {
+		id, err := a.Authenticate(ctx, fullMethod, req)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return id, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// TokenAuthenticator 静态bearer token认证，token到身份名的映射
+type TokenAuthenticator map[string]string
+
+func (t TokenAuthenticator) Authenticate(ctx context.Context, _ string, _ interface{}) (*Identity, error) {
+	values := metadata.ValueFromIncomingContext(ctx, HeaderAuthorization)
+	if len(values) == 0 {
+		return nil, ErrNoCredentials
+	}
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	for known, name := range t {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+			return &Identity{Name: name, Method: "token"}, nil
+		}
+	}
+	return nil, errors.New("invalid token")
+}
+
+// TLSAuthenticator 使用mTLS客户端证书中的身份(CN、DNS SAN、URI SAN中的第一个)
+type TLSAuthenticator struct{}
+
+func (TLSAuthenticator) Authenticate(ctx context.Context, _ string, _ interface{}) (*Identity, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.PeerCertificates) == 0 {
+		return nil, ErrNoCredentials
+	}
+	ids := tlsutil.Identities(info.State.PeerCertificates[0])
+	if len(ids) == 0 {
+		return nil, ErrNoCredentials
+	}
+	return &Identity{Name: ids[0], Method: "mtls"}, nil
+}
+
+// HMACAuthenticator 校验HMAC-SHA256签名的请求，Keys为key id到密钥的映射
+type HMACAuthenticator struct {
+	Keys    map[string][]byte
+	MaxSkew time.Duration // 允许的时间偏差，默认5分钟
+}
+
+func (h *HMACAuthenticator) Authenticate(ctx context.Context, fullMethod string, req interface{}) (*Identity, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	keyID, ts, sig := first(md, HeaderKeyID), first(md, HeaderTimestamp), first(md, HeaderSignature)
+	if keyID == "" || sig == "" {
+		return nil, ErrNoCredentials
+	}
+	secret, ok := h.Keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %s", keyID)
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp %q", ts)
+	}
+	skew := h.MaxSkew
+	if skew <= 0 {
+		skew = 5 * time.Minute
+	}
+	if d := time.Since(time.Unix(unix, 0)); d > skew || d < -skew {
+		return nil, errors.New("request timestamp out of range")
+	}
+
+	expected, err := Sign(secret, fullMethod, ts, req)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return nil, errors.New("invalid signature")
+	}
+	return &Identity{Name: keyID, Method: "hmac"}, nil
+}
+
+// Sign 计算请求签名：HMAC-SHA256(secret, method \n timestamp \n sha256(request))
+func Sign(secret []byte, fullMethod, timestamp string, req interface{}) (string, error) {
+	var body []byte
+	if msg, ok := req.(proto.Message); ok {
+		var err error
+		body, err = proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+		if err != nil {
+			return "", fmt.Errorf("marshal request: %v", err)
+		}
+	}
+	digest := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(fullMethod))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(hex.EncodeToString(digest[:])))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Resolver 将一次调用解析为需要的group和操作，ok为false表示该方法不需要鉴权
+type Resolver func(fullMethod string, req interface{}) (group string, op Operation, ok bool)
+
+// UnaryServerInterceptor 认证调用方并按ACL授权，认证失败返回Unauthenticated，无权限返回PermissionDenied
+func UnaryServerInterceptor(authn Authenticator, acl *ACL, resolve Resolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		group, op, ok := resolve(info.FullMethod, req)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		id, err := authn.Authenticate(ctx, info.FullMethod, req)
+		if err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "authentication failed: %v", err)
+		}
+		if !acl.Allowed(id.Name, group, op) {
+			return nil, status.Errorf(codes.PermissionDenied, "%s is not allowed to %s group %q", id.Name, op, group)
+		}
+		return handler(NewContext(ctx, id), req)
+	}
+}
+
+// TokenCredentials 客户端携带bearer token的PerRPCCredentials
+type TokenCredentials struct {
+	Token string
+	// AllowInsecure 为true时允许在明文连接上发送token
+	AllowInsecure bool
+}
+
+func (t TokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{HeaderAuthorization: "Bearer " + t.Token}, nil
+}
+
+func (t TokenCredentials) RequireTransportSecurity() bool {
+	return !t.AllowInsecure
+}
+
+// HMACClientInterceptor 客户端为每个请求签名
+func HMACClientInterceptor(keyID string, secret []byte) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		sig, err := Sign(secret, method, ts, req)
+		if err != nil {
+			return err
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, HeaderKeyID, keyID, HeaderTimestamp, ts, HeaderSignature, sig)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	pb "gocache/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const getMethod = "/proto.GoCache/Get"
+
+func resolveTest(fullMethod string, req interface{}) (string, Operation, bool) {
+	if fullMethod != getMethod {
+		return "", 0, false
+	}
+	return req.(*pb.Request).GetGroup(), OpRead, true
+}
+
+func TestACL_Allowed(t *testing.T) {
+	acl := NewACL()
+	acl.Grant("svc-a", "users", OpRead|OpWrite)
+	acl.Grant("ops", Wildcard, OpAll)
+	acl.Grant(Wildcard, "public", OpRead)
+
+	cases := []struct {
+		identity, group string
+		op              Operation
+		want            bool
+	}{
+		{"svc-a", "users", OpRead, true},
+		{"svc-a", "users", OpDelete, false},
+		{"svc-a", "orders", OpRead, false},
+		{"ops", "orders", OpAdmin, true},
+		{"anyone", "public", OpRead, true},
+		{"anyone", "public", OpWrite, false},
+	}
+	for _, c := range cases {
+		if got := acl.Allowed(c.identity, c.group, c.op); got != c.want {
+			t.Errorf("Allowed(%s, %s, %s) = %v, want %v", c.identity, c.group, c.op, got, c.want)
+		}
+	}
+
+	acl.Revoke("svc-a", "users", OpWrite)
+	if acl.Allowed("svc-a", "users", OpWrite) {
+		t.Error("write should be revoked")
+	}
+}
+
+func TestParseOperation(t *testing.T) {
+	op, err := ParseOperation("read, delete")
+	if err != nil || op != OpRead|OpDelete {
+		t.Fatalf("expect read|delete, got %s %v", op, err)
+	}
+	if op, _ := ParseOperation("all"); op != OpAll {
+		t.Fatalf("expect all, got %s", op)
+	}
+	if _, err := ParseOperation("exec"); err == nil {
+		t.Fatal("expect error for unknown operation")
+	}
+}
+
+func TestInterceptor(t *testing.T) {
+	acl := NewACL()
+	acl.Grant("svc-a", "users", OpRead)
+	secret := []byte("secret")
+	authn := Chain(TokenAuthenticator{"t-a": "svc-a"}, &HMACAuthenticator{Keys: map[string][]byte{"svc-a": secret}})
+	interceptor := UnaryServerInterceptor(authn, acl, resolveTest)
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		id, _ := FromContext(ctx)
+		return id, nil
+	}
+	call := func(md metadata.MD, group string) (*Identity, error) {
+		ctx := metadata.NewIncomingContext(context.Background(), md)
+		resp, err := interceptor(ctx, &pb.Request{Group: group, Key: "k"}, &grpc.UnaryServerInfo{FullMethod: getMethod}, handler)
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*Identity), nil
+	}
+
+	if id, err := call(metadata.Pairs(HeaderAuthorization, "Bearer t-a"), "users"); err != nil || id.Name != "svc-a" {
+		t.Fatalf("token auth failed: %v", err)
+	}
+	if _, err := call(metadata.Pairs(HeaderAuthorization, "Bearer t-a"), "orders"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expect PermissionDenied, got %v", err)
+	}
+	if _, err := call(metadata.Pairs(HeaderAuthorization, "Bearer bad"), "users"); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expect Unauthenticated, got %v", err)
+	}
+	if _, err := call(metadata.MD{}, "users"); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expect Unauthenticated without credentials, got %v", err)
+	}
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	sig, _ := Sign(secret, getMethod, ts, &pb.Request{Group: "users", Key: "k"})
+	md := metadata.Pairs(HeaderKeyID, "svc-a", HeaderTimestamp, ts, HeaderSignature, sig)
+	if id, err := call(md, "users"); err != nil || id.Method != "hmac" {
+		t.Fatalf("hmac auth failed: %v", err)
+	}
+	// 签名绑定请求内容，篡改group后签名失效
+	if _, err := call(md, "orders"); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expect Unauthenticated for tampered request, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrOpen 熔断器处于打开状态时返回
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State 熔断器状态
+type State int
+
+const (
+	StateClosed   State = iota // 正常放行请求
+	StateOpen                  // 熔断中，拒绝所有请求
+	StateHalfOpen              // 试探中，只放行少量请求
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// Config 熔断器配置
+type Config struct {
+	FailureThreshold int           // 连续失败多少次后打开
+	SuccessThreshold int           // 半开状态下连续成功多少次后关闭
+	OpenTimeout      time.Duration // 打开多久后进入半开状态
+	HalfOpenMaxCalls int           // 半开状态下允许同时进行的试探请求数
+}
+
+// DefaultConfig 默认配置
+var DefaultConfig = &Config{
+	FailureThreshold: 5,
+	SuccessThreshold: 2,
+	OpenTimeout:      5 * time.Second,
+	HalfOpenMaxCalls: 1,
+}
+
+// Breaker 熔断器，并发安全
+type Breaker struct {
+	mu        sync.Mutex
+	config    *Config
+	state     State
+	failures  int       // 关闭状态下的连续失败次数
+	successes int       // 半开状态下的连续成功次数
+	inflight  int       // 半开状态下正在进行的请求数
+	openedAt  time.Time // 最近一次打开的时间
+	now       func() time.Time
+}
+
+// New 创建熔断器，config中<=0的字段使用DefaultConfig的值
+func New(config *Config) *Breaker {
+	cfg := *DefaultConfig
+	if config != nil {
+		cfg = *config
+		if cfg.FailureThreshold <= 0 {
+			cfg.FailureThreshold = DefaultConfig.FailureThreshold
+		}
+		if cfg.SuccessThreshold <= 0 {
+			cfg.SuccessThreshold = DefaultConfig.SuccessThreshold
+		}
+		if cfg.OpenTimeout <= 0 {
+			cfg.OpenTimeout = DefaultConfig.OpenTimeout
+		}
+		if cfg.HalfOpenMaxCalls <= 0 {
+			cfg.HalfOpenMaxCalls = DefaultConfig.HalfOpenMaxCalls
+		}
+	}
+	return &Breaker{
+		config: &cfg,
+		now:    time.Now,
+	}
+}
+
+// Allow 判断是否放行一次请求，放行后必须调用 Success 或 Failure 上报结果
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		if b.inflight >= b.config.HalfOpenMaxCalls {
+			return false
+		}
+		b.inflight++
+	}
+	return true
+}
+
+// Success 上报一次成功
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case StateClosed:
+		b.failures = 0
+	case StateHalfOpen:
+		b.release()
+		b.successes++
+		if b.successes >= b.config.SuccessThreshold {
+			b.setState(StateClosed)
+		}
+	}
+}
+
+// Failure 上报一次失败
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case StateClosed:
+		b.failures++
+		if b.failures >= b.config.FailureThreshold {
+			b.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		b.release()
+		b.setState(StateOpen)
+	}
+}
+
+// State 返回当前状态
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentState()
+}
+
+// currentState 打开超时后惰性切换到半开状态，调用方需持有锁
+func (b *Breaker) currentState() State {
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.config.OpenTimeout {
+		b.setState(StateHalfOpen)
+	}
+	return b.state
+}
+
+// setState 切换状态并重置计数，调用方需持有锁
+func (b *Breaker) setState(state State) {
+	b.state = state
+	b.failures = 0
+	b.successes = 0
+	b.inflight = 0
+	if state == StateOpen {
+		b.openedAt = b.now()
+	}
+}
+
+// release 释放一个半开状态的试探名额
+func (b *Breaker) release() {
+	if b.inflight > 0 {
+		b.inflight--
+	}
+}
@@ -0,0 +1,103 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestBreaker() (*Breaker, *time.Time) {
+	now := time.Now()
+	b := New(&Config{
+		FailureThreshold: 3,
+		SuccessThreshold: 2,
+		OpenTimeout:      time.Second,
+		HalfOpenMaxCalls: 1,
+	})
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestBreaker_OpenAfterFailures(t *testing.T) {
+	b, _ := newTestBreaker()
+	for i := 0; i < 2; i++ {
+		b.Failure()
+	}
+	b.Success()
+	for i := 0; i < 2; i++ {
+		b.Failure()
+	}
+	if b.State() != StateClosed {
+		t.Fatalf("success should reset failures, got %s", b.State())
+	}
+	b.Failure()
+	if b.State() != StateOpen {
+		t.Fatalf("expect open, got %s", b.State())
+	}
+	if b.Allow() {
+		t.Fatal("open breaker should reject requests")
+	}
+}
+
+func TestBreaker_HalfOpen(t *testing.T) {
+	b, now := newTestBreaker()
+	trip := func() {
+		for i := 0; i < 3; i++ {
+			b.Failure()
+		}
+	}
+	trip()
+	*now = now.Add(time.Second)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("expect half-open, got %s", b.State())
+	}
+	if !b.Allow() {
+		t.Fatal("half-open breaker should allow one probe")
+	}
+	if b.Allow() {
+		t.Fatal("half-open breaker should limit probes")
+	}
+	b.Success()
+	if !b.Allow() {
+		t.Fatal("probe slot should be released")
+	}
+	b.Success()
+	if b.State() != StateClosed {
+		t.Fatalf("expect closed, got %s", b.State())
+	}
+
+	trip()
+	*now = now.Add(time.Second)
+	b.Allow()
+	b.Failure()
+	if b.State() != StateOpen {
+		t.Fatalf("failure in half-open should reopen, got %s", b.State())
+	}
+}
+
+func TestNew_PartialConfig(t *testing.T) {
+	now := time.Now()
+	b := New(&Config{OpenTimeout: time.Second})
+	b.now = func() time.Time { return now }
+
+	// 未设置的字段使用默认值，不会在第一次失败时打开
+	for i := 0; i < DefaultConfig.FailureThreshold-1; i++ {
+		b.Failure()
+	}
+	if b.State() != StateClosed {
+		t.Fatalf("expect closed before default failure threshold, got %s", b.State())
+	}
+	b.Failure()
+	now = now.Add(time.Second)
+	if !b.Allow() {
+		t.Fatal("half-open breaker should allow the default number of probes")
+	}
+	b.Success()
+	if b.State() != StateHalfOpen {
+		t.Fatalf("expect half-open before default success threshold, got %s", b.State())
+	}
+	b.Allow()
+	b.Success()
+	if b.State() != StateClosed {
+		t.Fatalf("expect closed, got %s", b.State())
+	}
+}
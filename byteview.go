@@ -1,20 +1,128 @@
 package gocache
 
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"gocache/store"
+	"sync"
+	"time"
+)
+
 // ByteView 只读的字节视图，用于缓存数据
 type ByteView struct {
 	b []byte
+	e time.Time // 过期时间，零值表示不过期
+	f uint32    // 客户端自定义标记，如memcached的flags
+	c uint64    // 每次写入时分配的CAS版本号
+	t string    // 写入时携带的Content-Type
+
+	// z b的压缩算法，为空时b为原始数据
+	z Compression
+	// u 压缩的值的解压结果，由cache在读取时设置，第一次读取原始数据时才解压，多个副本共享
+	u *inflated
+	// x b为加密后的密文，只出现在存储、快照和AOF中，读取时由cache解密
+	x bool
+	// s 软过期时间，之后条目仍可读取但需要后台刷新，零值表示不会软过期。不保存到快照和AOF中
+	s time.Time
+	// d 从Getter加载该值的耗时，用于XFetch提前刷新。不保存到快照和AOF中
+	d time.Duration
+}
+
+// inflated 压缩的值延迟解压的结果
+type inflated struct {
+	once  sync.Once
+	limit int // 解压结果的大小上限
+	b     []byte
+	err   error
 }
 
+// Len 返回值在缓存中占用的字节数，压缩的值为压缩后的大小
 func (v ByteView) Len() int {
 	return len(v.b)
 }
 
+// ByteSlice 返回原始数据的副本，压缩的值在第一次读取时解压，无法解压时返回nil
 func (v ByteView) ByteSlice() []byte {
-	return cloneBytes(v.b)
+	return cloneBytes(v.data())
 }
 
+// String 返回原始数据，压缩的值在第一次读取时解压，无法解压时返回空字符串
 func (v ByteView) String() string {
-	return string(v.b)
+	return string(v.data())
+}
+
+// Compression 返回值在缓存中使用的压缩算法，未压缩时为空
+func (v ByteView) Compression() Compression {
+	return v.z
+}
+
+// data 返回原始数据，调用方不能修改，无法解压时返回nil。需要区分损坏的值时使用plain
+func (v ByteView) data() []byte {
+	b, _ := v.plain()
+	return b
+}
+
+// plain 返回原始数据，未压缩时返回底层切片，调用方不能修改。压缩的值在第一次调用时解压
+func (v ByteView) plain() ([]byte, error) {
+	if v.z == CompressionNone {
+		return v.b, nil
+	}
+	u := v.u
+	if u == nil {
+		u = &inflated{limit: defaultCompressMaxSize}
+	}
+	u.once.Do(func() {
+		if u.b, u.err = decompress(v.z, v.b, u.limit); u.err != nil {
+			u.err = fmt.Errorf("decompress %s value: %v", v.z, u.err)
+		}
+	})
+	return u.b, u.err
+}
+
+// lazy 返回读取原始数据时才解压的视图，解压结果超过limit字节时视为损坏
+func (v ByteView) lazy(limit int) ByteView {
+	if v.z != CompressionNone {
+		v.u = &inflated{limit: limit}
+	}
+	return v
+}
+
+// decompressed 返回解压后的视图，用于快照和AOF等只保存原始数据的场景，调用方需先通过plain确认能够解压
+func (v ByteView) decompressed() ByteView {
+	v.b, v.z, v.u = v.data(), CompressionNone, nil
+	return v
+}
+
+// Expire 返回过期时间，零值表示不过期
+func (v ByteView) Expire() time.Time {
+	return v.e
+}
+
+// Flags 返回写入时携带的客户端标记
+func (v ByteView) Flags() uint32 {
+	return v.f
+}
+
+// CAS 返回条目的版本号，条目每次写入都会变化
+func (v ByteView) CAS() uint64 {
+	return v.c
+}
+
+// ContentType 返回写入时携带的Content-Type，未设置时为空
+func (v ByteView) ContentType() string {
+	return v.t
+}
+
+// TTL 返回剩余过期时间，不过期时返回0
+func (v ByteView) TTL() time.Duration {
+	if v.e.IsZero() {
+		return 0
+	}
+	if ttl := time.Until(v.e); ttl > 0 {
+		return ttl
+	}
+	return time.Nanosecond
 }
 
 func cloneBytes(b []byte) []byte {
@@ -22,3 +130,58 @@ func cloneBytes(b []byte) []byte {
 	copy(c, b)
 	return c
 }
+
+// byteViewCodec 两级存储的磁盘层和Arena使用的ByteView编码，在快照条目的基础上加上CAS版本号、
+// 压缩算法、是否加密、软过期时间和加载耗时，压缩和加密的值按存储中的数据原样保存
+type byteViewCodec struct{}
+
+func (byteViewCodec) Encode(v store.Value) ([]byte, error) {
+	view := v.(ByteView)
+	b := binary.AppendUvarint(nil, view.c)
+	b = appendBytes(b, []byte(view.z))
+	if view.x {
+		b = append(b, 1)
+	} else {
+		b = append(b, 0)
+	}
+	var stale int64
+	if !view.s.IsZero() {
+		stale = view.s.UnixNano()
+	}
+	b = binary.AppendVarint(b, stale)
+	b = binary.AppendVarint(b, int64(view.d))
+	return appendEntry(b, snapshotEntry{value: view}), nil
+}
+
+func (byteViewCodec) Decode(data []byte) (store.Value, error) {
+	r := bytes.NewReader(data)
+	c, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	z, err := readBytes(r)
+	if err != nil {
+		return nil, err
+	}
+	x, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	stale, err := binary.ReadVarint(r)
+	if err != nil {
+		return nil, err
+	}
+	d, err := binary.ReadVarint(r)
+	if err != nil {
+		return nil, err
+	}
+	e, err := readEntry(r)
+	if err != nil {
+		return nil, err
+	}
+	e.value.c, e.value.z, e.value.x, e.value.d = c, Compression(z), x == 1, time.Duration(d)
+	if stale != 0 {
+		e.value.s = time.Unix(0, stale)
+	}
+	return e.value, nil
+}
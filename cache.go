@@ -1,15 +1,39 @@
 package gocache
 
 import (
+	"fmt"
 	"gocache/store"
+	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// casSeq 条目CAS版本号的全局序列，以启动时间为初值，避免重启后与客户端持有的旧版本号重复
+var casSeq atomic.Uint64
+
+func init() {
+	casSeq.Store(uint64(time.Now().UnixNano()))
+}
+
 type cache struct {
 	lock       sync.RWMutex
+	writeMu    sync.Mutex // 串行化写入，保证条件写入的检查和写入是原子的
 	lruCache   store.Store
 	cacheBytes int64
+	cacheType  store.CacheType
+	ttl        time.Duration     // 未指定过期时间的条目使用的默认TTL，为0时不过期
+	aof        *aofLog           // 未开启AOF时为nil
+	disk       store.DiskOptions // Tiered的磁盘层配置
+	// compression 值的压缩配置，compressIn和compressOut累计尝试压缩的值压缩前后的字节数
+	compression CompressionConfig
+	compressIn  atomic.Int64
+	compressOut atomic.Int64
+	// keys 不为nil时值在存储中加密保存，aeads缓存每个密钥的AES-GCM
+	keys  KeyProvider
+	aeads sync.Map
+	// jitter 从Getter加载的条目的TTL随机缩短的最大比例
+	jitter float64
 }
 
 func (cache *cache) lruCacheLazyLoadIfNeed() {
@@ -17,16 +41,179 @@ func (cache *cache) lruCacheLazyLoadIfNeed() {
 		cache.lock.Lock()
 		defer cache.lock.Unlock()
 		if cache.lruCache == nil {
-			cache.lruCache = store.NewLRUCache(store.Options{
-				MaxBytes: cache.cacheBytes,
-			})
+			// Tiered和Arena在NewGroup中创建并记录错误，这里不会再遇到
+			cache.lruCache, _ = cache.newStore()
+		}
+	}
+}
+
+// newStore 按cacheType创建存储，磁盘层打开失败或Arena没有容量上限时返回LRU和错误
+func (cache *cache) newStore() (store.Store, error) {
+	opts := store.Options{MaxBytes: cache.cacheBytes, Disk: cache.disk, Codec: byteViewCodec{}}
+	if cache.aof != nil {
+		opts.OnExpired = func(key string, _ store.Value) {
+			cache.aof.append(aofExpire, snapshotEntry{key: key})
 		}
 	}
+	switch cache.cacheType {
+	case store.Tiered:
+		s, err := store.NewTieredStore(opts)
+		if err != nil {
+			return store.NewLRUCache(opts), err
+		}
+		return s, nil
+	case store.Arena:
+		s, err := store.NewArenaStore(opts)
+		if err != nil {
+			return store.NewLRUCache(opts), err
+		}
+		return s, nil
+	}
+	if s := store.NewStore(cache.cacheType, opts); s != nil {
+		return s, nil
+	}
+	// 尚未实现的存储类型回退到LRU
+	return store.NewLRUCache(opts), nil
+}
+
+// close 关闭需要释放资源的存储，如两级存储的磁盘文件
+func (cache *cache) close() {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if c, ok := cache.lruCache.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// add 使用默认TTL写入从Getter加载的条目，开启抖动时TTL被随机缩短。cas不为0时只在当前条目的版本号相同时写入
+func (cache *cache) add(key string, value ByteView, cas uint64) (ByteView, error) {
+	return cache.addWithTTL(key, value, cache.jitterTTL(cache.ttl, time.Until(value.s)), cas)
 }
 
-func (cache *cache) add(key string, value ByteView) {
+// addWithTTL 写入条目，ttl<=0时使用默认TTL，返回写入后的条目
+func (cache *cache) addWithTTL(key string, value ByteView, ttl time.Duration, cas uint64) (ByteView, error) {
 	cache.lruCacheLazyLoadIfNeed()
-	cache.lruCache.Set(key, value)
+	cache.writeMu.Lock()
+	defer cache.writeMu.Unlock()
+	if !cache.unchanged(key, cas) {
+		return value, nil
+	}
+	return cache.put(key, value, ttl)
+}
+
+// unchanged 判断key的当前条目版本号是否仍为cas，cas为0时不检查。调用方需持有writeMu
+func (cache *cache) unchanged(key string, cas uint64) bool {
+	if cas == 0 {
+		return true
+	}
+	old, ok := cache.get(key)
+	return ok && old.c == cas
+}
+
+// put 写入条目并分配CAS版本号，调用方需持有writeMu
+func (cache *cache) put(key string, value ByteView, ttl time.Duration) (ByteView, error) {
+	if ttl <= 0 {
+		ttl = cache.ttl
+	}
+	value.c = casSeq.Add(1)
+	if ttl > 0 {
+		value.e = time.Now().Add(ttl)
+	} else {
+		value.e = time.Time{}
+	}
+	stored, err := cache.set(key, value, ttl)
+	if err != nil {
+		cache.log(aofDelete, key, ByteView{})
+		return value, err
+	}
+	cache.logSet(key, value, stored)
+	return value, nil
+}
+
+// set 按配置压缩和加密后写入存储，返回保存的条目，ttl<=0时不过期。
+// 加密或存储失败时删除旧值并返回错误，不会以明文保存。调用方需持有writeMu
+func (cache *cache) set(key string, value ByteView, ttl time.Duration) (ByteView, error) {
+	if value.x && cache.keys == nil {
+		cache.lruCache.Delete(key)
+		return value, fmt.Errorf("encryption is not enabled")
+	}
+	value, err := cache.seal(key, cache.compress(value))
+	if err != nil {
+		cache.lruCache.Delete(key)
+		return value, fmt.Errorf("encrypt value: %v", err)
+	}
+	// 解压结果只属于读取它的请求，不保存到存储中
+	value.u = nil
+	if ttl > 0 {
+		err = cache.lruCache.SetWithExpiration(key, value, ttl)
+	} else {
+		err = cache.lruCache.Set(key, value)
+	}
+	if err != nil {
+		cache.lruCache.Delete(key)
+		return value, fmt.Errorf("store value: %v", err)
+	}
+	return value, nil
+}
+
+// logSet 记录一次写入，加密的条目记录密文，否则记录原始数据
+func (cache *cache) logSet(key string, value, stored ByteView) {
+	if stored.x {
+		value = stored
+	}
+	cache.log(aofSet, key, value)
+}
+
+// log 开启AOF时记录一次修改，未加密的值解压后记录。调用方需持有writeMu以保证记录顺序与写入顺序一致
+func (cache *cache) log(op aofOp, key string, value ByteView) {
+	if cache.aof != nil {
+		cache.aof.append(op, snapshotEntry{key: key, value: value.decompressed()})
+	}
+}
+
+// write 按opts条件写入，返回新条目的CAS版本号
+func (cache *cache) write(key string, value []byte, opts WriteOptions) (uint64, error) {
+	cache.lruCacheLazyLoadIfNeed()
+	cache.writeMu.Lock()
+	defer cache.writeMu.Unlock()
+
+	view := ByteView{b: cloneBytes(value), f: opts.Flags, t: opts.ContentType}
+	ttl := opts.TTL
+	if opts.CAS != 0 || opts.Mode != WriteSet {
+		old, ok := cache.get(key)
+		switch {
+		case opts.CAS != 0 && !ok:
+			return 0, ErrNotFound
+		case opts.CAS != 0 && old.c != opts.CAS:
+			return 0, ErrCASConflict
+		case opts.Mode == WriteAdd && ok:
+			return 0, ErrNotStored
+		case opts.Mode != WriteSet && opts.Mode != WriteAdd && !ok:
+			return 0, ErrNotStored
+		}
+		// 追加时沿用原条目的标记、Content-Type和过期时间
+		if opts.Mode == WriteAppend || opts.Mode == WritePrepend {
+			data, err := old.plain()
+			if err != nil {
+				return 0, err
+			}
+			if opts.Mode == WriteAppend {
+				view.b = append(cloneBytes(data), value...)
+			} else {
+				view.b = append(cloneBytes(value), data...)
+			}
+		}
+		if opts.Mode == WriteAppend || opts.Mode == WritePrepend {
+			view.f, view.t = old.f, old.t
+			ttl = old.TTL()
+		}
+	}
+	if opts.Expire {
+		cache.remove(key)
+		return 0, nil
+	}
+	view, err := cache.put(key, view, ttl)
+	return view.c, err
 }
 
 func (cache *cache) get(key string) (value ByteView, ok bool) {
@@ -36,25 +223,234 @@ func (cache *cache) get(key string) (value ByteView, ok bool) {
 		return
 	}
 	if v, find := cache.lruCache.Get(key); find {
-		return v.(ByteView), true
+		// 无法解密的条目视为不存在
+		value, err := cache.open(key, v.(ByteView))
+		if err != nil {
+			return value, false
+		}
+		// 压缩的值在读取原始数据时才解压，发往peer时直接发送压缩的数据
+		return value.lazy(cache.maxDecompressedSize()), true
 	}
 	return
 }
 
-func (cache *cache) addWithExpiration(key string, value ByteView, expirationTime time.Time) {
+// addWithExpiration 写入从Getter加载的条目，开启抖动时过期时间被随机提前。cas不为0时只在当前条目的版本号相同时写入
+func (cache *cache) addWithExpiration(key string, value ByteView, expirationTime time.Time, cas uint64) (ByteView, error) {
 	cache.lruCacheLazyLoadIfNeed()
-    ttl := time.Until(expirationTime)
-    if ttl < 0 {
-        ttl = 0
-    }
-	cache.lruCache.SetWithExpiration(key, value, ttl)
+	now := time.Now()
+	ttl := expirationTime.Sub(now)
+	if ttl <= 0 {
+		// 已经过期的条目不写入，SetWithExpiration的ttl为0表示永不过期
+		return value, nil
+	}
+	ttl = cache.jitterTTL(ttl, value.s.Sub(now))
+	cache.writeMu.Lock()
+	defer cache.writeMu.Unlock()
+	if !cache.unchanged(key, cas) {
+		return value, nil
+	}
+	value.c = casSeq.Add(1)
+	value.e = now.Add(ttl)
+	stored, err := cache.set(key, value, ttl)
+	if err != nil {
+		cache.log(aofDelete, key, ByteView{})
+		return value, err
+	}
+	cache.logSet(key, value, stored)
+	return value, nil
 }
 
 func (cache *cache) delete(key string) bool {
+	cache.writeMu.Lock()
+	defer cache.writeMu.Unlock()
+	return cache.remove(key)
+}
+
+// drop 删除版本号为cas的条目，用于清理损坏或回源时已不存在的值，条目已被重新写入时保留
+func (cache *cache) drop(key string, cas uint64) {
+	cache.writeMu.Lock()
+	defer cache.writeMu.Unlock()
+	if view, ok := cache.get(key); ok && view.c == cas {
+		cache.remove(key)
+	}
+}
+
+// remove 删除key并记录AOF，调用方需持有writeMu
+func (cache *cache) remove(key string) bool {
 	cache.lock.Lock()
 	defer cache.lock.Unlock()
 	if cache.lruCache == nil {
 		return true
 	}
-	return cache.lruCache.Delete(key)
-}
\ No newline at end of file
+	if !cache.lruCache.Delete(key) {
+		return false
+	}
+	cache.log(aofDelete, key, ByteView{})
+	return true
+}
+
+// entries 按LRU顺序返回未过期的条目，从最久未使用到最近使用。加密的值保持密文，未加密的值被解压
+func (cache *cache) entries() ([]snapshotEntry, error) {
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	if cache.lruCache == nil {
+		return nil, nil
+	}
+	r, ok := cache.lruCache.(store.Ranger)
+	if !ok {
+		return nil, fmt.Errorf("store does not support iteration")
+	}
+	var entries []snapshotEntry
+	r.Range(func(key string, value store.Value, expire time.Time) bool {
+		view := value.(ByteView)
+		if !view.x {
+			// 无法解压的条目不写入快照
+			if _, err := view.lazy(cache.maxDecompressedSize()).plain(); err != nil {
+				return true
+			}
+			view = view.decompressed()
+		}
+		view.e = expire
+		entries = append(entries, snapshotEntry{key: key, value: view})
+		return true
+	})
+	return entries, nil
+}
+
+// restore 按顺序写入快照中的条目，跳过已过期和已存在的key。
+// 容量不足时丢弃最久未使用的条目，返回写入的条目数
+func (cache *cache) restore(entries []snapshotEntry) int {
+	cache.lruCacheLazyLoadIfNeed()
+	cache.writeMu.Lock()
+	defer cache.writeMu.Unlock()
+
+	now := time.Now()
+	cache.lock.RLock()
+	maxBytes := cache.cacheBytes
+	cache.lock.RUnlock()
+
+	// 从最近使用的条目向前累计压缩后的大小，找到容量内能保留的第一个条目
+	first, used := len(entries), int64(0)
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := &entries[i]
+		if (!e.value.e.IsZero() && !e.value.e.After(now)) || (e.value.x && cache.keys == nil) {
+			continue
+		}
+		e.value = cache.compress(e.value)
+		used += int64(len(e.key) + e.value.Len())
+		if maxBytes > 0 && used > maxBytes {
+			break
+		}
+		first = i
+	}
+
+	n := 0
+	for _, e := range entries[first:] {
+		if _, ok := cache.get(e.key); ok {
+			continue
+		}
+		// 保留快照中的过期时间，不使用默认TTL
+		value := e.value
+		value.c = casSeq.Add(1)
+		var ttl time.Duration
+		if !value.e.IsZero() {
+			if ttl = value.e.Sub(now); ttl <= 0 {
+				continue
+			}
+		}
+		stored, err := cache.set(e.key, value, ttl)
+		if err != nil {
+			continue
+		}
+		cache.logSet(e.key, value, stored)
+		n++
+	}
+	return n
+}
+
+// replay 重放AOF，返回记录数、无法写入存储的条目数和日志中最后一条完整记录之后的偏移。重放时不会再写入日志
+func (cache *cache) replay(path string) (int, int, int64, error) {
+	cache.lruCacheLazyLoadIfNeed()
+	cache.writeMu.Lock()
+	defer cache.writeMu.Unlock()
+	skipped := 0
+	records, end, err := replayAOF(path, func(op aofOp, e snapshotEntry) {
+		switch op {
+		case aofSet:
+			value := e.value
+			value.c = casSeq.Add(1)
+			var err error
+			if value.e.IsZero() {
+				_, err = cache.set(e.key, value, 0)
+			} else if ttl := time.Until(value.e); ttl > 0 {
+				_, err = cache.set(e.key, value, ttl)
+			} else {
+				cache.lruCache.Delete(e.key)
+			}
+			if err != nil {
+				skipped++
+			}
+		case aofDelete, aofExpire:
+			cache.lruCache.Delete(e.key)
+		case aofClear:
+			cache.lruCache.Clear()
+		}
+	})
+	return records, skipped, end, err
+}
+
+// beginRewrite 开始AOF重写并返回当前的条目，两者在writeMu下完成，保证之后的修改都会记录到重写缓冲中
+func (cache *cache) beginRewrite() ([]snapshotEntry, error) {
+	cache.lruCacheLazyLoadIfNeed()
+	cache.writeMu.Lock()
+	defer cache.writeMu.Unlock()
+	if err := cache.aof.startRewrite(); err != nil {
+		return nil, err
+	}
+	entries, err := cache.entries()
+	if err != nil {
+		cache.aof.abortRewrite()
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (cache *cache) stats() store.Stats {
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	if cache.lruCache == nil {
+		return store.Stats{}
+	}
+	return cache.lruCache.Stats()
+}
+
+// clear 清空缓存，返回清除的条目数
+func (cache *cache) clear() int {
+	cache.writeMu.Lock()
+	defer cache.writeMu.Unlock()
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if cache.lruCache == nil {
+		return 0
+	}
+	n := cache.lruCache.Len()
+	cache.lruCache.Clear()
+	cache.log(aofClear, "", ByteView{})
+	return n
+}
+
+// resize 调整缓存容量，返回调整前的容量
+func (cache *cache) resize(cacheBytes int64) (int64, error) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	old := cache.cacheBytes
+	if cache.lruCache != nil {
+		r, ok := cache.lruCache.(store.Resizer)
+		if !ok {
+			return old, fmt.Errorf("store does not support resizing")
+		}
+		r.SetMaxBytes(cacheBytes)
+	}
+	cache.cacheBytes = cacheBytes
+	return old, nil
+}
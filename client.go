@@ -2,96 +2,459 @@ package gocache
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"gocache/breaker"
+	"gocache/logger"
 	pb "gocache/pb"
 	"gocache/registry"
+	"log/slog"
+	"net"
+	"sync/atomic"
 	"time"
+
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 )
 
+const defaultClientTimeout = 3 * time.Second
+
 type Client struct {
-	addr string
-	svcName string
-	etcdCli *clientv3.Client
-	conn *grpc.ClientConn
-	grpcCli pb.GoCacheClient
+	addr      string
+	svcName   string
+	etcdCli   *clientv3.Client
+	conn      *grpc.ClientConn
+	grpcCli   pb.GoCacheClient
+	breaker   *breaker.Breaker
+	healthy   atomic.Bool
+	timeout   time.Duration
+	tlsConfig *tls.Config
+	tlsFunc   func(addr string) *tls.Config
+	dialOpts  []grpc.DialOption
+	retry     *RetryPolicy
+	hedge     *HedgePolicy
+	latencies latencyTracker
+	logger    *slog.Logger
+	replicas  func(key, owner string) []*Client // 对冲请求可以发往的其它peer，由ClientPicker设置
 }
 
 var _ Peer = (*Client)(nil)
 
-func NewClient(addr string, svcName string, etcdCli *clientv3.Client) (*Client, error) {
+// ClientOption 定义Client的配置选项
+type ClientOption func(*Client)
+
+// WithBreakerConfig 设置节点熔断器配置
+func WithBreakerConfig(cfg *breaker.Config) ClientOption {
+	return func(c *Client) {
+		c.breaker = breaker.New(cfg)
+	}
+}
+
+// WithClientTimeout 设置调用方ctx未携带deadline时每次调用的超时时间
+func WithClientTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithClientLogger 设置Client使用的logger
+func WithClientLogger(l *slog.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = logger.New("client", l)
+	}
+}
+
+// WithTLS 使用TLS连接peer，配置中带客户端证书时即为mTLS。config.ServerName为空时使用peer地址中的主机名
+func WithTLS(config *tls.Config) ClientOption {
+	return func(c *Client) {
+		c.tlsConfig = config
+	}
+}
+
+// WithTLSFunc 按peer地址生成TLS配置，如 tlsutil.Reloader.ClientConfigFor，
+// 每个peer的证书按各自的地址校验，优先于WithTLS
+func WithTLSFunc(fn func(addr string) *tls.Config) ClientOption {
+	return func(c *Client) {
+		c.tlsFunc = fn
+	}
+}
+
+// WithDialOptions 追加grpc连接选项，如 auth.TokenCredentials 或 auth.HMACClientInterceptor
+func WithDialOptions(opts ...grpc.DialOption) ClientOption {
+	return func(c *Client) {
+		c.dialOpts = append(c.dialOpts, opts...)
+	}
+}
+
+// WithRetryPolicy 设置Get/Delete的重试策略，传nil关闭重试
+func WithRetryPolicy(policy *RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// WithHedgePolicy 开启Get请求对冲，对冲请求发往哈希环上的下一个peer，传nil关闭
+func WithHedgePolicy(policy *HedgePolicy) ClientOption {
+	return func(c *Client) {
+		c.hedge = policy
+	}
+}
+
+func NewClient(addr string, svcName string, etcdCli *clientv3.Client, opts ...ClientOption) (*Client, error) {
 	var err error
 	if etcdCli == nil {
 		etcdCli, err = clientv3.New(clientv3.Config{
-			Endpoints: []string{"localhost:2379"},
+			Endpoints:   []string{"localhost:2379"},
 			DialTimeout: 5 * time.Second,
 		})
 		if err != nil {
 			return nil, err
 		}
 	}
-	conn, err := registry.EtcdDial(etcdCli, svcName, addr)
-	if err != nil {
-		return nil, err
-	}
-	grpcCli := pb.NewGoCacheClient(conn)
 	client := &Client{
-		addr: addr,
+		addr:    addr,
 		svcName: svcName,
 		etcdCli: etcdCli,
-		conn: conn,
-		grpcCli: grpcCli,
+		breaker: breaker.New(nil),
+		timeout: defaultClientTimeout,
+		logger:  logger.New("client", nil),
 	}
+	client.healthy.Store(true)
+	for _, opt := range opts {
+		opt(client)
+	}
+	client.logger = client.logger.With("peer", addr)
+
+	dialOpts := []grpc.DialOption{grpc.WithChainUnaryInterceptor(tracingClientInterceptor(addr), peerMetricsInterceptor(addr))}
+	if tlsConfig := client.peerTLSConfig(); tlsConfig != nil {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	}
+	dialOpts = append(dialOpts, client.dialOpts...)
+	conn, err := registry.EtcdDial(etcdCli, svcName, addr, dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	client.conn = conn
+	client.grpcCli = pb.NewGoCacheClient(conn)
 	return client, nil
 }
 
-func (c *Client) Get(group, key string) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// peerTLSConfig 返回连接peer使用的TLS配置。经etcd解析时grpc的authority不是peer的主机名，
+// 未指定ServerName时使用addr中的主机名校验证书
+func (c *Client) peerTLSConfig() *tls.Config {
+	if c.tlsFunc != nil {
+		return c.tlsFunc(c.addr)
+	}
+	if c.tlsConfig == nil || c.tlsConfig.ServerName != "" {
+		return c.tlsConfig
+	}
+	config := c.tlsConfig.Clone()
+	if host, _, err := net.SplitHostPort(c.addr); err == nil {
+		config.ServerName = host
+	}
+	return config
+}
+
+// Get 读取key，返回的ByteView带有条目在peer上的过期时间
+func (c *Client) Get(ctx context.Context, group, key string) (ByteView, error) {
+	ctx, cancel := c.withTimeout(ctx)
 	defer cancel()
 
-	resp, err := c.grpcCli.Get(ctx, &pb.Request{
-		Group: group,
-		Key:   key,
+	req := &pb.Request{
+		Group:            group,
+		Key:              key,
+		AcceptCompressed: true,
+		CacheOnly:        isCacheOnly(ctx),
+	}
+	var resp *pb.ResponseForGet
+	err := c.invoke(ctx, c.retry, func(ctx context.Context) error {
+		var err error
+		resp, err = c.hedgedGet(ctx, req)
+		return err
 	})
+	if status.Code(err) == codes.NotFound {
+		err = ErrNotFound
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get value from lcache: %v", err)
+		return ByteView{}, fmt.Errorf("failed to get value from lcache: %w", err)
 	}
 
-	return resp.GetValue(), nil
+	z, err := ParseCompression(resp.GetCompression())
+	if err != nil {
+		return ByteView{}, fmt.Errorf("failed to get value from lcache: %w", err)
+	}
+	// 压缩的值在读取原始数据时才解压，写入本地热点缓存时保持压缩
+	view := ByteView{b: resp.GetValue(), z: z, f: resp.GetFlags(), c: resp.GetCas(), t: resp.GetContentType()}.lazy(maxDecompressedSize(group))
+	if ms := resp.GetTtlMs(); ms > 0 {
+		view.e = time.Now().Add(time.Duration(ms) * time.Millisecond)
+	}
+	return view, nil
+}
+
+// Set 写入不保证幂等，不做重试。ttl<=0时使用peer上Group的默认TTL
+func (c *Client) Set(ctx context.Context, group, key string, value []byte, ttl time.Duration) error {
+	_, err := c.Write(ctx, group, key, value, WriteOptions{TTL: ttl})
+	return err
 }
 
-func (c *Client) Set(group, key string, value []byte) error {
-    ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-    defer cancel()
+// Write 按opts写入，返回新条目的CAS版本号。条件不满足时返回的错误包装了ErrNotStored、ErrCASConflict或ErrNotFound。
+// 值以原始数据发送，由peer按自己的配置压缩
+func (c *Client) Write(ctx context.Context, group, key string, value []byte, opts WriteOptions) (uint64, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
 
-    _, err := c.grpcCli.Set(ctx, &pb.Request{
-        Group: group,
-        Key:   key,
-        Value: value,
-    })
-    
-    if err != nil {
-        return fmt.Errorf("failed to set value to peer %s: %v", c.addr, err)
-    }
-    return nil
+	var resp *pb.ResponseForGet
+	err := c.invoke(ctx, nil, func(ctx context.Context) error {
+		var err error
+		resp, err = c.grpcCli.Set(ctx, &pb.Request{
+			Group:       group,
+			Key:         key,
+			Value:       value,
+			TtlMs:       opts.TTL.Milliseconds(),
+			Flags:       opts.Flags,
+			Cas:         opts.CAS,
+			Mode:        int32(opts.Mode),
+			ContentType: opts.ContentType,
+			Expire:      opts.Expire,
+		})
+		return err
+	})
+	if err != nil {
+		switch status.Code(err) {
+		case codes.FailedPrecondition:
+			err = ErrNotStored
+		case codes.Aborted:
+			err = ErrCASConflict
+		case codes.NotFound:
+			err = ErrNotFound
+		}
+		return 0, fmt.Errorf("failed to set value to peer %s: %w", c.addr, err)
+	}
+	return resp.GetCas(), nil
 }
 
-func (c *Client) Delete(group, key string) (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (c *Client) Delete(ctx context.Context, group, key string) (bool, error) {
+	ctx, cancel := c.withTimeout(ctx)
 	defer cancel()
 
-	resp, err := c.grpcCli.Delete(ctx, &pb.Request{
-		Group: group,
-		Key:   key,
+	var resp *pb.ResponseForDelete
+	err := c.invoke(ctx, c.retry, func(ctx context.Context) error {
+		var err error
+		resp, err = c.grpcCli.Delete(ctx, &pb.Request{
+			Group: group,
+			Key:   key,
+		})
+		return err
 	})
 	if err != nil {
-		return false, fmt.Errorf("failed to delete value from lcache: %v", err)
+		return false, fmt.Errorf("failed to delete value from lcache: %w", err)
 	}
 
 	return resp.GetValue(), nil
 }
 
+// Stats 获取peer上指定Group的统计快照
+func (c *Client) Stats(ctx context.Context, group string) (Stats, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	var resp *pb.ResponseForStats
+	err := c.invoke(ctx, c.retry, func(ctx context.Context) error {
+		var err error
+		resp, err = c.grpcCli.Stats(ctx, &pb.StatsRequest{Group: group})
+		return err
+	})
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to get stats from peer %s: %w", c.addr, err)
+	}
+	return statsFromPB(resp), nil
+}
+
+// withTimeout 调用方未设置deadline时使用Client的默认超时
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, ok := ctx.Deadline(); ok || c.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// invoke 按重试策略执行一次调用，每次尝试都经过熔断器
+func (c *Client) invoke(ctx context.Context, policy *RetryPolicy, call func(ctx context.Context) error) error {
+	attempts := 1
+	if policy != nil && policy.MaxAttempts > 1 {
+		attempts = policy.MaxAttempts
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if sleepErr := sleepContext(ctx, policy.backoff(attempt)); sleepErr != nil {
+				return err
+			}
+		}
+		if !c.breaker.Allow() {
+			return fmt.Errorf("peer %s: %w", c.addr, breaker.ErrOpen)
+		}
+		err = call(ctx)
+		c.report(err)
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// hedgedGet 发出Get请求，开启对冲时若首个请求超过对冲延迟仍未返回，则按哈希环顺序向下一个peer追加只读请求。
+// 没有其它可用的peer时不对冲，向同一个peer重复请求无法绕开慢节点
+func (c *Client) hedgedGet(ctx context.Context, req *pb.Request) (*pb.ResponseForGet, error) {
+	delay := c.hedgeDelay()
+	// 只读取缓存的请求只有owner能回答，不对冲
+	if delay <= 0 || c.replicas == nil || req.GetCacheOnly() {
+		return c.getOnce(ctx, req)
+	}
+	targets := c.replicas(req.GetKey(), c.addr)
+	if len(targets) == 0 {
+		return c.getOnce(ctx, req)
+	}
+	if len(targets) > c.hedge.MaxHedges {
+		targets = targets[:c.hedge.MaxHedges]
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		resp *pb.ResponseForGet
+		err  error
+	}
+	results := make(chan result, len(targets)+1)
+	launch := func(get func(ctx context.Context, req *pb.Request) (*pb.ResponseForGet, error)) {
+		go func() {
+			resp, err := get(ctx, req)
+			results <- result{resp, err}
+		}()
+	}
+
+	launch(c.getOnce)
+	inflight, hedges := 1, 0
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case r := <-results:
+			inflight--
+			if r.err == nil || inflight == 0 {
+				return r.resp, r.err
+			}
+		case <-timer.C:
+			if hedges < len(targets) {
+				c.logger.Debug("hedging get", logger.Key(req.GetKey()), "replica", targets[hedges].addr)
+				launch(targets[hedges].hedgeOnce)
+				inflight++
+				hedges++
+				timer.Reset(delay)
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// getOnce 发出单个Get请求并记录成功请求的延迟
+func (c *Client) getOnce(ctx context.Context, req *pb.Request) (*pb.ResponseForGet, error) {
+	start := time.Now()
+	resp, err := c.grpcCli.Get(ctx, req)
+	if err == nil {
+		c.latencies.observe(time.Since(start))
+	}
+	return resp, err
+}
+
+// hedgeOnce 向副本发出对冲请求。请求经过副本的熔断器，并标记为只读，副本回源后不写入缓存
+func (c *Client) hedgeOnce(ctx context.Context, req *pb.Request) (*pb.ResponseForGet, error) {
+	if !c.breaker.Allow() {
+		return nil, fmt.Errorf("peer %s: %w", c.addr, breaker.ErrOpen)
+	}
+	resp, err := c.getOnce(withReadOnly(ctx), req)
+	c.report(err)
+	return resp, err
+}
+
+// hedgeDelay 返回当前的对冲延迟，<=0表示不对冲
+func (c *Client) hedgeDelay() time.Duration {
+	if c.hedge == nil || c.hedge.MaxHedges <= 0 {
+		return 0
+	}
+	if c.hedge.Delay > 0 {
+		return c.hedge.Delay
+	}
+	delay := c.latencies.percentile95()
+	if delay < c.hedge.MinDelay {
+		delay = c.hedge.MinDelay
+	}
+	return delay
+}
+
+// report 将一次请求的结果上报给熔断器，业务错误(如key不存在)不计为节点故障
+func (c *Client) report(err error) {
+	if isPeerFailure(err) {
+		c.breaker.Failure()
+	} else {
+		c.breaker.Success()
+	}
+}
+
+// isPeerFailure 判断错误是否说明节点本身不可用
+func isPeerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal:
+		return true
+	}
+	return false
+}
+
+// Addr 返回peer地址
+func (c *Client) Addr() string {
+	return c.addr
+}
+
+// Available 返回节点当前是否可以接收请求
+func (c *Client) Available() bool {
+	return c.healthy.Load() && c.breaker.State() != breaker.StateOpen
+}
+
+// probe 通过grpc健康检查协议探测节点状态
+func (c *Client) probe(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := healthpb.NewHealthClient(c.conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	healthy := err == nil && resp.GetStatus() == healthpb.HealthCheckResponse_SERVING
+	if status.Code(err) == codes.Unimplemented {
+		// 对端未开启健康检查服务，只依赖熔断器判断
+		healthy = true
+	}
+
+	if prev := c.healthy.Swap(healthy); prev != healthy {
+		if healthy {
+			c.logger.Info("peer is healthy again")
+		} else {
+			c.logger.Warn("peer failed health check", "status", resp.GetStatus().String(), "error", err)
+		}
+	}
+}
+
 func (c *Client) Close() error {
 	if c.conn != nil {
 		return c.conn.Close()
@@ -0,0 +1,115 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"gocache/breaker"
+	"gocache/consistenthash"
+	"gocache/logger"
+	pb "gocache/pb"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeGoCacheClient Get返回节点地址，slow为true时阻塞到请求取消，readOnly记录请求是否带有只读标记
+type fakeGoCacheClient struct {
+	pb.GoCacheClient
+	addr     string
+	slow     bool
+	calls    atomic.Int64
+	readOnly atomic.Bool
+}
+
+func (f *fakeGoCacheClient) Get(ctx context.Context, in *pb.Request, opts ...grpc.CallOption) (*pb.ResponseForGet, error) {
+	f.calls.Add(1)
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(headerReadOnly)) > 0 {
+		f.readOnly.Store(true)
+	}
+	if f.slow {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &pb.ResponseForGet{Value: []byte(f.addr)}, nil
+}
+
+func newHedgingClient(addr string, grpcCli pb.GoCacheClient) *Client {
+	c := &Client{
+		addr:    addr,
+		grpcCli: grpcCli,
+		breaker: breaker.New(nil),
+		timeout: time.Second,
+		hedge:   &HedgePolicy{Delay: 5 * time.Millisecond, MaxHedges: 1},
+		logger:  logger.New("client", nil),
+	}
+	c.healthy.Store(true)
+	return c
+}
+
+func TestClient_HedgeToNextReplica(t *testing.T) {
+	p := &ClientPicker{selfAddr: "self:1", consHash: consistenthash.New(), clients: make(map[string]*Client)}
+	p.consHash.Add(p.selfAddr)
+	fakes := make(map[string]*fakeGoCacheClient)
+	for _, addr := range []string{"a:1", "b:1", "c:1"} {
+		fakes[addr] = &fakeGoCacheClient{addr: addr}
+		p.add(addr, newHedgingClient(addr, fakes[addr]))
+	}
+
+	var key string
+	var owner *Client
+	for i := 0; owner == nil; i++ {
+		key = strconv.Itoa(i)
+		if peer, ok, self := p.PickPeer(key); ok && !self {
+			owner = peer.(*Client)
+		}
+	}
+	fakes[owner.addr].slow = true
+
+	// 对冲请求发往哈希环上owner之后的第一个peer
+	var next string
+	for _, addr := range p.consHash.GetN(key, 4) {
+		if addr != owner.addr && addr != p.selfAddr {
+			next = addr
+			break
+		}
+	}
+	v, err := owner.Get(context.Background(), "g", key)
+	if err != nil || v.String() != next {
+		t.Fatalf("expect value from replica %s, got %q %v", next, v.String(), err)
+	}
+	for addr, fake := range fakes {
+		want := int64(0)
+		if addr == owner.addr || addr == next {
+			want = 1
+		}
+		if n := fake.calls.Load(); n != want {
+			t.Fatalf("%s: expect %d calls, got %d", addr, want, n)
+		}
+	}
+	// 对冲请求只读，owner收到的请求不带只读标记
+	if !fakes[next].readOnly.Load() || fakes[owner.addr].readOnly.Load() {
+		t.Fatal("expect only the hedged request marked read-only")
+	}
+
+	// 对冲请求经过副本的熔断器
+	replica := newHedgingClient("replica:1", &fakeGoCacheClient{addr: "replica:1"})
+	for replica.breaker.State() != breaker.StateOpen {
+		replica.breaker.Failure()
+	}
+	if _, err := replica.hedgeOnce(context.Background(), &pb.Request{Key: key}); !errors.Is(err, breaker.ErrOpen) {
+		t.Fatalf("expect hedge rejected by the replica's breaker, got %v", err)
+	}
+
+	// 没有其它peer时不对冲
+	alone := &fakeGoCacheClient{addr: "alone:1", slow: true}
+	c := newHedgingClient("alone:1", alone)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Get(ctx, "g", key); err == nil || alone.calls.Load() != 1 {
+		t.Fatalf("expect a single request without replicas, got %d calls %v", alone.calls.Load(), err)
+	}
+}
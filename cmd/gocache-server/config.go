@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gocache"
+	"gocache/store"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix 环境变量前缀，环境变量优先于配置文件
+const envPrefix = "GOCACHE_"
+
+// Config gocache-server的配置，支持YAML和TOML，按文件扩展名选择格式
+type Config struct {
+	Addr            string          `yaml:"addr" toml:"addr"`
+	Service         string          `yaml:"service" toml:"service"`
+	Admin           bool            `yaml:"admin" toml:"admin"`
+	AdminInsecure   bool            `yaml:"admin_insecure" toml:"admin_insecure"`
+	ShutdownTimeout time.Duration   `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	Discovery       DiscoveryConfig `yaml:"discovery" toml:"discovery"`
+	TLS             TLSConfig       `yaml:"tls" toml:"tls"`
+	Metrics         MetricsConfig   `yaml:"metrics" toml:"metrics"`
+	Log             LogConfig       `yaml:"log" toml:"log"`
+	Groups          []GroupConfig   `yaml:"groups" toml:"groups"`
+}
+
+// DiscoveryConfig 服务发现配置，Type为none时以单节点运行
+type DiscoveryConfig struct {
+	Type string     `yaml:"type" toml:"type"`
+	Etcd EtcdConfig `yaml:"etcd" toml:"etcd"`
+}
+
+// EtcdConfig etcd服务发现配置
+type EtcdConfig struct {
+	Endpoints   []string      `yaml:"endpoints" toml:"endpoints"`
+	DialTimeout time.Duration `yaml:"dial_timeout" toml:"dial_timeout"`
+	LeaseTTL    int64         `yaml:"lease_ttl" toml:"lease_ttl"`
+}
+
+// TLSConfig 节点TLS配置，同时用于对外服务和连接peer，证书文件变化时自动重新加载
+type TLSConfig struct {
+	CertFile          string   `yaml:"cert_file" toml:"cert_file"`
+	KeyFile           string   `yaml:"key_file" toml:"key_file"`
+	CAFile            string   `yaml:"ca_file" toml:"ca_file"`
+	ServerName        string   `yaml:"server_name" toml:"server_name"`
+	ClientAuth        bool     `yaml:"client_auth" toml:"client_auth"`
+	AllowedIdentities []string `yaml:"allowed_identities" toml:"allowed_identities"`
+}
+
+// Enabled 是否配置了TLS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// MetricsConfig Prometheus指标配置，Addr为空时不暴露指标
+type MetricsConfig struct {
+	Addr string `yaml:"addr" toml:"addr"`
+}
+
+// LogConfig 日志配置，Levels按组件设置级别
+type LogConfig struct {
+	Level  string            `yaml:"level" toml:"level"`
+	Format string            `yaml:"format" toml:"format"`
+	Levels map[string]string `yaml:"levels" toml:"levels"`
+}
+
+// GroupConfig 缓存Group配置
+type GroupConfig struct {
+	Name     string         `yaml:"name" toml:"name"`
+	MaxBytes Size           `yaml:"max_bytes" toml:"max_bytes"`
+	Policy   string         `yaml:"policy" toml:"policy"`
+	TTL      time.Duration  `yaml:"ttl" toml:"ttl"`
+	Snapshot SnapshotConfig `yaml:"snapshot" toml:"snapshot"`
+	AOF      AOFConfig      `yaml:"aof" toml:"aof"`
+	Disk     DiskConfig     `yaml:"disk" toml:"disk"`
+	// Compression 值的压缩配置
+	Compression CompressionConfig `yaml:"compression" toml:"compression"`
+	// Encryption 值的静态加密配置
+	Encryption EncryptionConfig `yaml:"encryption" toml:"encryption"`
+}
+
+// EncryptionConfig Group的静态加密配置，KeyFile为空时不加密。
+// 密钥文件每行为"<ID> <base64编码的密钥>"，最后一行为当前密钥，reload时重新读取
+type EncryptionConfig struct {
+	KeyFile string `yaml:"key_file" toml:"key_file"`
+}
+
+// CompressionConfig Group的压缩配置，Algorithm为空时不压缩
+type CompressionConfig struct {
+	Algorithm string `yaml:"algorithm" toml:"algorithm"`
+	MinSize   Size   `yaml:"min_size" toml:"min_size"`
+	MaxSize   Size   `yaml:"max_size" toml:"max_size"`
+}
+
+// DiskConfig 两级存储的磁盘层配置，Dir为空时只使用内存
+type DiskConfig struct {
+	Dir          string `yaml:"dir" toml:"dir"`
+	MaxBytes     Size   `yaml:"max_bytes" toml:"max_bytes"`
+	SegmentBytes Size   `yaml:"segment_bytes" toml:"segment_bytes"`
+}
+
+// SnapshotConfig Group的快照配置，Path为空时不开启
+type SnapshotConfig struct {
+	Path     string        `yaml:"path" toml:"path"`
+	Interval time.Duration `yaml:"interval" toml:"interval"`
+}
+
+// AOFConfig Group的AOF配置，Path为空时不开启
+type AOFConfig struct {
+	Path           string `yaml:"path" toml:"path"`
+	Fsync          string `yaml:"fsync" toml:"fsync"`
+	RewriteMinSize Size   `yaml:"rewrite_min_size" toml:"rewrite_min_size"`
+}
+
+// Size 字节数，支持整数或带单位的字符串，如"64MB"、"1GiB"
+type Size int64
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+	{"B", 1},
+}
+
+// ParseSize 解析字节数，单位按1024进制
+func ParseSize(s string) (Size, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(str, unit.suffix) {
+			str, multiplier = strings.TrimSpace(strings.TrimSuffix(str, unit.suffix)), unit.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return Size(n * multiplier), nil
+}
+
+func (s *Size) UnmarshalText(text []byte) error {
+	v, err := ParseSize(string(text))
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+func (s *Size) UnmarshalYAML(node *yaml.Node) error {
+	return s.UnmarshalText([]byte(node.Value))
+}
+
+func (s *Size) UnmarshalTOML(v interface{}) error {
+	switch v := v.(type) {
+	case int64:
+		*s = Size(v)
+		return nil
+	case string:
+		return s.UnmarshalText([]byte(v))
+	default:
+		return fmt.Errorf("invalid size %v", v)
+	}
+}
+
+// defaultConfig 返回默认配置，配置文件中未设置的字段使用默认值
+func defaultConfig() *Config {
+	return &Config{
+		Addr:            "localhost:9999",
+		Service:         "lcache",
+		ShutdownTimeout: 10 * time.Second,
+		Discovery: DiscoveryConfig{
+			Type: "none",
+			Etcd: EtcdConfig{
+				Endpoints:   []string{"localhost:2379"},
+				DialTimeout: 5 * time.Second,
+				LeaseTTL:    3,
+			},
+		},
+		Log: LogConfig{Level: "info", Format: "text"},
+	}
+}
+
+// LoadConfig 读取配置文件并应用环境变量覆盖，path为空时只使用默认值和环境变量
+func LoadConfig(path string) (*Config, error) {
+	cfg := defaultConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read config: %v", err)
+		}
+		if err := decodeConfig(path, data, cfg); err != nil {
+			return nil, fmt.Errorf("parse config %s: %v", path, err)
+		}
+	}
+	if err := applyEnv(cfg, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	return cfg, nil
+}
+
+func decodeConfig(path string, data []byte, cfg *Config) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		return nil
+	case ".toml":
+		md, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return err
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown field %s", undecoded[0])
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported config format %q, use .yaml, .yml or .toml", filepath.Ext(path))
+	}
+}
+
+// envOverrides 支持的环境变量，名称为envPrefix加上key
+var envOverrides = []struct {
+	key   string
+	apply func(cfg *Config, v string) error
+}{
+	{"ADDR", func(cfg *Config, v string) error { cfg.Addr = v; return nil }},
+	{"SERVICE", func(cfg *Config, v string) error { cfg.Service = v; return nil }},
+	{"ADMIN", func(cfg *Config, v string) (err error) { cfg.Admin, err = strconv.ParseBool(v); return }},
+	{"ADMIN_INSECURE", func(cfg *Config, v string) (err error) { cfg.AdminInsecure, err = strconv.ParseBool(v); return }},
+	{"SHUTDOWN_TIMEOUT", func(cfg *Config, v string) (err error) { cfg.ShutdownTimeout, err = time.ParseDuration(v); return }},
+	{"DISCOVERY", func(cfg *Config, v string) error { cfg.Discovery.Type = v; return nil }},
+	{"ETCD_ENDPOINTS", func(cfg *Config, v string) error { cfg.Discovery.Etcd.Endpoints = splitList(v); return nil }},
+	{"TLS_CERT_FILE", func(cfg *Config, v string) error { cfg.TLS.CertFile = v; return nil }},
+	{"TLS_KEY_FILE", func(cfg *Config, v string) error { cfg.TLS.KeyFile = v; return nil }},
+	{"TLS_CA_FILE", func(cfg *Config, v string) error { cfg.TLS.CAFile = v; return nil }},
+	{"METRICS_ADDR", func(cfg *Config, v string) error { cfg.Metrics.Addr = v; return nil }},
+	{"LOG_LEVEL", func(cfg *Config, v string) error { cfg.Log.Level = v; return nil }},
+	{"LOG_FORMAT", func(cfg *Config, v string) error { cfg.Log.Format = v; return nil }},
+}
+
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	for _, o := range envOverrides {
+		v, ok := lookup(envPrefix + o.key)
+		if !ok {
+			continue
+		}
+		if err := o.apply(cfg, v); err != nil {
+			return fmt.Errorf("invalid %s%s: %v", envPrefix, o.key, err)
+		}
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Validate 校验配置
+func (c *Config) Validate() error {
+	if !gocache.ValidPeerAddr(c.Addr) {
+		return fmt.Errorf("invalid addr %q", c.Addr)
+	}
+	if c.Service == "" {
+		return fmt.Errorf("service is required")
+	}
+	// gocache-server没有配置认证，Admin服务只能显式以不安全的方式开启
+	if c.Admin && !c.AdminInsecure {
+		return fmt.Errorf("admin requires admin_insecure, the admin service has no authentication")
+	}
+	switch c.Discovery.Type {
+	case "", "none":
+	case "etcd":
+		if len(c.Discovery.Etcd.Endpoints) == 0 {
+			return fmt.Errorf("discovery.etcd.endpoints is required")
+		}
+	default:
+		return fmt.Errorf("unknown discovery type %q", c.Discovery.Type)
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return fmt.Errorf("tls.cert_file and tls.key_file must be set together")
+	}
+	if _, err := parseLevel(c.Log.Level); err != nil {
+		return err
+	}
+	for component, level := range c.Log.Levels {
+		if _, err := parseLevel(level); err != nil {
+			return fmt.Errorf("log.levels.%s: %v", component, err)
+		}
+	}
+	switch c.Log.Format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("unknown log format %q", c.Log.Format)
+	}
+
+	names := make(map[string]bool, len(c.Groups))
+	for _, g := range c.Groups {
+		if g.Name == "" {
+			return fmt.Errorf("group name is required")
+		}
+		if names[g.Name] {
+			return fmt.Errorf("duplicate group %q", g.Name)
+		}
+		names[g.Name] = true
+		if g.MaxBytes <= 0 {
+			return fmt.Errorf("group %s: max_bytes must be positive", g.Name)
+		}
+		if _, err := parsePolicy(g.Policy); err != nil {
+			return fmt.Errorf("group %s: %v", g.Name, err)
+		}
+		if g.TTL < 0 {
+			return fmt.Errorf("group %s: ttl must not be negative", g.Name)
+		}
+		if g.Snapshot.Interval < 0 {
+			return fmt.Errorf("group %s: snapshot.interval must not be negative", g.Name)
+		}
+		if g.Snapshot.Path == "" && g.Snapshot.Interval > 0 {
+			return fmt.Errorf("group %s: snapshot.path is required", g.Name)
+		}
+		if _, err := gocache.ParseFsyncPolicy(g.AOF.Fsync); err != nil {
+			return fmt.Errorf("group %s: aof.fsync: %v", g.Name, err)
+		}
+		if g.Disk.Dir == "" && (g.Disk.MaxBytes > 0 || g.Disk.SegmentBytes > 0) {
+			return fmt.Errorf("group %s: disk.dir is required", g.Name)
+		}
+		if _, err := gocache.ParseCompression(g.Compression.Algorithm); err != nil {
+			return fmt.Errorf("group %s: compression.algorithm: %v", g.Name, err)
+		}
+		if policy, _ := parsePolicy(g.Policy); g.Disk.Dir != "" && policy != store.LRU {
+			return fmt.Errorf("group %s: disk tier requires lru policy", g.Name)
+		}
+	}
+	return nil
+}
+
+// parsePolicy 解析存储策略，支持lru和arena
+func parsePolicy(policy string) (store.CacheType, error) {
+	switch store.CacheType(strings.ToLower(policy)) {
+	case "", store.LRU:
+		return store.LRU, nil
+	case store.Arena:
+		return store.Arena, nil
+	default:
+		return "", fmt.Errorf("unsupported policy %q", policy)
+	}
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return level, fmt.Errorf("invalid log level %q", s)
+	}
+	return level, nil
+}
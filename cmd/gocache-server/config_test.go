@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"gocache"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig_Examples(t *testing.T) {
+	yamlCfg, err := LoadConfig("gocache.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tomlCfg, err := LoadConfig("gocache.example.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(yamlCfg, tomlCfg) {
+		t.Fatalf("yaml and toml examples differ:\n%+v\n%+v", yamlCfg, tomlCfg)
+	}
+
+	want := []GroupConfig{
+		{Name: "scores", MaxBytes: 64 << 20, Policy: "lru", TTL: 10 * time.Minute,
+			Snapshot:    SnapshotConfig{Path: "/var/lib/gocache/scores.snap", Interval: 5 * time.Minute},
+			Compression: CompressionConfig{Algorithm: "zstd", MinSize: 1 << 10}},
+		{Name: "sessions", MaxBytes: 16 << 20},
+	}
+	if !reflect.DeepEqual(yamlCfg.Groups, want) {
+		t.Fatalf("unexpected groups %+v", yamlCfg.Groups)
+	}
+	if yamlCfg.Discovery.Type != "etcd" || yamlCfg.Log.Levels["server"] != "debug" {
+		t.Fatalf("unexpected config %+v", yamlCfg)
+	}
+}
+
+func TestLoadConfig_EnvOverride(t *testing.T) {
+	t.Setenv("GOCACHE_ADDR", "localhost:7000")
+	t.Setenv("GOCACHE_ETCD_ENDPOINTS", "etcd-1:2379, etcd-2:2379")
+	t.Setenv("GOCACHE_ADMIN", "false")
+
+	cfg, err := LoadConfig("gocache.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != "localhost:7000" || cfg.Admin {
+		t.Fatalf("env not applied: %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.Discovery.Etcd.Endpoints, []string{"etcd-1:2379", "etcd-2:2379"}) {
+		t.Fatalf("unexpected endpoints %v", cfg.Discovery.Etcd.Endpoints)
+	}
+
+	t.Setenv("GOCACHE_ADMIN", "maybe")
+	if _, err := LoadConfig("gocache.example.yaml"); err == nil {
+		t.Fatal("expect error for invalid bool")
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown.yaml":   "addr: localhost:9999\nunknown: 1\n",
+		"unknown.toml":   "addr = \"localhost:9999\"\nunknown = 1\n",
+		"policy.yaml":    "groups:\n  - {name: g, max_bytes: 1KB, policy: lfu}\n",
+		"disk.yaml":      "groups:\n  - {name: g, max_bytes: 1KB, policy: arena, disk: {dir: /tmp/g}}\n",
+		"size.yaml":      "groups:\n  - {name: g, max_bytes: lots}\n",
+		"nosize.yaml":    "groups:\n  - {name: g}\n",
+		"dup.yaml":       "groups:\n  - {name: g, max_bytes: 1}\n  - {name: g, max_bytes: 1}\n",
+		"discovery.yaml": "discovery: {type: consul}\n",
+		"tls.yaml":       "tls: {cert_file: a.crt}\n",
+		"level.yaml":     "log: {level: loud}\n",
+		"snapshot.yaml":  "groups:\n  - {name: g, max_bytes: 1, snapshot: {interval: 1m}}\n",
+		"fsync.yaml":     "groups:\n  - {name: g, max_bytes: 1, aof: {path: g.aof, fsync: sometimes}}\n",
+		"compress.yaml":  "groups:\n  - {name: g, max_bytes: 1, compression: {algorithm: lz4}}\n",
+		"admin.yaml":     "admin: true\n",
+		"config.json":    "{}",
+	}
+	dir := t.TempDir()
+	for name, content := range tests {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("%s: expect error", name)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]Size{
+		"1024":   1024,
+		"2KB":    2 << 10,
+		"64MB":   64 << 20,
+		"1GiB":   1 << 30,
+		"512 b":  512,
+		"10m":    10 << 20,
+		"  3G  ": 3 << 30,
+	}
+	for s, want := range tests {
+		got, err := ParseSize(s)
+		if err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "MB", "-1", "1.5GB", "1TB"} {
+		if _, err := ParseSize(s); err == nil {
+			t.Errorf("ParseSize(%q) expect error", s)
+		}
+	}
+}
+
+func TestNode_Reload(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Groups = []GroupConfig{
+		{Name: "reload-keep", MaxBytes: 1 << 10},
+		{Name: "reload-drop", MaxBytes: 1 << 10},
+	}
+	n, err := newNode(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for name := range n.groups {
+			gocache.DestroyGroup(name)
+		}
+	}()
+
+	keep := gocache.GetGroup("reload-keep")
+	if err := keep.Set(context.Background(), "k", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+
+	next := defaultConfig()
+	next.Addr = "localhost:7777"
+	next.Groups = []GroupConfig{
+		{Name: "reload-keep", MaxBytes: 2 << 10, TTL: time.Minute},
+		{Name: "reload-new", MaxBytes: 1 << 10},
+	}
+	n.reload(next)
+
+	if gocache.GetGroup("reload-drop") != nil || gocache.GetGroup("reload-new") == nil {
+		t.Fatalf("groups not reconciled: %v", gocache.ListGroups())
+	}
+	if gocache.GetGroup("reload-keep") != keep {
+		t.Fatal("existing group must be kept")
+	}
+	if v, err := keep.Get(context.Background(), "k"); err != nil || v.String() != "v" {
+		t.Fatalf("existing data lost: %q %v", v.String(), err)
+	}
+	if n.cfg.Addr != cfg.Addr {
+		t.Fatalf("addr change must not be applied, got %s", n.cfg.Addr)
+	}
+	if got := n.cfg.Groups[0]; got.MaxBytes != 2<<10 || got.TTL != 0 {
+		t.Fatalf("expect resize applied and ttl change ignored, got %+v", got)
+	}
+}
+
+func TestNode_EncryptionKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	os.WriteFile(path, []byte("# rotated monthly\nk1 MDEyMzQ1Njc4OWFiY2RlZg==\n"), 0o600)
+	cfg := defaultConfig()
+	cfg.Groups = []GroupConfig{{Name: "encrypted", MaxBytes: 1 << 10, Encryption: EncryptionConfig{KeyFile: path}}}
+	n, err := newNode(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { gocache.DestroyGroup("encrypted") })
+	g := gocache.GetGroup("encrypted")
+	g.Set(context.Background(), "k", []byte("v"))
+
+	// 追加新密钥并reload，旧值仍可读取
+	os.WriteFile(path, []byte("k1 MDEyMzQ1Njc4OWFiY2RlZg==\nk2 ZmVkY2JhOTg3NjU0MzIxMA==\n"), 0o600)
+	n.reload(cfg)
+	if id, _, _ := n.keyRings["encrypted"].CurrentKey(); id != "k2" {
+		t.Fatalf("expect rotated to k2, got %s", id)
+	}
+	if v, err := g.Get(context.Background(), "k"); err != nil || v.String() != "v" {
+		t.Fatalf("unexpected value %q %v", v.String(), err)
+	}
+
+	// 文件有误时保留原来的密钥
+	os.WriteFile(path, []byte("k1 not-base64\n"), 0o600)
+	n.reload(cfg)
+	if ids := n.keyRings["encrypted"].IDs(); len(ids) != 2 {
+		t.Fatalf("expect keys kept after invalid file, got %v", ids)
+	}
+
+	gocache.DestroyGroup("encrypted")
+	cfg.Groups[0].Encryption.KeyFile = filepath.Join(t.TempDir(), "missing")
+	if _, err := newNode(cfg); err == nil {
+		t.Fatal("expect error for missing key file")
+	}
+}
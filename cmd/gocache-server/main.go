@@ -0,0 +1,61 @@
+// gocache-server 按配置文件启动一个gocache节点。
+//
+// 配置文件支持YAML(.yaml/.yml)和TOML(.toml)，GOCACHE_前缀的环境变量覆盖配置文件中的值。
+// 收到SIGINT/SIGTERM时先从etcd注销再优雅退出，收到SIGHUP时重新加载配置。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	configPath := flag.String("config", "", "配置文件路径(.yaml/.yml/.toml)")
+	flag.Parse()
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gocache-server: %v\n", err)
+		os.Exit(2)
+	}
+	setupLogging(cfg.Log)
+
+	n, err := newNode(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gocache-server: %v\n", err)
+		os.Exit(1)
+	}
+	errCh, err := n.start()
+	if err != nil {
+		n.close()
+		fmt.Fprintf(os.Stderr, "gocache-server: %v\n", err)
+		os.Exit(1)
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for {
+		select {
+		case err := <-errCh:
+			n.logger.Error("server exited", "error", err)
+			n.shutdown()
+			os.Exit(1)
+		case sig := <-sigCh:
+			if sig == syscall.SIGHUP {
+				newCfg, err := LoadConfig(*configPath)
+				if err != nil {
+					n.logger.Error("reload config failed, keeping current config", "error", err)
+					continue
+				}
+				n.reload(newCfg)
+				continue
+			}
+			n.logger.Info("shutting down", "signal", sig.String())
+			n.shutdown()
+			return
+		}
+	}
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"gocache"
+	"gocache/logger"
+	"gocache/registry"
+	"gocache/store"
+	"gocache/tlsutil"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// missGetter 独立部署的节点没有回源数据，数据只能通过Set写入
+var missGetter = gocache.GetterFunc(func(key string) ([]byte, bool, time.Time) {
+	return nil, false, time.Time{}
+})
+
+// node 按配置组装的缓存节点
+type node struct {
+	mu       sync.Mutex
+	cfg      *Config
+	logger   *slog.Logger
+	server   *gocache.Server
+	picker   *gocache.ClientPicker
+	registry *registry.ServiceRegistry
+	groups   map[string]*gocache.Group
+	keyRings map[string]*gocache.KeyRing
+
+	regCancel    context.CancelFunc
+	unregistered chan struct{}
+}
+
+func newNode(cfg *Config) (*node, error) {
+	n := &node{
+		cfg:          cfg,
+		logger:       logger.New("gocache-server", nil),
+		groups:       make(map[string]*gocache.Group),
+		keyRings:     make(map[string]*gocache.KeyRing),
+		unregistered: make(chan struct{}, 1),
+	}
+
+	serverOpts := []gocache.ServerOptions{gocache.WithServerServiceName(cfg.Service)}
+	var clientOpts []gocache.ClientOption
+	if cfg.TLS.Enabled() {
+		reloader, err := tlsutil.NewReloader(&tlsutil.Config{
+			CertFile:          cfg.TLS.CertFile,
+			KeyFile:           cfg.TLS.KeyFile,
+			CAFile:            cfg.TLS.CAFile,
+			ServerName:        cfg.TLS.ServerName,
+			ClientAuth:        cfg.TLS.ClientAuth,
+			AllowedIdentities: cfg.TLS.AllowedIdentities,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("load tls config: %v", err)
+		}
+		serverOpts = append(serverOpts, gocache.WithServerTLS(reloader.ServerConfig()))
+		clientOpts = append(clientOpts, gocache.WithTLSFunc(reloader.ClientConfigFor))
+	}
+	if cfg.Metrics.Addr != "" {
+		serverOpts = append(serverOpts, gocache.WithMetricsAddr(cfg.Metrics.Addr))
+	}
+
+	if cfg.Discovery.Type == "etcd" {
+		etcdCfg := &registry.Config{
+			Endpoints:        cfg.Discovery.Etcd.Endpoints,
+			DialTimeout:      cfg.Discovery.Etcd.DialTimeout,
+			LeaseTTL:         cfg.Discovery.Etcd.LeaseTTL,
+			RetryInterval:    registry.DefaultConfig.RetryInterval,
+			MaxRetryInterval: registry.DefaultConfig.MaxRetryInterval,
+		}
+		picker, err := gocache.NewClientPicker(cfg.Addr,
+			gocache.WithServiceName(cfg.Service),
+			gocache.WithEtcdConfig(etcdCfg),
+			gocache.WithClientOptions(clientOpts...),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create peer picker: %v", err)
+		}
+		n.picker = picker
+
+		reg, err := registry.NewServiceRegistry(etcdCfg, registry.WithStateHandler(n.onRegistryState))
+		if err != nil {
+			picker.Close()
+			return nil, fmt.Errorf("create service registry: %v", err)
+		}
+		n.registry = reg
+	}
+	if cfg.Admin {
+		serverOpts = append(serverOpts, gocache.WithAdmin(n.picker), gocache.WithInsecureAdmin())
+	}
+
+	server, err := gocache.NewServer(cfg.Addr, serverOpts...)
+	if err != nil {
+		n.close()
+		return nil, err
+	}
+	n.server = server
+
+	for _, gc := range cfg.Groups {
+		if err := n.addGroup(gc); err != nil {
+			n.close()
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// onRegistryState 注销完成后通知shutdown
+func (n *node) onRegistryState(state registry.State) {
+	if state == registry.StateUnregistered {
+		select {
+		case n.unregistered <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// addGroup 按配置创建Group，配置已经过校验，只有读取密钥文件可能失败
+func (n *node) addGroup(gc GroupConfig) error {
+	policy, _ := parsePolicy(gc.Policy)
+	opts := []gocache.GroupOption{
+		gocache.WithCacheType(policy),
+		gocache.WithDefaultTTL(gc.TTL),
+	}
+	if gc.Snapshot.Path != "" {
+		opts = append(opts, gocache.WithSnapshot(gocache.SnapshotConfig{
+			Path:     gc.Snapshot.Path,
+			Interval: gc.Snapshot.Interval,
+		}))
+	}
+	if gc.AOF.Path != "" {
+		fsync, _ := gocache.ParseFsyncPolicy(gc.AOF.Fsync)
+		opts = append(opts, gocache.WithAOF(gocache.AOFConfig{
+			Path:           gc.AOF.Path,
+			Fsync:          fsync,
+			RewriteMinSize: int64(gc.AOF.RewriteMinSize),
+		}))
+	}
+	if gc.Disk.Dir != "" {
+		opts = append(opts, gocache.WithDiskTier(store.DiskOptions{
+			Dir:          gc.Disk.Dir,
+			MaxBytes:     int64(gc.Disk.MaxBytes),
+			SegmentBytes: int64(gc.Disk.SegmentBytes),
+		}))
+	}
+	if algorithm, _ := gocache.ParseCompression(gc.Compression.Algorithm); algorithm != gocache.CompressionNone {
+		opts = append(opts, gocache.WithCompression(gocache.CompressionConfig{
+			Algorithm: algorithm,
+			MinSize:   int(gc.Compression.MinSize),
+			MaxSize:   int(gc.Compression.MaxSize),
+		}))
+	}
+	if gc.Encryption.KeyFile != "" {
+		ring, err := loadKeys(gc.Encryption.KeyFile, nil)
+		if err != nil {
+			return fmt.Errorf("group %s: %v", gc.Name, err)
+		}
+		n.keyRings[gc.Name] = ring
+		opts = append(opts, gocache.WithEncryption(ring))
+	}
+	g := gocache.NewGroup(gc.Name, int64(gc.MaxBytes), missGetter, opts...)
+	if n.picker != nil {
+		g.RegisterPeers(n.picker)
+	}
+	n.groups[gc.Name] = g
+	n.logger.Info("group created", "group", gc.Name, "max_bytes", int64(gc.MaxBytes), "policy", policy, "ttl", gc.TTL)
+	return nil
+}
+
+type keyEntry struct {
+	id  string
+	key []byte
+}
+
+// readKeyFile 读取密钥文件，每行为"<ID> <base64编码的密钥>"，忽略空行和#开头的注释
+func readKeyFile(path string) ([]keyEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %v", err)
+	}
+	var entries []keyEntry
+	seen := make(map[string]bool)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; sc.Scan(); line++ {
+		s := strings.TrimSpace(sc.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		fields := strings.Fields(s)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("key file %s:%d: expect \"<id> <base64 key>\"", path, line)
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("key file %s:%d: %v", path, line, err)
+		}
+		if seen[fields[0]] {
+			return nil, fmt.Errorf("key file %s:%d: duplicate key %s", path, line, fields[0])
+		}
+		seen[fields[0]] = true
+		entries = append(entries, keyEntry{id: fields[0], key: key})
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("key file %s: no keys", path)
+	}
+	return entries, nil
+}
+
+// loadKeys 按密钥文件更新ring，ring为nil时新建。文件中最后一个密钥成为当前密钥，
+// 文件中已删除的密钥从ring中移除。文件有误时ring保持不变
+func loadKeys(path string, ring *gocache.KeyRing) (*gocache.KeyRing, error) {
+	entries, err := readKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// 先用临时的KeyRing校验所有密钥，避免更新到一半失败
+	check, err := gocache.NewKeyRing(entries[0].id, entries[0].key)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries[1:] {
+		if err := check.Rotate(e.id, e.key); err != nil {
+			return nil, err
+		}
+	}
+	if ring == nil {
+		return check, nil
+	}
+	for _, e := range entries {
+		if old, err := ring.Key(e.id); err == nil && !bytes.Equal(old, e.key) {
+			return nil, fmt.Errorf("key %s already exists with different value", e.id)
+		}
+	}
+	for _, e := range entries {
+		ring.Rotate(e.id, e.key)
+	}
+	for _, id := range ring.IDs() {
+		if _, err := check.Key(id); err != nil {
+			ring.Retire(id)
+		}
+	}
+	return ring, nil
+}
+
+// start 启动gRPC服务并注册到etcd，返回的channel在服务退出时收到错误
+func (n *node) start() (<-chan error, error) {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- n.server.Run()
+	}()
+	// 开始监听后再注册，其它节点解析到本节点时已经可以连接
+	select {
+	case <-n.server.Ready():
+	case err := <-errCh:
+		return nil, fmt.Errorf("start server: %v", err)
+	}
+
+	if n.registry != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		if err := n.registry.Register(ctx, n.cfg.Service, n.cfg.Addr); err != nil {
+			cancel()
+			n.server.Stop()
+			return nil, fmt.Errorf("register service: %v", err)
+		}
+		n.regCancel = cancel
+	}
+	n.logger.Info("node started", "addr", n.cfg.Addr, "service", n.cfg.Service, "discovery", n.cfg.Discovery.Type)
+	return errCh, nil
+}
+
+// shutdown 先从etcd注销使其它节点不再路由过来，再等待进行中的请求完成
+func (n *node) shutdown() {
+	timeout := n.cfg.ShutdownTimeout
+	deadline := time.After(timeout)
+
+	if n.regCancel != nil {
+		n.regCancel()
+		select {
+		case <-n.unregistered:
+		case <-deadline:
+			n.logger.Warn("timed out waiting for deregistration")
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		n.server.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-deadline:
+		n.logger.Warn("graceful shutdown timed out, exiting", "timeout", timeout)
+	}
+	n.close()
+}
+
+func (n *node) close() {
+	if n.picker != nil {
+		if err := n.picker.Close(); err != nil {
+			n.logger.Warn("close peer picker failed", "error", err)
+		}
+	}
+	if n.registry != nil {
+		if err := n.registry.Close(); err != nil {
+			n.logger.Warn("close service registry failed", "error", err)
+		}
+	}
+}
+
+// reload 应用新配置中可在线生效的部分：Group的增删和容量、日志级别。
+// 其它字段变化需要重启才能生效
+func (n *node) reload(cfg *Config) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	old := n.cfg
+	for _, field := range restartRequired(old, cfg) {
+		n.logger.Warn("config change requires restart, ignored", "field", field)
+	}
+
+	setupLevels(cfg.Log, old.Log)
+
+	want := make(map[string]GroupConfig, len(cfg.Groups))
+	for _, gc := range cfg.Groups {
+		want[gc.Name] = gc
+	}
+	prev := make(map[string]GroupConfig, len(old.Groups))
+	for _, gc := range old.Groups {
+		prev[gc.Name] = gc
+	}
+
+	for name := range n.groups {
+		if _, ok := want[name]; !ok {
+			gocache.DestroyGroup(name)
+			delete(n.groups, name)
+			delete(n.keyRings, name)
+			n.logger.Info("group removed", "group", name)
+		}
+	}
+	for name, gc := range want {
+		g, ok := n.groups[name]
+		if !ok {
+			if err := n.addGroup(gc); err != nil {
+				n.logger.Warn("add group failed", "group", name, "error", err)
+				delete(want, name)
+			}
+			continue
+		}
+		p := prev[name]
+		if p.MaxBytes != gc.MaxBytes {
+			if _, err := g.Resize(int64(gc.MaxBytes)); err != nil {
+				n.logger.Warn("resize group failed", "group", name, "error", err)
+				gc.MaxBytes = p.MaxBytes
+			}
+		}
+		if p.TTL != gc.TTL || p.Policy != gc.Policy || p.Snapshot != gc.Snapshot || p.AOF != gc.AOF || p.Disk != gc.Disk ||
+			p.Compression != gc.Compression || p.Encryption != gc.Encryption {
+			n.logger.Warn("group ttl, policy, persistence, compression and encryption changes require restart, ignored", "group", name)
+			gc.TTL, gc.Policy, gc.Snapshot, gc.AOF, gc.Disk, gc.Compression, gc.Encryption = p.TTL, p.Policy, p.Snapshot, p.AOF, p.Disk, p.Compression, p.Encryption
+		}
+		// 重新读取密钥文件以轮换密钥
+		if ring := n.keyRings[name]; ring != nil {
+			if _, err := loadKeys(gc.Encryption.KeyFile, ring); err != nil {
+				n.logger.Warn("reload encryption keys failed", "group", name, "error", err)
+			} else {
+				n.logger.Info("encryption keys reloaded", "group", name, "keys", len(ring.IDs()))
+			}
+		}
+		want[name] = gc
+	}
+
+	// 记录实际生效的配置，下次reload以此为基准比较
+	applied := *old
+	applied.Log = cfg.Log
+	applied.Groups = make([]GroupConfig, 0, len(want))
+	for _, gc := range cfg.Groups {
+		if gc, ok := want[gc.Name]; ok {
+			applied.Groups = append(applied.Groups, gc)
+		}
+	}
+	n.cfg = &applied
+	n.logger.Info("config reloaded", "groups", len(applied.Groups))
+}
+
+// restartRequired 返回变化后需要重启才能生效的字段
+func restartRequired(old, cfg *Config) []string {
+	var fields []string
+	check := func(name string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			fields = append(fields, name)
+		}
+	}
+	check("addr", old.Addr, cfg.Addr)
+	check("service", old.Service, cfg.Service)
+	check("admin", old.Admin, cfg.Admin)
+	check("admin_insecure", old.AdminInsecure, cfg.AdminInsecure)
+	check("shutdown_timeout", old.ShutdownTimeout, cfg.ShutdownTimeout)
+	check("discovery", old.Discovery, cfg.Discovery)
+	check("tls", old.TLS, cfg.TLS)
+	check("metrics", old.Metrics, cfg.Metrics)
+	check("log.format", old.Log.Format, cfg.Log.Format)
+	return fields
+}
+
+// setupLogging 设置默认logger，级别由logger包按组件过滤
+func setupLogging(cfg LogConfig) {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var h slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if cfg.Format == "json" {
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(h))
+	setupLevels(cfg, LogConfig{})
+}
+
+// setupLevels 设置默认和各组件的日志级别，old中有而cfg中没有的组件恢复为默认级别
+func setupLevels(cfg, old LogConfig) {
+	level, _ := parseLevel(cfg.Level)
+	logger.SetLevel("", level)
+	for component := range old.Levels {
+		if _, ok := cfg.Levels[component]; !ok {
+			logger.SetLevel(component, level)
+		}
+	}
+	for component, s := range cfg.Levels {
+		l, _ := parseLevel(s)
+		logger.SetLevel(component, l)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	pb "gocache/pb"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// keyValue 单个key的读取结果
+type keyValue struct {
+	Key      string `json:"key"`
+	Found    bool   `json:"found"`
+	Value    string `json:"value,omitempty"`
+	Encoding string `json:"encoding,omitempty"` // value不是合法UTF-8时为base64
+	raw      []byte
+}
+
+func newKeyValue(key string, raw []byte) keyValue {
+	kv := keyValue{Key: key, Found: true, raw: raw}
+	if utf8.Valid(raw) {
+		kv.Value = string(raw)
+	} else {
+		kv.Value, kv.Encoding = base64.StdEncoding.EncodeToString(raw), "base64"
+	}
+	return kv
+}
+
+func runGet(ctx context.Context, c *ctl, args []string) error {
+	if len(args) != 1 {
+		return usagef("get requires exactly one key")
+	}
+	resp, err := c.cache.Get(ctx, &pb.Request{Group: c.opts.group, Key: args[0]})
+	if status.Code(err) == codes.NotFound {
+		if err := c.out.value(keyValue{Key: args[0]}); err != nil {
+			return err
+		}
+		return errNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("get %s: %v", args[0], err)
+	}
+	return c.out.value(newKeyValue(args[0], resp.GetValue()))
+}
+
+func runSet(ctx context.Context, c *ctl, args []string) error {
+	if len(args) != 2 {
+		return usagef("set requires a key and a value")
+	}
+	val := []byte(args[1])
+	if args[1] == "-" {
+		var err error
+		if val, err = io.ReadAll(c.stdin); err != nil {
+			return fmt.Errorf("read value from stdin: %v", err)
+		}
+	}
+	if _, err := c.cache.Set(ctx, &pb.Request{Group: c.opts.group, Key: args[0], Value: val}); err != nil {
+		return fmt.Errorf("set %s: %v", args[0], err)
+	}
+	return c.out.result(map[string]interface{}{"key": args[0], "ok": true}, "OK")
+}
+
+func runDel(ctx context.Context, c *ctl, args []string) error {
+	if len(args) != 1 {
+		return usagef("del requires exactly one key")
+	}
+	resp, err := c.cache.Delete(ctx, &pb.Request{Group: c.opts.group, Key: args[0]})
+	if err != nil {
+		return fmt.Errorf("del %s: %v", args[0], err)
+	}
+	text := "deleted"
+	if !resp.GetValue() {
+		text = "not found"
+	}
+	if err := c.out.result(map[string]interface{}{"key": args[0], "deleted": resp.GetValue()}, text); err != nil {
+		return err
+	}
+	if !resp.GetValue() {
+		return errNotFound
+	}
+	return nil
+}
+
+// runMGet 并发读取多个key，任一key不存在时以exitNotFound退出
+func runMGet(ctx context.Context, c *ctl, args []string) error {
+	if len(args) == 0 {
+		return usagef("mget requires at least one key")
+	}
+	results := make([]keyValue, len(args))
+	errs := make([]error, len(args))
+	var wg sync.WaitGroup
+	for i, key := range args {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			results[i] = keyValue{Key: key}
+			resp, err := c.cache.Get(ctx, &pb.Request{Group: c.opts.group, Key: key})
+			switch {
+			case status.Code(err) == codes.NotFound:
+			case err != nil:
+				errs[i] = fmt.Errorf("get %s: %v", key, err)
+			default:
+				results[i] = newKeyValue(key, resp.GetValue())
+			}
+		}(i, key)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	if err := c.out.values(results); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if !r.Found {
+			return errNotFound
+		}
+	}
+	return nil
+}
+
+func runStats(ctx context.Context, c *ctl, args []string) error {
+	if len(args) != 0 {
+		return usagef("stats takes no arguments")
+	}
+	resp, err := c.cache.Stats(ctx, &pb.StatsRequest{Group: c.opts.group})
+	if err != nil {
+		return fmt.Errorf("stats: %v", err)
+	}
+	hitRate := 0.0
+	if resp.GetHits()+resp.GetMisses() > 0 {
+		hitRate = float64(resp.GetHits()) / float64(resp.GetHits()+resp.GetMisses())
+	}
+	compressionRatio := 0.0
+	if resp.GetCompressionOutBytes() > 0 {
+		compressionRatio = float64(resp.GetCompressionInBytes()) / float64(resp.GetCompressionOutBytes())
+	}
+	us := func(v int64) string { return (time.Duration(v) * time.Microsecond).String() }
+	rows := [][]string{
+		{"group", resp.GetGroup()},
+		{"gets", fmt.Sprint(resp.GetGets())},
+		{"hits", fmt.Sprint(resp.GetHits())},
+		{"misses", fmt.Sprint(resp.GetMisses())},
+		{"hit_rate", fmt.Sprintf("%.4f", hitRate)},
+		{"dedups", fmt.Sprint(resp.GetDedups())},
+		{"stale_hits", fmt.Sprint(resp.GetStaleHits())},
+		{"refreshes", fmt.Sprint(resp.GetRefreshes())},
+		{"peer_loads", fmt.Sprint(resp.GetPeerLoads())},
+		{"peer_errors", fmt.Sprint(resp.GetPeerErrors())},
+		{"local_loads", fmt.Sprint(resp.GetLocalLoads())},
+		{"local_load_errors", fmt.Sprint(resp.GetLocalLoadErrors())},
+		{"load_latency_p50", us(resp.GetLoadLatencyP50Us())},
+		{"load_latency_p90", us(resp.GetLoadLatencyP90Us())},
+		{"load_latency_p99", us(resp.GetLoadLatencyP99Us())},
+		{"bytes", fmt.Sprint(resp.GetBytes())},
+		{"items", fmt.Sprint(resp.GetItems())},
+		{"evictions", fmt.Sprint(resp.GetEvictions())},
+		{"expirations", fmt.Sprint(resp.GetExpirations())},
+		{"compression_ratio", fmt.Sprintf("%.2f", compressionRatio)},
+	}
+	return c.out.table(resp, []string{"FIELD", "VALUE"}, rows)
+}
+
+func runGroups(ctx context.Context, c *ctl, args []string) error {
+	if len(args) != 0 {
+		return usagef("groups takes no arguments")
+	}
+	resp, err := c.admin.ListGroups(ctx, &pb.AdminRequest{})
+	if err != nil {
+		return fmt.Errorf("groups: %v", err)
+	}
+	rows := make([][]string, 0, len(resp.GetGroups()))
+	for _, name := range resp.GetGroups() {
+		rows = append(rows, []string{name})
+	}
+	return c.out.table(resp.GetGroups(), []string{"GROUP"}, rows)
+}
+
+func runRing(ctx context.Context, c *ctl, args []string) error {
+	if len(args) != 0 {
+		return usagef("ring takes no arguments")
+	}
+	resp, err := c.admin.Ring(ctx, &pb.AdminRequest{})
+	if err != nil {
+		return fmt.Errorf("ring: %v", err)
+	}
+	rows := make([][]string, 0, len(resp.GetNodes()))
+	for _, node := range resp.GetNodes() {
+		rows = append(rows, []string{node.GetAddr(), fmt.Sprint(node.GetReplicas()), fmt.Sprintf("%.4f", node.GetLoadRatio())})
+	}
+	return c.out.table(resp, []string{"ADDR", "REPLICAS", "LOAD"}, rows)
+}
+
+func runPeers(ctx context.Context, c *ctl, args []string) error {
+	if len(args) != 0 {
+		return usagef("peers takes no arguments")
+	}
+	resp, err := c.admin.Peers(ctx, &pb.AdminRequest{})
+	if err != nil {
+		return fmt.Errorf("peers: %v", err)
+	}
+	rows := [][]string{{resp.GetSelf(), "self", "-"}}
+	for _, peer := range resp.GetPeers() {
+		rows = append(rows, []string{peer.GetAddr(), fmt.Sprint(peer.GetHealthy()), peer.GetBreakerState()})
+	}
+	return c.out.table(resp, []string{"ADDR", "HEALTHY", "BREAKER"}, rows)
+}
+
+func runFlush(ctx context.Context, c *ctl, args []string) error {
+	if len(args) != 0 {
+		return usagef("flush takes no arguments")
+	}
+	resp, err := c.admin.Flush(ctx, &pb.AdminRequest{Group: c.opts.group})
+	if err != nil {
+		return fmt.Errorf("flush: %v", err)
+	}
+	return c.out.result(map[string]interface{}{"group": c.opts.group, "removed": resp.GetRemoved()},
+		fmt.Sprintf("removed %d entries", resp.GetRemoved()))
+}
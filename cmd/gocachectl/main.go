@@ -0,0 +1,288 @@
+// gocachectl 是访问gocache节点的命令行工具，可以直连节点或通过etcd发现节点。
+//
+// 退出码:
+//
+//	0 成功
+//	1 key不存在
+//	2 参数错误
+//	3 请求失败(连接失败、鉴权失败、服务端错误等)
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"gocache/auth"
+	pb "gocache/pb"
+	"gocache/registry"
+	"gocache/tlsutil"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	exitOK       = 0
+	exitNotFound = 1
+	exitUsage    = 2
+	exitError    = 3
+)
+
+// errNotFound 命令执行成功但key不存在
+var errNotFound = errors.New("not found")
+
+// usageError 参数错误
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...interface{}) error {
+	return usageError{fmt.Sprintf(format, args...)}
+}
+
+// options 全局参数
+type options struct {
+	addr          string
+	etcd          string
+	service       string
+	group         string
+	output        string
+	timeout       time.Duration
+	token         string
+	tlsEnabled    bool
+	tlsCA         string
+	tlsCert       string
+	tlsKey        string
+	tlsServerName string
+}
+
+// command 子命令
+type command struct {
+	name string
+	args string
+	help string
+	run  func(ctx context.Context, c *ctl, args []string) error
+}
+
+var commands = []command{
+	{name: "get", args: "<key>", help: "读取key并输出value", run: runGet},
+	{name: "set", args: "<key> <value|->", help: "写入key，value为-时从标准输入读取", run: runSet},
+	{name: "del", args: "<key>", help: "删除key", run: runDel},
+	{name: "mget", args: "<key>...", help: "批量读取多个key", run: runMGet},
+	{name: "stats", args: "", help: "输出group的统计信息", run: runStats},
+	{name: "groups", args: "", help: "列出节点上的所有group", run: runGroups},
+	{name: "ring", args: "", help: "输出节点视角的哈希环", run: runRing},
+	{name: "peers", args: "", help: "输出节点的peer及健康状态", run: runPeers},
+	{name: "flush", args: "", help: "清空节点上group的缓存", run: runFlush},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("gocachectl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var opts options
+	fs.StringVar(&opts.addr, "addr", "", "节点地址host:port；与-etcd同时使用时只连接该实例")
+	fs.StringVar(&opts.etcd, "etcd", "", "etcd地址，多个用逗号分隔，未指定-addr时从etcd发现节点")
+	fs.StringVar(&opts.service, "service", "lcache", "etcd中注册的服务名")
+	fs.StringVar(&opts.group, "group", "default", "group名称")
+	fs.StringVar(&opts.output, "o", "table", "输出格式: table或json")
+	fs.DurationVar(&opts.timeout, "timeout", 5*time.Second, "请求超时时间")
+	fs.StringVar(&opts.token, "token", "", "bearer token")
+	fs.BoolVar(&opts.tlsEnabled, "tls", false, "使用TLS连接，指定-tls-ca或-tls-cert时自动开启")
+	fs.StringVar(&opts.tlsCA, "tls-ca", "", "校验服务端证书的CA文件")
+	fs.StringVar(&opts.tlsCert, "tls-cert", "", "客户端证书文件(mTLS)")
+	fs.StringVar(&opts.tlsKey, "tls-key", "", "客户端私钥文件(mTLS)")
+	fs.StringVar(&opts.tlsServerName, "tls-server-name", "", "校验服务端证书时使用的主机名")
+	fs.Usage = func() { printUsage(fs) }
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		printUsage(fs)
+		return exitUsage
+	}
+
+	cmd := lookupCommand(fs.Arg(0))
+	if cmd == nil {
+		fmt.Fprintf(stderr, "gocachectl: unknown command %q\n", fs.Arg(0))
+		printUsage(fs)
+		return exitUsage
+	}
+	if opts.output != "table" && opts.output != "json" {
+		fmt.Fprintf(stderr, "gocachectl: unknown output format %q\n", opts.output)
+		return exitUsage
+	}
+	if opts.addr == "" && opts.etcd == "" {
+		fmt.Fprintln(stderr, "gocachectl: one of -addr or -etcd is required")
+		return exitUsage
+	}
+
+	c, err := dial(&opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "gocachectl: %v\n", err)
+		return exitError
+	}
+	defer c.close()
+	c.stdin, c.out = stdin, newPrinter(stdout, opts.output)
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+	return exitCode(cmd.run(ctx, c, fs.Args()[1:]), stderr)
+}
+
+// exitCode 输出错误并返回对应的退出码
+func exitCode(err error, stderr io.Writer) int {
+	var ue usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errNotFound):
+		return exitNotFound
+	case errors.As(err, &ue):
+		fmt.Fprintf(stderr, "gocachectl: %v\n", err)
+		return exitUsage
+	default:
+		fmt.Fprintf(stderr, "gocachectl: %v\n", err)
+		return exitError
+	}
+}
+
+func lookupCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func printUsage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintln(w, "usage: gocachectl [flags] <command> [args]")
+	fmt.Fprintln(w, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-7s %-16s %s\n", cmd.name, cmd.args, cmd.help)
+	}
+	fmt.Fprintln(w, "\nflags:")
+	fs.PrintDefaults()
+	fmt.Fprintln(w, "\nexit codes: 0 ok, 1 key not found, 2 usage error, 3 request failed")
+}
+
+// ctl 持有到节点的连接
+type ctl struct {
+	opts    *options
+	conn    *grpc.ClientConn
+	etcdCli *clientv3.Client
+	cache   pb.GoCacheClient
+	admin   pb.AdminClient
+	stdin   io.Reader
+	out     *printer
+}
+
+// dial 直连节点，或通过etcd解析节点地址
+func dial(opts *options) (*ctl, error) {
+	dialOpts, err := dialOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &ctl{opts: opts}
+	if opts.etcd != "" {
+		c.etcdCli, err = clientv3.New(clientv3.Config{
+			Endpoints:   strings.Split(opts.etcd, ","),
+			DialTimeout: registry.DefaultConfig.DialTimeout,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create etcd client: %v", err)
+		}
+		c.conn, err = registry.EtcdDial(c.etcdCli, opts.service, opts.addr, dialOpts...)
+	} else {
+		c.conn, err = grpc.NewClient(opts.addr, dialOpts...)
+	}
+	if err != nil {
+		c.close()
+		return nil, fmt.Errorf("failed to dial: %v", err)
+	}
+	c.cache = pb.NewGoCacheClient(c.conn)
+	c.admin = pb.NewAdminClient(c.conn)
+	return c, nil
+}
+
+func (c *ctl) close() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	if c.etcdCli != nil {
+		c.etcdCli.Close()
+	}
+}
+
+// dialOptions 根据参数构造传输层凭证和鉴权凭证
+func dialOptions(opts *options) ([]grpc.DialOption, error) {
+	var dialOpts []grpc.DialOption
+	tlsConfig, err := clientTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	if opts.token != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(auth.TokenCredentials{
+			Token:         opts.token,
+			AllowInsecure: tlsConfig == nil,
+		}))
+	}
+	return dialOpts, nil
+}
+
+// clientTLSConfig 未开启TLS时返回nil
+func clientTLSConfig(opts *options) (*tls.Config, error) {
+	if opts.tlsCert != "" || opts.tlsKey != "" {
+		reloader, err := tlsutil.NewReloader(&tlsutil.Config{
+			CertFile:   opts.tlsCert,
+			KeyFile:    opts.tlsKey,
+			CAFile:     opts.tlsCA,
+			ServerName: opts.tlsServerName,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls config: %v", err)
+		}
+		return reloader.ClientConfigFor(opts.addr), nil
+	}
+	if !opts.tlsEnabled && opts.tlsCA == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{ServerName: opts.tlsServerName, MinVersion: tls.VersionTLS12}
+	if opts.tlsCA != "" {
+		pem, err := os.ReadFile(opts.tlsCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", opts.tlsCA)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
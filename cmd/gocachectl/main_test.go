@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gocache"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServer 在随机端口上启动带admin服务的节点
+func startServer(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	gocache.NewGroup("ctl-test", 1<<10, gocache.GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		if key == "db" {
+			return []byte("from-db"), true, time.Time{}
+		}
+		return nil, false, time.Time{}
+	}))
+	server, err := gocache.NewServer(addr, gocache.WithAdmin(nil), gocache.WithInsecureAdmin())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Run()
+	t.Cleanup(func() {
+		server.Stop()
+		gocache.DestroyGroup("ctl-test")
+	})
+
+	for i := 0; i < 50; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			return addr
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("server %s not ready", addr)
+	return ""
+}
+
+func runCtl(addr, stdin string, args ...string) (int, string) {
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-addr", addr, "-group", "ctl-test"}, args...)
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String()
+}
+
+func TestCommands(t *testing.T) {
+	addr := startServer(t)
+
+	tests := []struct {
+		args  []string
+		stdin string
+		code  int
+		out   string
+	}{
+		{args: []string{"set", "k", "v"}, code: exitOK, out: "OK\n"},
+		{args: []string{"set", "piped", "-"}, stdin: "from stdin", code: exitOK, out: "OK\n"},
+		{args: []string{"get", "k"}, code: exitOK, out: "v\n"},
+		{args: []string{"get", "piped"}, code: exitOK, out: "from stdin\n"},
+		{args: []string{"get", "db"}, code: exitOK, out: "from-db\n"},
+		{args: []string{"get", "missing"}, code: exitNotFound, out: ""},
+		{args: []string{"-o", "json", "get", "missing"}, code: exitNotFound, out: `{"key":"missing","found":false}` + "\n"},
+		{args: []string{"mget", "k", "missing"}, code: exitNotFound, out: "KEY      VALUE\nk        v\nmissing  (nil)\n"},
+		{args: []string{"del", "k"}, code: exitOK, out: "deleted\n"},
+		{args: []string{"del", "k"}, code: exitNotFound, out: "not found\n"},
+		{args: []string{"groups"}, code: exitOK, out: "GROUP\nctl-test\n"},
+		{args: []string{"ring"}, code: exitError},
+		{args: []string{"get"}, code: exitUsage},
+		{args: []string{"unknown"}, code: exitUsage},
+	}
+	for _, tt := range tests {
+		code, out := runCtl(addr, tt.stdin, tt.args...)
+		if code != tt.code {
+			t.Fatalf("%v: expect exit code %d, got %d", tt.args, tt.code, code)
+		}
+		if tt.out != "" && out != tt.out {
+			t.Fatalf("%v: expect output %q, got %q", tt.args, tt.out, out)
+		}
+	}
+
+	code, out := runCtl(addr, "", "-o", "json", "stats")
+	var stats map[string]interface{}
+	if code != exitOK || json.Unmarshal([]byte(out), &stats) != nil {
+		t.Fatalf("unexpected stats output %d %q", code, out)
+	}
+	if stats["group"] != "ctl-test" || stats["items"] != fmt.Sprint(2) {
+		t.Fatalf("unexpected stats %v", stats)
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	code, _ := runCtl("127.0.0.1:1", "", "-timeout", "500ms", "get", "k")
+	if code != exitError {
+		t.Fatalf("expect exit code %d, got %d", exitError, code)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// printer 按table或json格式输出结果
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func newPrinter(w io.Writer, format string) *printer {
+	return &printer{w: w, json: format == "json"}
+}
+
+// value 输出单个value，table格式直接输出原始字节便于脚本使用，key不存在时不输出
+func (p *printer) value(kv keyValue) error {
+	if p.json {
+		return p.encode(kv)
+	}
+	if !kv.Found {
+		return nil
+	}
+	if _, err := p.w.Write(kv.raw); err != nil {
+		return err
+	}
+	_, err := io.WriteString(p.w, "\n")
+	return err
+}
+
+// values 输出多个key的读取结果
+func (p *printer) values(kvs []keyValue) error {
+	if p.json {
+		return p.encode(kvs)
+	}
+	rows := make([][]string, 0, len(kvs))
+	for _, kv := range kvs {
+		v := "(nil)"
+		if kv.Found {
+			v = kv.Value
+		}
+		rows = append(rows, []string{kv.Key, v})
+	}
+	return p.writeTable([]string{"KEY", "VALUE"}, rows)
+}
+
+// result 输出单行结果，table格式只输出text
+func (p *printer) result(v interface{}, text string) error {
+	if p.json {
+		return p.encode(v)
+	}
+	_, err := fmt.Fprintln(p.w, text)
+	return err
+}
+
+// table 输出表格，json格式时输出v
+func (p *printer) table(v interface{}, header []string, rows [][]string) error {
+	if p.json {
+		return p.encode(v)
+	}
+	return p.writeTable(header, rows)
+}
+
+func (p *printer) writeTable(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// encode protobuf消息使用proto字段名输出，其它值使用encoding/json
+func (p *printer) encode(v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		b, err := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(m)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(p.w, string(b))
+		return err
+	}
+	return json.NewEncoder(p.w).Encode(v)
+}
@@ -0,0 +1,180 @@
+package gocache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression 值的压缩算法
+type Compression string
+
+const (
+	CompressionNone   Compression = ""
+	CompressionGzip   Compression = "gzip"
+	CompressionZstd   Compression = "zstd"
+	CompressionSnappy Compression = "snappy"
+)
+
+const (
+	defaultCompressMinSize = 256
+	defaultCompressMaxSize = 64 << 20
+)
+
+// CompressionConfig Group的压缩配置
+type CompressionConfig struct {
+	Algorithm Compression
+	MinSize   int // 小于该字节数的值不压缩，默认256
+	// MaxSize 大于该字节数的值不压缩，解压结果超过MaxSize的条目视为损坏，默认64MB
+	MaxSize int
+}
+
+// WithCompression 写入缓存前压缩值，压缩后的大小计入cacheBytes，读取值的内容时再解压。
+// 压缩后没有变小的值按原样保存
+func WithCompression(cfg CompressionConfig) GroupOption {
+	return func(g *Group) {
+		if cfg.MinSize <= 0 {
+			cfg.MinSize = defaultCompressMinSize
+		}
+		if cfg.MaxSize <= 0 {
+			cfg.MaxSize = defaultCompressMaxSize
+		}
+		g.mainCache.compression = cfg
+	}
+}
+
+// ParseCompression 解析压缩算法名称，空字符串和none表示不压缩
+func ParseCompression(s string) (Compression, error) {
+	switch z := Compression(strings.ToLower(s)); z {
+	case "none":
+		return CompressionNone, nil
+	case CompressionNone, CompressionGzip, CompressionZstd, CompressionSnappy:
+		return z, nil
+	default:
+		return "", fmt.Errorf("unknown compression %q", s)
+	}
+}
+
+var (
+	gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+	// EncodeAll和DecodeAll可以并发调用，第一次使用时创建
+	zstdEncoder = sync.OnceValue(func() *zstd.Encoder {
+		e, _ := zstd.NewWriter(nil)
+		return e
+	})
+	// zstdDecoders 按解压大小上限缓存的解码器
+	zstdDecoders sync.Map
+)
+
+// zstdDecoder 返回解压结果最多limit字节的解码器
+func zstdDecoder(limit int) *zstd.Decoder {
+	if d, ok := zstdDecoders.Load(limit); ok {
+		return d.(*zstd.Decoder)
+	}
+	d, _ := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(limit)))
+	if actual, loaded := zstdDecoders.LoadOrStore(limit, d); loaded {
+		d.Close()
+		return actual.(*zstd.Decoder)
+	}
+	return d
+}
+
+// compress 用算法z压缩b
+func compress(z Compression, b []byte) ([]byte, error) {
+	switch z {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzipWriters.Get().(*gzip.Writer)
+		defer gzipWriters.Put(w)
+		w.Reset(&buf)
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		return zstdEncoder().EncodeAll(b, nil), nil
+	case CompressionSnappy:
+		return snappy.Encode(nil, b), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %q", z)
+	}
+}
+
+// decompress 解压用算法z压缩的b，解压结果超过limit字节时返回错误
+func decompress(z Compression, b []byte, limit int) ([]byte, error) {
+	var out []byte
+	switch z {
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		if out, err = io.ReadAll(io.LimitReader(r, int64(limit)+1)); err != nil {
+			return nil, err
+		}
+	case CompressionZstd:
+		var err error
+		if out, err = zstdDecoder(limit).DecodeAll(b, nil); err != nil {
+			return nil, err
+		}
+	case CompressionSnappy:
+		n, err := snappy.DecodedLen(b)
+		if err != nil {
+			return nil, err
+		}
+		if n > limit {
+			return nil, fmt.Errorf("decompressed value exceeds %d bytes", limit)
+		}
+		if out, err = snappy.Decode(nil, b); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown compression %q", z)
+	}
+	if len(out) > limit {
+		return nil, fmt.Errorf("decompressed value exceeds %d bytes", limit)
+	}
+	return out, nil
+}
+
+// maxDecompressedSize 返回解压结果的大小上限，未开启压缩的Group使用默认值
+func (cache *cache) maxDecompressedSize() int {
+	if cache.compression.MaxSize > 0 {
+		return cache.compression.MaxSize
+	}
+	return defaultCompressMaxSize
+}
+
+// maxDecompressedSize 返回从peer读取group的值时解压结果的大小上限，本地没有该Group时使用默认值
+func maxDecompressedSize(group string) int {
+	if g := GetGroup(group); g != nil {
+		return g.mainCache.maxDecompressedSize()
+	}
+	return defaultCompressMaxSize
+}
+
+// compress 按Group的配置压缩值，不在MinSize和MaxSize之间、已经压缩或加密、压缩后没有变小时原样返回
+func (cache *cache) compress(value ByteView) ByteView {
+	cfg := cache.compression
+	if cfg.Algorithm == CompressionNone || value.z != CompressionNone || value.x || len(value.b) < cfg.MinSize || len(value.b) > cache.maxDecompressedSize() {
+		return value
+	}
+	cache.compressIn.Add(int64(len(value.b)))
+	b, err := compress(cfg.Algorithm, value.b)
+	if err != nil || len(b) >= len(value.b) {
+		cache.compressOut.Add(int64(len(value.b)))
+		return value
+	}
+	cache.compressOut.Add(int64(len(b)))
+	value.b, value.z = b, cfg.Algorithm
+	return value
+}
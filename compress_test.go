@@ -0,0 +1,149 @@
+package gocache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	pb "gocache/pb"
+	"strings"
+	"testing"
+)
+
+// jsonValue 返回一个压缩率较高的JSON值
+func jsonValue(n int) []byte {
+	var b strings.Builder
+	b.WriteString("[")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"id":%d,"name":"user-%d","active":true,"tags":["a","b"]}`, i, i%10)
+	}
+	b.WriteString("]")
+	return []byte(b.String())
+}
+
+func TestCompression_Algorithms(t *testing.T) {
+	value := jsonValue(100)
+	for _, z := range []Compression{CompressionGzip, CompressionZstd, CompressionSnappy} {
+		b, err := compress(z, value)
+		if err != nil || len(b) >= len(value) {
+			t.Fatalf("%s: compress %d -> %d bytes, %v", z, len(value), len(b), err)
+		}
+		if got, err := decompress(z, b, len(value)); err != nil || !bytes.Equal(got, value) {
+			t.Fatalf("%s: round trip failed: %v", z, err)
+		}
+		if _, err := decompress(z, b, len(value)-1); err == nil {
+			t.Fatalf("%s: expect error when exceeding the limit", z)
+		}
+		if _, err := decompress(z, []byte("garbage"), len(value)); err == nil {
+			t.Fatalf("%s: expect error for invalid data", z)
+		}
+	}
+
+	for s, want := range map[string]Compression{"": CompressionNone, "none": CompressionNone, "ZSTD": CompressionZstd} {
+		if got, err := ParseCompression(s); err != nil || got != want {
+			t.Errorf("ParseCompression(%q) = %q %v, want %q", s, got, err, want)
+		}
+	}
+	if _, err := ParseCompression("lz4"); err == nil {
+		t.Error("expect error for unknown algorithm")
+	}
+}
+
+func TestCompression_Group(t *testing.T) {
+	g := NewGroup("compressed", 1<<20, missGetter(), WithCompression(CompressionConfig{Algorithm: CompressionZstd}))
+	t.Cleanup(func() { DestroyGroup("compressed") })
+	ctx := context.Background()
+
+	value := jsonValue(100)
+	g.Set(ctx, "big", value)
+	g.Set(ctx, "small", []byte("tiny"))
+	g.Write(ctx, "big", []byte("]"), WriteOptions{Mode: WriteAppend})
+
+	v, err := g.Get(ctx, "big")
+	if err != nil || v.Compression() != CompressionZstd || v.String() != string(value)+"]" {
+		t.Fatalf("expect compressed value decompressed on read, got %q %v", v.Compression(), err)
+	}
+	if v.Len() >= len(value) || !bytes.Equal(v.ByteSlice(), []byte(v.String())) {
+		t.Fatalf("expect compressed size counted, got %d", v.Len())
+	}
+	if v, _ := g.Get(ctx, "small"); v.Compression() != CompressionNone || v.String() != "tiny" {
+		t.Fatal("expect values below MinSize stored as is")
+	}
+
+	stats := g.Stats()
+	if stats.Bytes >= int64(len(value)) || stats.CompressionRatio() < 5 {
+		t.Fatalf("unexpected stats %+v, ratio %.2f", stats, stats.CompressionRatio())
+	}
+
+	// 客户端声明能解压时才发送压缩的值
+	s, err := NewServer("localhost:9999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := s.Get(ctx, &pb.Request{Group: "compressed", Key: "big", AcceptCompressed: true})
+	if err != nil || resp.GetCompression() != "zstd" || len(resp.GetValue()) != v.Len() {
+		t.Fatalf("expect compressed value on the wire, got %q %d bytes %v", resp.GetCompression(), len(resp.GetValue()), err)
+	}
+	resp, err = s.Get(ctx, &pb.Request{Group: "compressed", Key: "big"})
+	if err != nil || resp.GetCompression() != "" || string(resp.GetValue()) != v.String() {
+		t.Fatalf("expect raw value for old clients, got %q %v", resp.GetCompression(), err)
+	}
+}
+
+func TestCompression_Corrupt(t *testing.T) {
+	g := NewGroup("compressed-corrupt", 1<<20, missGetter(), WithCompression(CompressionConfig{Algorithm: CompressionZstd, MaxSize: 1 << 10}))
+	t.Cleanup(func() { DestroyGroup("compressed-corrupt") })
+	ctx := context.Background()
+	g.Set(ctx, "k", []byte("v"))
+
+	// 无法解压或解压后超过MaxSize的条目被删除并视为未命中
+	b, _ := compress(CompressionZstd, make([]byte, 2<<10))
+	g.mainCache.lruCache.Set("corrupt", ByteView{b: []byte("garbage"), z: CompressionZstd})
+	g.mainCache.lruCache.Set("bomb", ByteView{b: b, z: CompressionZstd})
+	for _, key := range []string{"corrupt", "bomb"} {
+		if _, err := g.getPlain(ctx, key); err != ErrNotFound {
+			t.Fatalf("%s: expect miss, got %v", key, err)
+		}
+		if _, ok := g.mainCache.lruCache.Get(key); ok {
+			t.Fatalf("%s: expect entry evicted", key)
+		}
+	}
+
+	// 读取时不解压，需要原始数据时才解压
+	g.Set(ctx, "lazy", jsonValue(10))
+	v, err := g.Get(ctx, "lazy")
+	if err != nil || v.Compression() != CompressionZstd || v.u.b != nil {
+		t.Fatalf("expect value left compressed until read, got %q %v", v.Compression(), err)
+	}
+	if v.String() != string(jsonValue(10)) || v.u.b == nil {
+		t.Fatal("expect value decompressed on read")
+	}
+
+	// 超过MaxSize的值不压缩
+	g.Set(ctx, "big", jsonValue(100))
+	if v, err := g.Get(ctx, "big"); err != nil || v.Compression() != CompressionNone {
+		t.Fatalf("expect values above MaxSize stored as is, got %q %v", v.Compression(), err)
+	}
+}
+
+func TestCompression_Persistence(t *testing.T) {
+	path := t.TempDir() + "/compressed.snap"
+	ctx := context.Background()
+	opts := []GroupOption{WithCompression(CompressionConfig{Algorithm: CompressionGzip}), WithSnapshot(SnapshotConfig{Path: path})}
+	g := NewGroup("compressed-snap", 1<<20, missGetter(), opts...)
+	t.Cleanup(func() { DestroyGroup("compressed-snap") })
+	value := jsonValue(50)
+	g.Set(ctx, "k", value)
+	if err := g.SaveSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 快照中保存原始数据，不开启压缩的Group也能加载
+	DestroyGroup("compressed-snap")
+	g = NewGroup("compressed-snap", 1<<20, missGetter(), WithSnapshot(SnapshotConfig{Path: path}))
+	if v, err := g.Get(ctx, "k"); err != nil || v.Compression() != CompressionNone || v.String() != string(value) {
+		t.Fatalf("unexpected value loaded from snapshot: %v", err)
+	}
+}
@@ -17,7 +17,7 @@ type Map struct {
 	keys []int                  // 哈希环
 	hashMap map[int]string	    // 哈希环到节点的映射
 	nodeReplicas map[string]int // 节点到虚拟节点数量的映射
-	nodeCounts map[string]int64 // 节点负载统计
+	nodeCounts map[string]*int64 // 节点负载统计，Get在读锁下原子累加
 	totalRequests int64			// 总请求数
 }
 
@@ -27,7 +27,7 @@ func New(opts ...Option) *Map {
 		config:       DefaultConfig,
 		hashMap:      make(map[int]string),
 		nodeReplicas: make(map[string]int),
-		nodeCounts:   make(map[string]int64),
+		nodeCounts:   make(map[string]*int64),
 	}
 
 	for _, opt := range opts {
@@ -77,6 +77,11 @@ func (m *Map) Remove(node string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	return m.removeNode(node)
+}
+
+// removeNode 移除节点的所有虚拟节点，调用方需持有写锁
+func (m *Map) removeNode(node string) error {
 	replicas := m.nodeReplicas[node]
 	if replicas == 0 {
 		return fmt.Errorf("node %s not found", node)
@@ -124,13 +129,43 @@ func (m *Map) Get(key string) string {
 	}
 
 	node := m.hashMap[m.keys[idx]]
-	count := m.nodeCounts[node]
-	m.nodeCounts[node] = count + 1
+	if count, ok := m.nodeCounts[node]; ok {
+		atomic.AddInt64(count, 1)
+	}
 	atomic.AddInt64(&m.totalRequests, 1)
 
 	return node
 }
 
+// GetN 按哈希环顺时针方向返回key之后最多n个不同的节点，第一个与Get相同，不计入负载统计
+func (m *Map) GetN(key string, n int) []string {
+	if key == "" || n <= 0 {
+		return nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if n > len(m.nodeReplicas) {
+		n = len(m.nodeReplicas)
+	}
+	hash := int(m.config.HashFunc([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool {
+		return m.keys[i] >= hash
+	})
+
+	nodes := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for i := 0; i < len(m.keys) && len(nodes) < n; i++ {
+		node := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if !seen[node] {
+			seen[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
 // addNode 添加节点的虚拟节点
 func (m *Map) addNode(node string, replicas int) {
 	for i := 0; i < replicas; i++ {
@@ -139,6 +174,9 @@ func (m *Map) addNode(node string, replicas int) {
 		m.hashMap[hash] = node
 	}
 	m.nodeReplicas[node] = replicas
+	if _, ok := m.nodeCounts[node]; !ok {
+		m.nodeCounts[node] = new(int64)
+	}
 }
 
 // checkAndRebalance 检查并重新平衡虚拟节点
@@ -148,15 +186,21 @@ func (m *Map) checkAndRebalance() {
 	}
 
 	// 计算负载情况
-	avgLoad := float64(m.totalRequests) / float64(len(m.nodeReplicas))
+	m.mu.RLock()
+	if len(m.nodeReplicas) == 0 {
+		m.mu.RUnlock()
+		return
+	}
+	avgLoad := float64(atomic.LoadInt64(&m.totalRequests)) / float64(len(m.nodeReplicas))
 	var maxDiff float64
 
 	for _, count := range m.nodeCounts {
-		diff := math.Abs(float64(count) - avgLoad)
+		diff := math.Abs(float64(atomic.LoadInt64(count)) - avgLoad)
 		if diff/avgLoad > maxDiff {
 			maxDiff = diff / avgLoad
 		}
 	}
+	m.mu.RUnlock()
 
 	// 如果负载不均衡度超过阈值，调整虚拟节点
 	if maxDiff > m.config.LoadBalanceThreshold {
@@ -164,17 +208,67 @@ func (m *Map) checkAndRebalance() {
 	}
 }
 
+// Rebalance 立即按当前负载统计重新平衡虚拟节点，没有请求统计时不做调整
+func (m *Map) Rebalance() {
+	if atomic.LoadInt64(&m.totalRequests) == 0 {
+		return
+	}
+	m.rebalanceNodes()
+}
+
+// PlanRebalance 返回按当前负载统计重新平衡后每个节点的虚拟节点数，不修改哈希环
+func (m *Map) PlanRebalance() map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	nodes := make(map[string]int, len(m.nodeReplicas))
+	for node, replicas := range m.nodeReplicas {
+		nodes[node] = replicas
+	}
+	if atomic.LoadInt64(&m.totalRequests) == 0 {
+		return nodes
+	}
+	for node, replicas := range m.plan() {
+		nodes[node] = replicas
+	}
+	return nodes
+}
+
 // rebalanceNodes 重新平衡节点
 func (m *Map) rebalanceNodes() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	avgLoad := float64(m.totalRequests) / float64(len(m.nodeReplicas))
+	// 先计算每个节点新的虚拟节点数量，避免遍历时修改map
+	adjust := m.plan()
+
+	// 重新添加节点的虚拟节点
+	for node, replicas := range adjust {
+		if err := m.removeNode(node); err != nil {
+			continue // 如果移除失败，跳过这个节点
+		}
+		m.addNode(node, replicas)
+	}
+
+	// 重置计数器
+	for _, count := range m.nodeCounts {
+		atomic.StoreInt64(count, 0)
+	}
+	atomic.StoreInt64(&m.totalRequests, 0)
+	sort.Ints(m.keys)
+}
+
+// plan 按负载计算需要调整的节点及其新的虚拟节点数，调用方需持有锁
+func (m *Map) plan() map[string]int {
+	adjust := make(map[string]int)
+	if len(m.nodeReplicas) == 0 {
+		return adjust
+	}
+	avgLoad := float64(atomic.LoadInt64(&m.totalRequests)) / float64(len(m.nodeReplicas))
 
-	// 调整每个节点的虚拟节点数量
 	for node, count := range m.nodeCounts {
 		currentReplicas := m.nodeReplicas[node]
-		loadRatio := float64(count) / avgLoad
+		loadRatio := float64(atomic.LoadInt64(count)) / avgLoad
 
 		var newReplicas int
 		if loadRatio > 1 {
@@ -194,20 +288,10 @@ func (m *Map) rebalanceNodes() {
 		}
 
 		if newReplicas != currentReplicas {
-			// 重新添加节点的虚拟节点
-			if err := m.Remove(node); err != nil {
-				continue // 如果移除失败，跳过这个节点
-			}
-			m.addNode(node, newReplicas)
+			adjust[node] = newReplicas
 		}
 	}
-
-	// 重置计数器
-	for node := range m.nodeCounts {
-		m.nodeCounts[node] = 0
-	}
-	atomic.StoreInt64(&m.totalRequests, 0)
-	sort.Ints(m.keys)
+	return adjust
 }
 
 // GetStats 获取负载统计信息
@@ -222,11 +306,23 @@ func (m *Map) GetStats() map[string]float64 {
 	}
 
 	for node, count := range m.nodeCounts {
-		stats[node] = float64(count) / float64(total)
+		stats[node] = float64(atomic.LoadInt64(count)) / float64(total)
 	}
 	return stats
 }
 
+// Nodes 返回每个节点当前的虚拟节点数
+func (m *Map) Nodes() map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	nodes := make(map[string]int, len(m.nodeReplicas))
+	for node, replicas := range m.nodeReplicas {
+		nodes[node] = replicas
+	}
+	return nodes
+}
+
 // 将checkAndRebalance移到单独的goroutine中
 func (m *Map) startBalancer() {
 	go func() {
@@ -1,24 +1,27 @@
 package consistenthash
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 	"testing"
 )
 
-func TestHashing(t *testing.T) {
-	hashFunc := func(data []byte) uint32 {
-		s := string(data)
-		if idx := strings.Index(s, "-"); idx != -1 {
-			nodePart := s[:idx]
-			replicaPart := s[idx+1:]
-			nodeVal, _ := strconv.Atoi(nodePart)
-			replicaVal, _ := strconv.Atoi(replicaPart)
-			return uint32(nodeVal + replicaVal*10)
-		}
-		val, _ := strconv.Atoi(s)
-		return uint32(val)
+// hashFunc 节点n的第i个虚拟节点的哈希值为n+10*i，key的哈希值为其数值
+func hashFunc(data []byte) uint32 {
+	s := string(data)
+	if idx := strings.Index(s, "-"); idx != -1 {
+		nodePart := s[:idx]
+		replicaPart := s[idx+1:]
+		nodeVal, _ := strconv.Atoi(nodePart)
+		replicaVal, _ := strconv.Atoi(replicaPart)
+		return uint32(nodeVal + replicaVal*10)
 	}
+	val, _ := strconv.Atoi(s)
+	return uint32(val)
+}
+
+func TestHashing(t *testing.T) {
 	cfg := &Config{
 		HashFunc:        hashFunc,
 		DefaultReplicas: 3,
@@ -45,4 +48,75 @@ func TestHashing(t *testing.T) {
 			t.Errorf("Asking for %s, should have yielded %s, but got %s", k, v, node)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestGetN(t *testing.T) {
+	hash := New(WithConfig(&Config{HashFunc: hashFunc, DefaultReplicas: 3, MinReplicas: 1, MaxReplicas: 10}))
+	hash.Add("6", "4", "2")
+	testCases := map[string][]string{
+		"11": {"2", "4", "6"},
+		"23": {"4", "6", "2"},
+		"27": {"2", "4", "6"},
+	}
+	for k, v := range testCases {
+		if nodes := hash.GetN(k, 5); fmt.Sprint(nodes) != fmt.Sprint(v) {
+			t.Errorf("Asking for %s, should have yielded %v, but got %v", k, v, nodes)
+		}
+	}
+	if nodes := hash.GetN("23", 1); len(nodes) != 1 || nodes[0] != hash.Get("23") {
+		t.Errorf("expect first node to match Get, got %v", nodes)
+	}
+}
+
+func TestRebalance(t *testing.T) {
+	hash := New(WithConfig(&Config{
+		HashFunc:        DefaultConfig.HashFunc,
+		DefaultReplicas: 50,
+		MinReplicas:     10,
+		MaxReplicas:     200,
+	}))
+	hash.Add("a", "b", "c")
+
+	// 并发Get不应导致map并发写
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		go func(i int) {
+			for j := 0; j < 500; j++ {
+				hash.Get(strconv.Itoa(i*1000 + j))
+			}
+			done <- struct{}{}
+		}(i)
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+
+	before := hash.Nodes()
+	plan := hash.PlanRebalance()
+	if fmt.Sprint(hash.Nodes()) != fmt.Sprint(before) {
+		t.Fatal("plan should not change the ring")
+	}
+	hash.Rebalance()
+	after := hash.Nodes()
+	if fmt.Sprint(after) != fmt.Sprint(plan) {
+		t.Fatalf("expect ring to match plan %v, got %v", plan, after)
+	}
+	if len(after) != 3 {
+		t.Fatalf("expect 3 nodes after rebalance, got %v", after)
+	}
+	changed := false
+	for node, replicas := range after {
+		if replicas < 10 || replicas > 200 {
+			t.Fatalf("replicas of %s out of range: %d", node, replicas)
+		}
+		if replicas != before[node] {
+			changed = true
+		}
+	}
+	if !changed {
+		t.Fatalf("expect replicas to change, before %v after %v", before, after)
+	}
+	if len(hash.GetStats()) != 0 {
+		t.Fatal("stats should be reset after rebalance")
+	}
+}
@@ -0,0 +1,184 @@
+package gocache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// KeyProvider 提供加密值使用的AES密钥，密钥为16、24或32字节。同一ID对应的密钥不能改变
+type KeyProvider interface {
+	// CurrentKey 返回加密新值使用的密钥及其ID，轮换密钥后返回新的ID
+	CurrentKey() (id string, key []byte, err error)
+	// Key 按ID返回密钥，用于解密旧值。轮换后旧密钥需要保留到用它加密的条目都被覆盖或过期
+	Key(id string) ([]byte, error)
+}
+
+// WithEncryption 在存储中以AES-GCM加密保存值，快照、AOF和磁盘层中同样只有密文。
+// 读取时用写入时的密钥ID解密，密钥无法获取或解密失败的条目视为不存在
+func WithEncryption(keys KeyProvider) GroupOption {
+	return func(g *Group) {
+		g.mainCache.keys = keys
+	}
+}
+
+// KeyRing 内存中的密钥集合，实现KeyProvider，可以在运行时轮换
+type KeyRing struct {
+	mu      sync.RWMutex
+	current string
+	keys    map[string][]byte
+}
+
+// NewKeyRing 创建只有一个密钥的KeyRing，该密钥为当前密钥
+func NewKeyRing(id string, key []byte) (*KeyRing, error) {
+	r := &KeyRing{keys: make(map[string][]byte)}
+	if err := r.Rotate(id, key); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Rotate 添加密钥并设为当前密钥，之后写入的值使用该密钥加密。id已存在时密钥必须相同
+func (r *KeyRing) Rotate(id string, key []byte) error {
+	if id == "" {
+		return fmt.Errorf("key id is required")
+	}
+	if _, err := aes.NewCipher(key); err != nil {
+		return fmt.Errorf("key %s: %v", id, err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.keys[id]; ok && !bytes.Equal(old, key) {
+		return fmt.Errorf("key %s already exists with different value", id)
+	}
+	r.keys[id] = bytes.Clone(key)
+	r.current = id
+	return nil
+}
+
+// Retire 删除不再使用的旧密钥，不能删除当前密钥
+func (r *KeyRing) Retire(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id == r.current {
+		return fmt.Errorf("cannot retire current key %s", id)
+	}
+	delete(r.keys, id)
+	return nil
+}
+
+// IDs 返回所有密钥的ID，包括当前密钥
+func (r *KeyRing) IDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]string, 0, len(r.keys))
+	for id := range r.keys {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// CurrentKey 实现KeyProvider接口
+func (r *KeyRing) CurrentKey() (string, []byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current, r.keys[r.current], nil
+}
+
+// Key 实现KeyProvider接口
+func (r *KeyRing) Key(id string) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %s", id)
+	}
+	return key, nil
+}
+
+// aead 返回密钥对应的AES-GCM，按ID和密钥缓存，密钥被删除后不会再用于解密
+func (cache *cache) aead(id string, key []byte) (cipher.AEAD, error) {
+	name := id + "\x00" + string(key)
+	if a, ok := cache.aeads.Load(name); ok {
+		return a.(cipher.AEAD), nil
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	a, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	cache.aeads.Store(name, a)
+	return a, nil
+}
+
+// seal 用当前密钥加密值，key作为附加数据，避免密文被挪到其它key下。
+// 密文格式：密钥ID、随机nonce和加密后的压缩算法与数据
+func (cache *cache) seal(key string, value ByteView) (ByteView, error) {
+	if cache.keys == nil || value.x {
+		return value, nil
+	}
+	id, k, err := cache.keys.CurrentKey()
+	if err != nil {
+		return value, fmt.Errorf("get current key: %v", err)
+	}
+	a, err := cache.aead(id, k)
+	if err != nil {
+		return value, fmt.Errorf("key %s: %v", id, err)
+	}
+	plain := appendBytes(nil, []byte(value.z))
+	plain = append(plain, value.b...)
+
+	b := appendBytes(nil, []byte(id))
+	nonce := make([]byte, a.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return value, err
+	}
+	b = append(b, nonce...)
+	value.b, value.z, value.x = a.Seal(b, nonce, plain, []byte(key)), CompressionNone, true
+	return value, nil
+}
+
+// open 解密seal加密的值，返回的值可能仍是压缩的
+func (cache *cache) open(key string, value ByteView) (ByteView, error) {
+	if !value.x {
+		return value, nil
+	}
+	if cache.keys == nil {
+		return value, fmt.Errorf("encryption is not enabled")
+	}
+	r := bytes.NewReader(value.b)
+	id, err := readBytes(r)
+	if err != nil {
+		return value, fmt.Errorf("invalid envelope: %v", err)
+	}
+	k, err := cache.keys.Key(string(id))
+	if err != nil {
+		return value, err
+	}
+	a, err := cache.aead(string(id), k)
+	if err != nil {
+		return value, fmt.Errorf("key %s: %v", id, err)
+	}
+	rest := value.b[len(value.b)-r.Len():]
+	if len(rest) < a.NonceSize() {
+		return value, fmt.Errorf("invalid envelope")
+	}
+	plain, err := a.Open(nil, rest[:a.NonceSize()], rest[a.NonceSize():], []byte(key))
+	if err != nil {
+		return value, err
+	}
+	n, size := binary.Uvarint(plain)
+	if size <= 0 || uint64(len(plain)-size) < n {
+		return value, fmt.Errorf("invalid envelope")
+	}
+	value.z = Compression(plain[size : size+int(n)])
+	value.b, value.x = plain[size+int(n):], false
+	return value, nil
+}
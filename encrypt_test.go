@@ -0,0 +1,119 @@
+package gocache
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newKeyRing(t *testing.T, id string, key string) *KeyRing {
+	t.Helper()
+	r, err := NewKeyRing(id, []byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestEncryption_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	snap, aof := filepath.Join(dir, "enc.snap"), filepath.Join(dir, "enc.aof")
+	ctx := context.Background()
+	keys := newKeyRing(t, "k1", "0123456789abcdef")
+	secret := append([]byte("secret:"), jsonValue(20)...)
+	opts := []GroupOption{
+		WithEncryption(keys),
+		WithCompression(CompressionConfig{Algorithm: CompressionZstd}),
+		WithSnapshot(SnapshotConfig{Path: snap}),
+		WithAOF(AOFConfig{Path: aof, Fsync: FsyncAlways}),
+	}
+	g := NewGroup("encrypted", 1<<20, missGetter(), opts...)
+	t.Cleanup(func() { DestroyGroup("encrypted") })
+
+	g.Write(ctx, "k", secret, WriteOptions{Flags: 3})
+	v, err := g.Get(ctx, "k")
+	if err != nil || !bytes.Equal(v.ByteSlice(), secret) || v.Flags() != 3 || v.Compression() != CompressionZstd {
+		t.Fatalf("unexpected value %q %v", v.String(), err)
+	}
+	raw, _ := g.mainCache.lruCache.Get("k")
+	if view := raw.(ByteView); !view.x || bytes.Contains(view.b, []byte("secret")) {
+		t.Fatal("expect ciphertext in store")
+	}
+	if err := g.SaveSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	g.Write(ctx, "k", []byte(":more"), WriteOptions{Mode: WriteAppend})
+	for _, path := range []string{snap, aof} {
+		if data, _ := os.ReadFile(path); len(data) == 0 || bytes.Contains(data, []byte("secret")) {
+			t.Fatalf("%s: expect only ciphertext on disk", path)
+		}
+	}
+
+	// 快照和AOF中的密文在重启后仍可读取
+	DestroyGroup("encrypted")
+	g = NewGroup("encrypted", 1<<20, missGetter(), opts...)
+	if v, err := g.Get(ctx, "k"); err != nil || v.String() != string(secret)+":more" || v.Flags() != 3 {
+		t.Fatalf("unexpected value after restart %q %v", v.String(), err)
+	}
+
+	// 没有密钥时密文被丢弃
+	DestroyGroup("encrypted")
+	g = NewGroup("encrypted", 1<<20, missGetter(), WithSnapshot(SnapshotConfig{Path: snap}))
+	if _, err := g.Get(ctx, "k"); err == nil {
+		t.Fatal("expect encrypted entries skipped without keys")
+	}
+}
+
+func TestEncryption_Rotation(t *testing.T) {
+	keys := newKeyRing(t, "k1", "0123456789abcdef")
+	g := NewGroup("rotation", 1<<20, missGetter(), WithEncryption(keys))
+	t.Cleanup(func() { DestroyGroup("rotation") })
+	ctx := context.Background()
+
+	g.Set(ctx, "old", []byte("v1"))
+	if err := keys.Rotate("k2", bytes.Repeat([]byte("k"), 32)); err != nil {
+		t.Fatal(err)
+	}
+	g.Set(ctx, "new", []byte("v2"))
+	for key, want := range map[string]string{"old": "v1", "new": "v2"} {
+		if v, err := g.Get(ctx, key); err != nil || v.String() != want {
+			t.Fatalf("%s: expect %s after rotation, got %q %v", key, want, v.String(), err)
+		}
+	}
+
+	// 密文绑定了key，挪到其它key下无法解密
+	raw, _ := g.mainCache.lruCache.Get("new")
+	g.mainCache.lruCache.Set("moved", raw)
+	if _, err := g.Get(ctx, "moved"); err == nil {
+		t.Fatal("expect ciphertext rejected under another key")
+	}
+
+	if err := keys.Retire("k2"); err == nil {
+		t.Fatal("expect error retiring current key")
+	}
+	keys.Retire("k1")
+	if _, err := g.Get(ctx, "old"); err == nil {
+		t.Fatal("expect values under retired key to miss")
+	}
+	if v, err := g.Get(ctx, "new"); err != nil || v.String() != "v2" {
+		t.Fatalf("unexpected value %q %v", v.String(), err)
+	}
+}
+
+func TestKeyRing_Invalid(t *testing.T) {
+	if _, err := NewKeyRing("", []byte("0123456789abcdef")); err == nil {
+		t.Fatal("expect error for empty id")
+	}
+	if _, err := NewKeyRing("k1", []byte("short")); err == nil {
+		t.Fatal("expect error for invalid key size")
+	}
+	keys := newKeyRing(t, "k1", "0123456789abcdef")
+	if err := keys.Rotate("k1", []byte("fedcba9876543210")); err == nil {
+		t.Fatal("expect error changing existing key")
+	}
+	if id, _, _ := keys.CurrentKey(); id != "k1" {
+		t.Fatalf("expect current key unchanged, got %s", id)
+	}
+}
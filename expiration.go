@@ -0,0 +1,46 @@
+package gocache
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// ExpirationConfig 避免大量key同时过期的配置，只作用于从Getter加载的条目
+type ExpirationConfig struct {
+	// Jitter 取值0~1，写入时把TTL随机缩短最多该比例，批量加载的key不会在同一时刻过期
+	Jitter float64
+	// Beta 大于0时开启XFetch提前刷新：条目越接近过期、上次加载越慢，越可能在过期前被后台刷新。
+	// 通常为1，越大刷新越早
+	Beta float64
+}
+
+// WithEarlyExpiration 为从Getter加载的条目开启TTL抖动和概率提前刷新，避免同时过期的key一起回源
+func WithEarlyExpiration(cfg ExpirationConfig) GroupOption {
+	return func(g *Group) {
+		g.mainCache.jitter = min(max(cfg.Jitter, 0), 1)
+		g.expiration = cfg
+	}
+}
+
+// jitterTTL 按配置随机缩短ttl，ttl<=0时原样返回。缩短后不小于floor，用于保证硬过期不早于软过期，
+// 陈旧的值在硬过期前仍可读取
+func (cache *cache) jitterTTL(ttl, floor time.Duration) time.Duration {
+	if cache.jitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	d := max(ttl-time.Duration(rand.Float64()*cache.jitter*float64(ttl)), floor)
+	if d > 0 {
+		return min(d, ttl)
+	}
+	return ttl
+}
+
+// expireEarly 按XFetch判断是否提前刷新：now - d*beta*ln(rand) >= 过期时间，d为上次加载的耗时
+func (g *Group) expireEarly(value ByteView, now time.Time) bool {
+	if g.expiration.Beta <= 0 || value.d <= 0 || value.e.IsZero() {
+		return false
+	}
+	gap := -float64(value.d) * g.expiration.Beta * math.Log(1-rand.Float64())
+	return !now.Add(time.Duration(gap)).Before(value.e)
+}
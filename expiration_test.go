@@ -0,0 +1,91 @@
+package gocache
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExpiration_Jitter(t *testing.T) {
+	expire := time.Now().Add(time.Hour)
+	g := NewGroup("jitter", 1<<20, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		if key[0] == 'e' {
+			return []byte("v"), true, expire
+		}
+		return []byte("v"), true, time.Time{}
+	}), WithDefaultTTL(time.Hour), WithEarlyExpiration(ExpirationConfig{Jitter: 0.5}))
+	t.Cleanup(func() { DestroyGroup("jitter") })
+	ctx := context.Background()
+
+	// 默认TTL和Getter返回的过期时间都被随机提前
+	for _, prefix := range []string{"d", "e"} {
+		seen := make(map[time.Time]bool)
+		for i := 0; i < 20; i++ {
+			v, _ := g.Get(ctx, fmt.Sprintf("%s%d", prefix, i))
+			if ttl := v.TTL(); ttl < 29*time.Minute || ttl > time.Hour {
+				t.Fatalf("%s: ttl %v out of jitter range", prefix, ttl)
+			}
+			seen[v.Expire()] = true
+		}
+		if len(seen) < 10 {
+			t.Fatalf("%s: expect spread expiration times, got %d distinct", prefix, len(seen))
+		}
+	}
+
+	// 直接写入的值保持指定的TTL
+	g.SetWithTTL(ctx, "set", []byte("v"), time.Hour)
+	if v, _ := g.Get(ctx, "set"); v.TTL() < 59*time.Minute {
+		t.Fatalf("expect written ttl kept, got %v", v.TTL())
+	}
+}
+
+func TestExpiration_JitterKeepsStaleWindow(t *testing.T) {
+	g := NewGroup("jitter-swr", 1<<20, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		return []byte("v"), true, time.Time{}
+	}), WithEarlyExpiration(ExpirationConfig{Jitter: 1}),
+		WithStaleWhileRevalidate(RevalidateConfig{SoftTTL: 50 * time.Minute, HardTTL: time.Hour}))
+	t.Cleanup(func() { DestroyGroup("jitter-swr") })
+	ctx := context.Background()
+
+	// 抖动后的硬过期时间不早于软过期时间
+	for i := 0; i < 20; i++ {
+		v, _ := g.Get(ctx, fmt.Sprintf("k%d", i))
+		if v.Expire().Before(v.s) {
+			t.Fatalf("expect hard expiry after soft expiry, got %v before %v", v.Expire(), v.s)
+		}
+	}
+}
+
+func TestExpiration_XFetch(t *testing.T) {
+	newGroup := func(name string, beta float64) (*Group, *atomic.Int64) {
+		var loads atomic.Int64
+		g := NewGroup(name, 1<<20, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+			time.Sleep(time.Millisecond)
+			return []byte(fmt.Sprintf("v%d", loads.Add(1))), true, time.Time{}
+		}), WithDefaultTTL(time.Second), WithEarlyExpiration(ExpirationConfig{Beta: beta}))
+		t.Cleanup(func() { DestroyGroup(name) })
+		return g, &loads
+	}
+	ctx := context.Background()
+
+	// beta很大时加载耗时被放大到远超TTL，命中几乎必然触发提前刷新
+	g, loads := newGroup("xfetch", 1e6)
+	g.Get(ctx, "k")
+	waitFor(t, func() bool {
+		v, _ := g.Get(ctx, "k")
+		return v.String() != "v1"
+	})
+	if g.Stats().Refreshes == 0 || loads.Load() < 2 {
+		t.Fatalf("expect early refresh, got %+v", g.Stats())
+	}
+
+	g, loads = newGroup("xfetch-off", 0)
+	for i := 0; i < 100; i++ {
+		g.Get(ctx, "k")
+	}
+	if loads.Load() != 1 || g.Stats().Refreshes != 0 {
+		t.Fatalf("expect no early refresh without beta, got %d loads", loads.Load())
+	}
+}
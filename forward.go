@@ -0,0 +1,61 @@
+package gocache
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// headerForwarded 标记请求由peer转发而来。各节点的哈希环可能暂时不一致，
+// 收到转发请求的节点只在本地处理，不会再次转发，避免请求在节点间循环
+const headerForwarded = "x-gocache-forwarded"
+
+// headerReadOnly 标记只读的转发请求，如对冲请求发往不负责该key的副本，副本回源后不写入本地缓存，
+// 否则owner上之后的写入和删除无法让这份副本失效
+const headerReadOnly = "x-gocache-read-only"
+
+type (
+	forwardedKey struct{}
+	readOnlyKey  struct{}
+)
+
+// asPeer ClientPicker创建的Client在请求中带上转发标记
+func asPeer() ClientOption {
+	return func(c *Client) {
+		c.dialOpts = append(c.dialOpts, grpc.WithChainUnaryInterceptor(forwardingClientInterceptor))
+	}
+}
+
+// forwardingClientInterceptor 在发往peer的请求metadata中写入转发标记
+func forwardingClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(metadata.AppendToOutgoingContext(ctx, headerForwarded, "1"), method, req, reply, cc, opts...)
+}
+
+// forwardingServerInterceptor 识别peer转发的请求和只读请求，在ctx中记录
+func forwardingServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if len(metadata.ValueFromIncomingContext(ctx, headerForwarded)) > 0 {
+		ctx = context.WithValue(ctx, forwardedKey{}, true)
+		if len(metadata.ValueFromIncomingContext(ctx, headerReadOnly)) > 0 {
+			ctx = context.WithValue(ctx, readOnlyKey{}, true)
+		}
+	}
+	return handler(ctx, req)
+}
+
+// withReadOnly 在发往peer的请求metadata中写入只读标记
+func withReadOnly(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, headerReadOnly, "1")
+}
+
+// isForwarded 判断请求是否由peer转发而来
+func isForwarded(ctx context.Context) bool {
+	forwarded, _ := ctx.Value(forwardedKey{}).(bool)
+	return forwarded
+}
+
+// isReadOnly 判断请求是否为只读的转发请求，回源的结果不写入本地缓存
+func isReadOnly(ctx context.Context) bool {
+	readOnly, _ := ctx.Value(readOnlyKey{}).(bool)
+	return readOnly
+}
@@ -0,0 +1,103 @@
+package gocache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// remotePicker 把所有key都分配给peer
+type remotePicker struct {
+	peer Peer
+}
+
+func (p remotePicker) PickPeer(key string) (Peer, bool, bool) { return p.peer, true, false }
+func (p remotePicker) Close() error                           { return nil }
+
+// countingPeer 统计收到的请求数，Get总是返回"remote"
+type countingPeer struct {
+	calls atomic.Int64
+}
+
+func (p *countingPeer) Get(ctx context.Context, group, key string) (ByteView, error) {
+	p.calls.Add(1)
+	return ByteView{b: []byte("remote")}, nil
+}
+
+func (p *countingPeer) Write(ctx context.Context, group, key string, value []byte, opts WriteOptions) (uint64, error) {
+	p.calls.Add(1)
+	return 1, nil
+}
+
+func (p *countingPeer) Delete(ctx context.Context, group, key string) (bool, error) {
+	p.calls.Add(1)
+	return true, nil
+}
+
+func (p *countingPeer) Close() error { return nil }
+
+func TestForwarded_NotForwardedAgain(t *testing.T) {
+	g := NewGroup("forwarded", 1<<10, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		return []byte("local"), true, time.Time{}
+	}))
+	t.Cleanup(func() { DestroyGroup("forwarded") })
+	peer := &countingPeer{}
+	g.RegisterPeers(remotePicker{peer: peer})
+
+	// 服务端拦截器根据metadata标记转发请求
+	incoming := metadata.NewIncomingContext(context.Background(), metadata.Pairs(headerForwarded, "1"))
+	var ctx context.Context
+	forwardingServerInterceptor(incoming, nil, &grpc.UnaryServerInfo{}, func(c context.Context, req interface{}) (interface{}, error) {
+		ctx = c
+		return nil, nil
+	})
+	if !isForwarded(ctx) || isForwarded(context.Background()) {
+		t.Fatal("expect only requests with the marker treated as forwarded")
+	}
+
+	if v, err := g.Get(ctx, "k"); err != nil || v.String() != "local" {
+		t.Fatalf("expect forwarded get loaded locally, got %q %v", v.String(), err)
+	}
+	g.Set(ctx, "w", []byte("v"))
+	g.Delete(ctx, "w")
+	if n := peer.calls.Load(); n != 0 {
+		t.Fatalf("expect forwarded requests handled locally, got %d peer calls", n)
+	}
+
+	if v, _ := g.Get(context.Background(), "other"); v.String() != "remote" || peer.calls.Load() != 1 {
+		t.Fatalf("expect other requests routed to the owner, got %q", v.String())
+	}
+}
+
+func TestForwarded_ReadOnly(t *testing.T) {
+	var loads atomic.Int64
+	g := NewGroup("read-only", 1<<10, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		loads.Add(1)
+		return []byte("local"), true, time.Time{}
+	}))
+	t.Cleanup(func() { DestroyGroup("read-only") })
+
+	incoming := metadata.NewIncomingContext(context.Background(), metadata.Pairs(headerForwarded, "1", headerReadOnly, "1"))
+	var ctx context.Context
+	forwardingServerInterceptor(incoming, nil, &grpc.UnaryServerInfo{}, func(c context.Context, req interface{}) (interface{}, error) {
+		ctx = c
+		return nil, nil
+	})
+	if !isReadOnly(ctx) {
+		t.Fatal("expect read-only marker recorded")
+	}
+
+	// 只读请求回源后不写入缓存
+	for i := 0; i < 2; i++ {
+		if v, err := g.Get(ctx, "k"); err != nil || v.String() != "local" {
+			t.Fatalf("unexpected value %q %v", v.String(), err)
+		}
+	}
+	if n, items := loads.Load(), g.Stats().Items; n != 2 || items != 0 {
+		t.Fatalf("expect read-only loads not cached, got %d loads %d items", n, items)
+	}
+}
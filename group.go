@@ -1,11 +1,19 @@
 package gocache
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"gocache/logger"
 	"gocache/singleflight"
-	"log"
+	"gocache/store"
+	"log/slog"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -13,12 +21,209 @@ var (
 	groups = make(map[string]*Group)
 )
 
+// defaultLoadTimeout 一次加载的默认超时时间
+const defaultLoadTimeout = 5 * time.Second
+
+var (
+	// ErrNotFound Getter中不存在该key
+	ErrNotFound = errors.New("data not found")
+	// ErrNotStored 条件写入的条件不满足，如Add时key已存在、Replace时key不存在
+	ErrNotStored = errors.New("item not stored")
+	// ErrCASConflict CAS写入时条目已被修改
+	ErrCASConflict = errors.New("cas conflict")
+)
+
+// WriteMode 写入模式，与memcached的存储命令对应
+type WriteMode int32
+
+const (
+	WriteSet     WriteMode = iota // 直接写入
+	WriteAdd                      // 仅在key不存在时写入
+	WriteReplace                  // 仅在key存在时写入
+	WriteAppend                   // 追加到原值之后，沿用原条目的标记和过期时间
+	WritePrepend                  // 插入到原值之前，沿用原条目的标记和过期时间
+)
+
+// WriteOptions Write的选项。条件写入只按key所在节点的缓存判断，不会调用Getter
+type WriteOptions struct {
+	TTL   time.Duration // <=0时使用默认TTL
+	Flags uint32        // 随值保存的客户端标记
+	// ContentType 随值保存的Content-Type，HTTP网关读取时原样返回
+	ContentType string
+	CAS         uint64 // 不为0时仅在当前条目的版本号与之相同时写入
+	Mode        WriteMode
+	// Expire 条件满足时删除条目而不是写入，检查和删除是原子的，用于memcached已经过去的过期时间
+	Expire bool
+}
+
 type Group struct {
-	name      string
-	getter    Getter              
-	mainCache cache              
-	peers     PeerPicker     
-	loader    *singleflight.Group
+	name        string
+	getter      Getter
+	mainCache   cache
+	peers       PeerPicker
+	loader      *singleflight.Group
+	loadTimeout time.Duration
+	counters    groupCounters
+	logger      *slog.Logger
+
+	snapshot   *snapshotter // 未开启快照时为nil
+	snapshotMu sync.Mutex   // 串行化快照写入
+
+	revalidate RevalidateConfig
+	expiration ExpirationConfig
+	refresher  singleflight.Group // 后台刷新，与loader分开，前台请求不会等待刷新
+	forwarded  singleflight.Group // peer转发来的加载只在本地进行，与loader分开，避免两个节点互相等待对方的加载
+}
+
+// GroupOption 定义Group的配置选项
+type GroupOption func(*Group)
+
+// WithGroupLogger 设置Group使用的logger
+func WithGroupLogger(l *slog.Logger) GroupOption {
+	return func(g *Group) {
+		g.logger = logger.New("group", l)
+	}
+}
+
+// WithCacheType 设置Group使用的存储类型，默认LRU
+func WithCacheType(cacheType store.CacheType) GroupOption {
+	return func(g *Group) {
+		g.mainCache.cacheType = cacheType
+	}
+}
+
+// WithDiskTier 使用内存+磁盘两级存储，内存中因容量不足淘汰的条目写入opts.Dir下的日志段，
+// 命中时重新提升到内存。磁盘层只作为内存的扩展，重启后不保留
+func WithDiskTier(opts store.DiskOptions) GroupOption {
+	return func(g *Group) {
+		if opts.Codec == nil {
+			opts.Codec = byteViewCodec{}
+		}
+		g.mainCache.cacheType = store.Tiered
+		g.mainCache.disk = opts
+	}
+}
+
+// WithLoadTimeout 设置一次加载(从peer或Getter)的超时时间，默认5秒。
+// 并发的Get共享同一次加载，加载不随调用方的ctx取消，只受该超时限制
+func WithLoadTimeout(timeout time.Duration) GroupOption {
+	return func(g *Group) {
+		g.loadTimeout = timeout
+	}
+}
+
+// WithDefaultTTL 设置条目的默认过期时间，Getter返回的过期时间优先
+func WithDefaultTTL(ttl time.Duration) GroupOption {
+	return func(g *Group) {
+		g.mainCache.ttl = ttl
+	}
+}
+
+// groupCounters Group的运行计数，gets = hits + misses + dedups
+type groupCounters struct {
+	gets            atomic.Int64 // Get调用次数
+	hits            atomic.Int64 // 命中本地缓存
+	misses          atomic.Int64 // 未命中本地缓存，需要从peer或Getter加载
+	peerLoads       atomic.Int64 // 从peer加载成功
+	peerErrors      atomic.Int64 // 从peer加载失败
+	localLoads      atomic.Int64 // 调用Getter成功
+	localLoadErrors atomic.Int64 // 调用Getter失败
+	dedups          atomic.Int64 // 被singleflight合并的请求
+	staleHits       atomic.Int64 // 命中已软过期的条目，同时计入hits
+	refreshes       atomic.Int64 // 后台刷新次数，包括软过期、提前刷新和XFetch
+	loadLatency     latencyTracker
+}
+
+// Stats Group运行状态快照
+type Stats struct {
+	Name            string
+	Gets            int64
+	Hits            int64
+	Misses          int64
+	PeerLoads       int64
+	PeerErrors      int64
+	LocalLoads      int64
+	LocalLoadErrors int64
+	Dedups          int64
+	StaleHits       int64         // 返回了软过期的旧值，同时计入Hits
+	Refreshes       int64         // 软过期、提前刷新和XFetch触发的后台加载
+	LoadLatencyP50  time.Duration // 最近Getter调用耗时的分位数
+	LoadLatencyP90  time.Duration
+	LoadLatencyP99  time.Duration
+	Bytes           int64
+	Items           int
+	Evictions       uint64
+	Expirations     uint64
+	// CompressionInBytes 尝试压缩的值压缩前的累计字节数，CompressionOutBytes 这些值实际保存的累计字节数
+	CompressionInBytes  int64
+	CompressionOutBytes int64
+}
+
+// HitRate 返回本地缓存命中率
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// CompressionRatio 返回压缩前后的字节数之比，未开启压缩时返回0
+func (s Stats) CompressionRatio() float64 {
+	if s.CompressionOutBytes == 0 {
+		return 0
+	}
+	return float64(s.CompressionInBytes) / float64(s.CompressionOutBytes)
+}
+
+// Name 返回Group名称
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Stats 返回Group当前的统计快照
+func (g *Group) Stats() Stats {
+	c := &g.counters
+	latency := c.loadLatency.quantiles(0.5, 0.9, 0.99)
+	storeStats := g.mainCache.stats()
+	return Stats{
+		Name:                g.name,
+		Gets:                c.gets.Load(),
+		Hits:                c.hits.Load(),
+		Misses:              c.misses.Load(),
+		PeerLoads:           c.peerLoads.Load(),
+		PeerErrors:          c.peerErrors.Load(),
+		LocalLoads:          c.localLoads.Load(),
+		LocalLoadErrors:     c.localLoadErrors.Load(),
+		Dedups:              c.dedups.Load(),
+		StaleHits:           c.staleHits.Load(),
+		Refreshes:           c.refreshes.Load(),
+		LoadLatencyP50:      latency[0],
+		LoadLatencyP90:      latency[1],
+		LoadLatencyP99:      latency[2],
+		Bytes:               storeStats.Bytes,
+		Items:               storeStats.Entries,
+		Evictions:           storeStats.Evictions,
+		Expirations:         storeStats.Expirations,
+		CompressionInBytes:  g.mainCache.compressIn.Load(),
+		CompressionOutBytes: g.mainCache.compressOut.Load(),
+	}
+}
+
+// Flush 清空本节点上该Group的缓存，返回清除的条目数
+func (g *Group) Flush() int {
+	n := g.mainCache.clear()
+	g.logger.Info("group flushed", "removed", n)
+	return n
+}
+
+// Resize 调整本节点上该Group的缓存容量，返回调整前的容量
+func (g *Group) Resize(cacheBytes int64) (int64, error) {
+	old, err := g.mainCache.resize(cacheBytes)
+	if err != nil {
+		return old, err
+	}
+	g.logger.Info("group resized", "old_bytes", old, "bytes", cacheBytes)
+	return old, nil
 }
 
 func (g *Group) RegisterPeers(peers PeerPicker) {
@@ -29,7 +234,7 @@ func (g *Group) RegisterPeers(peers PeerPicker) {
 }
 
 // NewGroup 新创建一个Group
-func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
+func NewGroup(name string, cacheBytes int64, getter Getter, opts ...GroupOption) *Group {
 	if getter == nil {
 		panic("nil Getter")
 	}
@@ -40,13 +245,52 @@ func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
 		getter: getter,
 		mainCache: cache{
 			cacheBytes: cacheBytes,
+			cacheType:  store.LRU,
 		},
-		loader: &singleflight.Group{},
+		loader:      &singleflight.Group{},
+		loadTimeout: defaultLoadTimeout,
+		logger:      logger.New("group", nil),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	g.logger = g.logger.With("group", name)
+	switch g.mainCache.cacheType {
+	case store.Tiered:
+		s, err := g.mainCache.newStore()
+		if err != nil {
+			g.logger.Error("open disk tier failed, using memory only", "dir", g.mainCache.disk.Dir, "error", err)
+		}
+		g.mainCache.lruCache = s
+	case store.Arena:
+		s, err := g.mainCache.newStore()
+		if err != nil {
+			g.logger.Error("create arena store failed, using lru", "error", err)
+		}
+		g.mainCache.lruCache = s
+	}
+	if g.snapshot != nil {
+		g.startSnapshot()
+	}
+	if g.mainCache.aof != nil {
+		g.startAOF()
 	}
 	groups[name] = g
 	return g
 }
 
+// ListGroups 返回所有Group的名称，按名称排序
+func ListGroups() []string {
+	lock.RLock()
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	lock.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
 func GetGroup(name string) *Group {
 	lock.RLock()
 	g := groups[name]
@@ -54,45 +298,112 @@ func GetGroup(name string) *Group {
 	return g
 }
 
-func (g *Group) Get(key string) (ByteView, error) {
+func (g *Group) Get(ctx context.Context, key string) (ByteView, error) {
 	if key == "" {
 		return ByteView{}, fmt.Errorf("key is required")
 	}
-	return g.load(key)
+	g.counters.gets.Add(1)
+	return g.load(ctx, key)
 }
 
-func (g *Group) load(key string) (ByteView, error) {
-	v, err := g.loader.Do(key, func() (interface{}, error) {
-		if g.peers != nil {
+func (g *Group) load(ctx context.Context, key string) (value ByteView, err error) {
+	ctx, span := tracer().Start(ctx, "Group.load", trace.WithAttributes(attrGroup.String(g.name)))
+	defer func() { endSpan(span, err) }()
+
+	loader := g.loader
+	if isForwarded(ctx) {
+		loader = &g.forwarded
+	}
+	// 加载由第一个调用方发起，但结果由所有等待者共享，不能随该调用方的ctx取消
+	ch := loader.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), g.loadTimeout)
+		defer cancel()
+		if g.peers != nil && loader == g.loader {
 			if peer, ok, isSelf := g.peers.PickPeer(key); ok {
 				if isSelf {
 					if v, ok := g.mainCache.get(key); ok {
-						log.Println("[Geek-Cache] hit")
+						g.counters.hits.Add(1)
+						span.SetAttributes(attrCacheHit.Bool(true))
+						g.logger.Debug("cache hit", logger.Key(key))
+						g.revalidateAfterHit(key, v)
 						return v, nil
 					}
 				} else {
-					if value, err := g.getFromPeer(peer, key); err == nil {
+					if value, err := g.getFromPeer(ctx, peer, key); err == nil {
+						g.counters.misses.Add(1)
+						g.counters.peerLoads.Add(1)
 						return value, nil
 					} else {
-						log.Println("[Geek-Cache] Failed to get from peer", err)
+						g.counters.peerErrors.Add(1)
+						g.logger.Warn("failed to get from peer", logger.Key(key), "peer", peerAddr(peer), "error", err)
 					}
 				}
 			}
 		}
-		return g.getLocally(key)
+		return g.getLocally(ctx, key)
 	})
 
-	if err == nil {
-		return v.(ByteView), nil
+	// 每个等待者只等到自己的ctx结束
+	var r singleflight.Result
+	select {
+	case r = <-ch:
+	case <-ctx.Done():
+		return ByteView{}, ctx.Err()
+	}
+	span.SetAttributes(attrDedup.Bool(r.Shared))
+	if r.Shared {
+		g.counters.dedups.Add(1)
+	}
+
+	if r.Err == nil {
+		return r.Val.(ByteView), nil
+	}
+	return ByteView{}, r.Err
+}
+
+// getPlain 读取key并确认能够解压，用于需要原始数据的前端。无法解压的值已经损坏，视为不存在
+func (g *Group) getPlain(ctx context.Context, key string) (ByteView, error) {
+	view, err := g.Get(ctx, key)
+	if err != nil {
+		return view, err
+	}
+	if _, err := view.plain(); err != nil {
+		g.logger.Warn("corrupted value treated as miss", logger.Key(key), "error", err)
+		g.mainCache.drop(key, view.c)
+		return ByteView{}, ErrNotFound
 	}
-	return ByteView{}, err
+	return view, nil
 }
 
-func (g *Group) Delete(key string) (bool, error) {
+type cacheOnlyKey struct{}
+
+// lookup 只读取缓存，不调用Getter，key不在缓存中时返回ErrNotFound。key属于其它节点时向该节点查询
+func (g *Group) lookup(ctx context.Context, key string) (ByteView, error) {
+	if key == "" {
+		return ByteView{}, fmt.Errorf("key is required")
+	}
+	if g.peers != nil && !isForwarded(ctx) {
+		if peer, ok, isSelf := g.peers.PickPeer(key); ok && !isSelf {
+			return peer.Get(context.WithValue(ctx, cacheOnlyKey{}, true), g.name, key)
+		}
+	}
+	if v, ok := g.mainCache.get(key); ok {
+		return v, nil
+	}
+	return ByteView{}, ErrNotFound
+}
+
+// isCacheOnly 判断发往peer的Get是否只读取缓存
+func isCacheOnly(ctx context.Context) bool {
+	cacheOnly, _ := ctx.Value(cacheOnlyKey{}).(bool)
+	return cacheOnly
+}
+
+func (g *Group) Delete(ctx context.Context, key string) (bool, error) {
 	if key == "" {
 		return true, fmt.Errorf("key is required")
 	}
-	if g.peers == nil {
+	if g.peers == nil || isForwarded(ctx) {
 		return g.mainCache.delete(key), nil
 	}
 	peer, ok, isSelf := g.peers.PickPeer(key)
@@ -102,43 +413,99 @@ func (g *Group) Delete(key string) (bool, error) {
 	if isSelf {
 		return g.mainCache.delete(key), nil
 	} else {
-		success, err := g.deleteFromPeer(peer, key)
+		success, err := g.deleteFromPeer(ctx, peer, key)
 		return success, err
 	}
 }
 
-func (g *Group) getFromPeer(peer Peer, key string) (ByteView, error) {
-	bytes, err := peer.Get(g.name, key)
-	if err != nil {
-		return ByteView{}, err
+// Set 使用默认TTL写入key
+func (g *Group) Set(ctx context.Context, key string, value []byte) error {
+	return g.SetWithTTL(ctx, key, value, 0)
+}
+
+// SetWithTTL 写入key，ttl<=0时使用默认TTL
+func (g *Group) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := g.Write(ctx, key, value, WriteOptions{TTL: ttl})
+	return err
+}
+
+// Write 按opts写入key，返回新条目的CAS版本号。key属于其它节点时转发给该节点，节点不可用时写入本地
+func (g *Group) Write(ctx context.Context, key string, value []byte, opts WriteOptions) (uint64, error) {
+	if key == "" {
+		return 0, fmt.Errorf("key is required")
 	}
-	return ByteView{
-		b: cloneBytes(bytes),
-	}, nil
+	if g.peers != nil && !isForwarded(ctx) {
+		if peer, ok, isSelf := g.peers.PickPeer(key); ok && !isSelf {
+			return peer.Write(ctx, g.name, key, value, opts)
+		}
+	}
+	return g.mainCache.write(key, value, opts)
 }
 
-func (g *Group) deleteFromPeer(peer Peer, key string) (bool, error) {
-	success, err := peer.Delete(g.name, key)
+func (g *Group) getFromPeer(ctx context.Context, peer Peer, key string) (value ByteView, err error) {
+	ctx, span := tracer().Start(ctx, "Group.getFromPeer", trace.WithAttributes(attrGroup.String(g.name)))
+	defer func() { endSpan(span, err) }()
+	span.SetAttributes(attrPeerAddr.String(peerAddr(peer)))
+
+	return peer.Get(ctx, g.name, key)
+}
+
+func (g *Group) deleteFromPeer(ctx context.Context, peer Peer, key string) (bool, error) {
+	success, err := peer.Delete(ctx, g.name, key)
 	if err != nil {
 		return false, err
 	}
 	return success, nil
 }
 
-func (g *Group) getLocally(key string) (ByteView, error) {
+func (g *Group) getLocally(ctx context.Context, key string) (value ByteView, err error) {
+	ctx, span := tracer().Start(ctx, "Group.getLocally", trace.WithAttributes(attrGroup.String(g.name)))
+	defer func() { endSpan(span, err) }()
+
 	if v, ok := g.mainCache.get(key); ok {
-		log.Println("[Geek-Cache] hit")
+		g.counters.hits.Add(1)
+		span.SetAttributes(attrCacheHit.Bool(true))
+		g.logger.Debug("cache hit", logger.Key(key))
+		g.revalidateAfterHit(key, v)
 		return v, nil
 	}
+	g.counters.misses.Add(1)
+	span.SetAttributes(attrCacheHit.Bool(false))
+	return g.loadFromGetter(ctx, key, 0)
+}
+
+// loadFromGetter 调用Getter回源并写入本地缓存，只读请求不写入。cas不为0时只在当前条目的版本号相同时写入
+func (g *Group) loadFromGetter(ctx context.Context, key string, cas uint64) (ByteView, error) {
+	_, span := tracer().Start(ctx, "Getter.Get", trace.WithAttributes(attrGroup.String(g.name)))
+	start := time.Now()
 	bytes, f, expirationTime := g.getter.Get(key)
+	g.counters.loadLatency.observe(time.Since(start))
+	span.End()
 	if !f {
-		return ByteView{}, fmt.Errorf("data not found")
+		g.counters.localLoadErrors.Add(1)
+		return ByteView{}, ErrNotFound
+	}
+	g.counters.localLoads.Add(1)
+	bw := ByteView{b: cloneBytes(bytes), d: time.Since(start)}
+	if isReadOnly(ctx) {
+		return bw, nil
+	}
+	if cfg := g.revalidate; cfg.SoftTTL > 0 {
+		now := time.Now()
+		bw.s = now.Add(cfg.SoftTTL)
+		if expirationTime.IsZero() && cfg.HardTTL > 0 {
+			expirationTime = now.Add(cfg.HardTTL)
+		}
 	}
-	bw := ByteView{cloneBytes(bytes)}
+	var err error
 	if !expirationTime.IsZero() {
-		g.mainCache.addWithExpiration(key, bw, expirationTime)
+		bw, err = g.mainCache.addWithExpiration(key, bw, expirationTime, cas)
 	} else {
-		g.mainCache.add(key, bw)
+		bw, err = g.mainCache.add(key, bw, cas)
+	}
+	// 写入缓存失败不影响本次读取
+	if err != nil {
+		g.logger.Warn("cache loaded value failed", logger.Key(key), "error", err)
 	}
 	return bw, nil
 }
@@ -154,9 +521,26 @@ func (f GetterFunc) Get(key string) ([]byte, bool, time.Time) {
 }
 
 func DestroyGroup(name string) {
-	g := GetGroup(name)
+	lock.Lock()
+	g := groups[name]
+	delete(groups, name)
+	lock.Unlock()
 	if g != nil {
-		delete(groups, name)
-		log.Printf("Destroy store [%s]", name)
+		if g.snapshot != nil {
+			close(g.snapshot.stop)
+		}
+		if g.mainCache.aof != nil {
+			g.stopAOF()
+		}
+		g.mainCache.close()
+		g.logger.Info("group destroyed")
+	}
+}
+
+// peerAddr 返回peer地址，peer未提供地址时返回空字符串
+func peerAddr(peer Peer) string {
+	if p, ok := peer.(interface{ Addr() string }); ok {
+		return p.Addr()
 	}
+	return ""
 }
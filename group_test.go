@@ -0,0 +1,278 @@
+package gocache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"gocache/store"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGroup_Stats(t *testing.T) {
+	var mu sync.Mutex
+	loads := 0
+	release := make(chan struct{})
+	g := NewGroup("stats-test", 1<<10, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		if key == "missing" {
+			return nil, false, time.Time{}
+		}
+		mu.Lock()
+		loads++
+		mu.Unlock()
+		<-release
+		return []byte("value"), true, time.Time{}
+	}))
+	defer DestroyGroup("stats-test")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.Get(context.Background(), "k")
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	g.Get(context.Background(), "k")
+	g.Get(context.Background(), "missing")
+
+	stats := g.Stats()
+	if loads != 1 || stats.LocalLoads != 1 || stats.LocalLoadErrors != 1 {
+		t.Fatalf("expect 1 load and 1 load error, got %+v", stats)
+	}
+	if stats.Gets != 5 || stats.Dedups != 2 || stats.Hits != 1 || stats.Misses != 2 {
+		t.Fatalf("unexpected counters %+v", stats)
+	}
+	if stats.Items != 1 || stats.Bytes != int64(len("k")+len("value")) {
+		t.Fatalf("unexpected store stats %+v", stats)
+	}
+	if stats.LoadLatencyP99 < 50*time.Millisecond {
+		t.Fatalf("expect load latency to include blocked getter, got %v", stats.LoadLatencyP99)
+	}
+}
+
+func TestGroup_LoadIgnoresCallerCancel(t *testing.T) {
+	release := make(chan struct{})
+	g := NewGroup("load-cancel", 1<<10, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		<-release
+		return []byte("value"), true, time.Time{}
+	}))
+	defer DestroyGroup("load-cancel")
+
+	// 第一个调用方取消后立即返回，共享的加载继续进行
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := g.Get(ctx, "k")
+		first <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	second := make(chan string, 1)
+	go func() {
+		v, _ := g.Get(context.Background(), "k")
+		second <- v.String()
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect canceled caller to return, got %v", err)
+	}
+	close(release)
+	if v := <-second; v != "value" {
+		t.Fatalf("expect other waiter to get the value, got %q", v)
+	}
+}
+
+// blockingPeer Get阻塞到ctx结束
+type blockingPeer struct {
+	countingPeer
+}
+
+func (p *blockingPeer) Get(ctx context.Context, group, key string) (ByteView, error) {
+	p.calls.Add(1)
+	<-ctx.Done()
+	return ByteView{}, ctx.Err()
+}
+
+func TestGroup_LoadTimeout(t *testing.T) {
+	g := NewGroup("load-timeout", 1<<10, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		return []byte("local"), true, time.Time{}
+	}), WithLoadTimeout(20*time.Millisecond))
+	defer DestroyGroup("load-timeout")
+	g.RegisterPeers(remotePicker{peer: &blockingPeer{}})
+
+	start := time.Now()
+	if v, err := g.Get(context.Background(), "k"); err != nil || v.String() != "local" || time.Since(start) > time.Second {
+		t.Fatalf("expect peer load bounded by load timeout, got %q %v after %v", v.String(), err, time.Since(start))
+	}
+}
+
+func TestGroup_Set(t *testing.T) {
+	g := NewGroup("set-test", 1<<10, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		return nil, false, time.Time{}
+	}))
+	defer DestroyGroup("set-test")
+
+	if err := g.Set(context.Background(), "k", []byte("v")); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if v, err := g.Get(context.Background(), "k"); err != nil || v.String() != "v" {
+		t.Fatalf("expect v, got %q %v", v.String(), err)
+	}
+	if _, err := g.Get(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expect ErrNotFound, got %v", err)
+	}
+}
+
+func TestGroup_Write(t *testing.T) {
+	g := NewGroup("write-test", 1<<10, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		return nil, false, time.Time{}
+	}))
+	defer DestroyGroup("write-test")
+	ctx := context.Background()
+
+	if _, err := g.Write(ctx, "k", []byte("v"), WriteOptions{Mode: WriteReplace}); !errors.Is(err, ErrNotStored) {
+		t.Fatalf("replace missing key: expect ErrNotStored, got %v", err)
+	}
+	cas, err := g.Write(ctx, "k", []byte("v"), WriteOptions{Mode: WriteAdd, Flags: 7})
+	if err != nil || cas == 0 {
+		t.Fatalf("add: %d %v", cas, err)
+	}
+	if _, err := g.Write(ctx, "k", []byte("v2"), WriteOptions{Mode: WriteAdd}); !errors.Is(err, ErrNotStored) {
+		t.Fatalf("add existing key: expect ErrNotStored, got %v", err)
+	}
+
+	view, _ := g.Get(ctx, "k")
+	if view.Flags() != 7 || view.CAS() != cas {
+		t.Fatalf("expect flags 7 and cas %d, got %d %d", cas, view.Flags(), view.CAS())
+	}
+
+	if _, err := g.Write(ctx, "k", []byte("!"), WriteOptions{Mode: WriteAppend, TTL: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Write(ctx, "k", []byte("x"), WriteOptions{CAS: cas}); !errors.Is(err, ErrCASConflict) {
+		t.Fatalf("stale cas: expect ErrCASConflict, got %v", err)
+	}
+	view, _ = g.Get(ctx, "k")
+	if view.String() != "v!" || view.Flags() != 7 || view.TTL() != 0 {
+		t.Fatalf("append must keep flags and expiration, got %q %d %v", view.String(), view.Flags(), view.TTL())
+	}
+	if _, err := g.Write(ctx, "k", []byte("x"), WriteOptions{CAS: view.CAS()}); err != nil {
+		t.Fatalf("cas: %v", err)
+	}
+	if _, err := g.Write(ctx, "missing", []byte("x"), WriteOptions{CAS: 1}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("cas missing key: expect ErrNotFound, got %v", err)
+	}
+}
+
+func TestGroup_DiskTier(t *testing.T) {
+	dir := t.TempDir()
+	g := NewGroup("disk-tier", 64, missGetter(), WithDiskTier(store.DiskOptions{Dir: dir}))
+	t.Cleanup(func() { DestroyGroup("disk-tier") })
+	ctx := context.Background()
+
+	cas, _ := g.Write(ctx, "k0", []byte("0123456789"), WriteOptions{Flags: 5, ContentType: "text/plain", TTL: time.Hour})
+	for _, key := range []string{"k1", "k2", "k3", "k4", "k5"} {
+		g.Set(ctx, key, []byte("0123456789"))
+	}
+	if stats := g.Stats(); stats.Items != 6 || stats.Evictions != 0 {
+		t.Fatalf("expect all items kept across tiers, got %+v", stats)
+	}
+	v, err := g.Get(ctx, "k0")
+	if err != nil || v.String() != "0123456789" || v.Flags() != 5 || v.CAS() != cas || v.ContentType() != "text/plain" || v.TTL() <= 59*time.Minute {
+		t.Fatalf("unexpected entry promoted from disk %+v %v", v, err)
+	}
+
+	DestroyGroup("disk-tier")
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.seg")); len(files) != 0 {
+		t.Fatalf("expect segments removed, got %v", files)
+	}
+
+	// 目录不可用时回退到只使用内存
+	file := filepath.Join(dir, "file")
+	os.WriteFile(file, nil, 0o644)
+	g = NewGroup("disk-tier", 64, missGetter(), WithDiskTier(store.DiskOptions{Dir: file}))
+	if err := g.Set(ctx, "k", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGroup_Arena(t *testing.T) {
+	g := NewGroup("arena", 1<<10, missGetter(), WithCacheType(store.Arena))
+	t.Cleanup(func() { DestroyGroup("arena") })
+	ctx := context.Background()
+
+	cas, _ := g.Write(ctx, "k", []byte("value"), WriteOptions{Flags: 7, ContentType: "text/plain", TTL: time.Hour})
+	v, err := g.Get(ctx, "k")
+	if err != nil || v.String() != "value" || v.Flags() != 7 || v.CAS() != cas || v.ContentType() != "text/plain" || v.TTL() <= 59*time.Minute {
+		t.Fatalf("unexpected entry %+v %v", v, err)
+	}
+	for i := 0; i < 100; i++ {
+		g.Set(ctx, fmt.Sprintf("key%d", i), []byte("0123456789"))
+	}
+	if stats := g.Stats(); stats.Evictions == 0 || stats.Bytes > 1<<10 {
+		t.Fatalf("expect arena bounded by cacheBytes, got %+v", stats)
+	}
+
+	// 存储拒绝的写入返回错误，旧值被删除
+	if err := g.Set(ctx, "key99", make([]byte, 2<<10)); err == nil {
+		t.Fatal("expect error for entry larger than the arena")
+	}
+	if _, err := g.Get(ctx, "key99"); err != ErrNotFound {
+		t.Fatalf("expect old value removed after failed write, got %v", err)
+	}
+}
+
+func TestGroup_ArenaWithoutMaxBytes(t *testing.T) {
+	var buf bytes.Buffer
+	g := NewGroup("arena-unbounded", 0, missGetter(), WithCacheType(store.Arena),
+		WithGroupLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	t.Cleanup(func() { DestroyGroup("arena-unbounded") })
+
+	// Arena需要容量上限，创建失败时记录错误并使用LRU
+	if !strings.Contains(buf.String(), "create arena store failed") {
+		t.Fatalf("expect arena error logged, got %q", buf.String())
+	}
+	ctx := context.Background()
+	if err := g.Set(ctx, "k", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := g.Get(ctx, "k"); err != nil || v.String() != "v" {
+		t.Fatalf("expect lru fallback usable, got %q %v", v.String(), err)
+	}
+}
+
+func TestGroup_WriteExpire(t *testing.T) {
+	g := NewGroup("write-expire", 1<<20, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		return nil, false, time.Time{}
+	}))
+	t.Cleanup(func() { DestroyGroup("write-expire") })
+	ctx := context.Background()
+	cas, err := g.Write(ctx, "k", []byte("v"), WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// CAS不匹配时不删除
+	if _, err := g.Write(ctx, "k", nil, WriteOptions{CAS: cas + 1, Expire: true}); !errors.Is(err, ErrCASConflict) {
+		t.Fatalf("expect ErrCASConflict, got %v", err)
+	}
+	if _, ok := g.mainCache.get("k"); !ok {
+		t.Fatal("expect entry kept on CAS conflict")
+	}
+	if _, err := g.Write(ctx, "k", nil, WriteOptions{CAS: cas, Expire: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.mainCache.get("k"); ok {
+		t.Fatal("expect entry removed")
+	}
+}
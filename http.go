@@ -0,0 +1,469 @@
+package gocache
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gocache/auth"
+	"gocache/logger"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	// HeaderTTL 写入时指定过期时间(秒数或"1m30s"形式)，读取时返回剩余秒数
+	HeaderTTL = "X-Gocache-Ttl"
+	// defaultHTTPMaxValueBytes PUT请求体和mget请求的默认大小上限
+	defaultHTTPMaxValueBytes = 1 << 20
+	// maxHTTPMGetKeys 单次mget的最大key数
+	maxHTTPMGetKeys    = 1000
+	defaultContentType = "application/octet-stream"
+)
+
+// HTTPConfig HTTP/JSON网关配置
+type HTTPConfig struct {
+	Addr string
+	// MaxValueBytes 单个请求体的最大字节数，默认1MB
+	MaxValueBytes int64
+}
+
+// WithHTTP 在cfg.Addr上开启HTTP/JSON网关，读写经过与gRPC接口相同的Group和peer路由。
+// Server配置了TLS时网关使用HTTPS，配置了认证时使用Authorization: Bearer <token>或mTLS证书认证
+func WithHTTP(cfg HTTPConfig) ServerOptions {
+	return func(server *Server) {
+		server.gateway = &httpGateway{cfg: cfg}
+	}
+}
+
+// httpGateway HTTP/JSON网关
+//
+//	GET    /groups                       可读的Group列表
+//	GET    /stats[?group=name]           Group统计
+//	GET    /groups/{group}/keys/{key}    读取，支持If-None-Match
+//	PUT    /groups/{group}/keys/{key}    写入，支持If-Match、If-None-Match: *
+//	DELETE /groups/{group}/keys/{key}    删除
+//	POST   /groups/{group}/mget          批量读取，请求体为{"keys": [...]}
+type httpGateway struct {
+	cfg    HTTPConfig
+	server *Server
+	srv    *http.Server
+	logger *slog.Logger
+}
+
+// start 开始监听，由Server.Run调用
+func (h *httpGateway) start(s *Server) error {
+	if h.cfg.MaxValueBytes <= 0 {
+		h.cfg.MaxValueBytes = defaultHTTPMaxValueBytes
+	}
+	h.server = s
+	h.logger = logger.New("http", s.baseLogger).With("addr", h.cfg.Addr)
+
+	lis, err := net.Listen("tcp", h.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("http: listen %s error: %v", h.cfg.Addr, err)
+	}
+	if s.tlsConfig != nil {
+		lis = tls.NewListener(lis, s.tlsConfig)
+	}
+	h.srv = &http.Server{Handler: h.handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	go func(srv *http.Server) {
+		if err := srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			h.logger.Error("http gateway failed", "error", err)
+		}
+	}(h.srv)
+	h.logger.Info("http gateway is running")
+	return nil
+}
+
+// stop 等待处理中的请求结束后关闭
+func (h *httpGateway) stop() {
+	if h.srv == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.srv.Shutdown(ctx); err != nil {
+		h.logger.Warn("shutdown http gateway failed", "error", err)
+	}
+	h.srv = nil
+}
+
+func (h *httpGateway) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /groups", h.listGroups)
+	mux.HandleFunc("GET /stats", h.stats)
+	mux.HandleFunc("GET /groups/{group}/keys/{key...}", h.get)
+	mux.HandleFunc("PUT /groups/{group}/keys/{key...}", h.put)
+	mux.HandleFunc("DELETE /groups/{group}/keys/{key...}", h.delete)
+	mux.HandleFunc("POST /groups/{group}/mget", h.mget)
+	return mux
+}
+
+// httpError 错误响应体
+type httpError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeHTTPError(w http.ResponseWriter, code int, format string, args ...interface{}) {
+	writeJSON(w, code, httpError{Error: fmt.Sprintf(format, args...)})
+}
+
+// identity 认证请求，未配置认证时返回nil
+func (h *httpGateway) identity(r *http.Request) (*auth.Identity, error) {
+	ctx := r.Context()
+	if r.TLS != nil {
+		// 与gRPC一致，mTLS证书中的身份可以直接用于认证
+		ctx = peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{State: *r.TLS}})
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return h.server.authenticateConn(ctx, "http", token)
+}
+
+// allowed 检查请求对group的操作权限，不允许时已写入401或403
+func (h *httpGateway) allowed(w http.ResponseWriter, r *http.Request, group string, op auth.Operation) bool {
+	if h.server.authn == nil {
+		return true
+	}
+	id, err := h.identity(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeHTTPError(w, http.StatusUnauthorized, "%v", err)
+		return false
+	}
+	if !h.server.acl.Allowed(id.Name, group, op) {
+		writeHTTPError(w, http.StatusForbidden, "%s is not allowed to %s group %s", id.Name, op, group)
+		return false
+	}
+	return true
+}
+
+// group 解析路径中的Group并检查权限，失败时已写入响应
+func (h *httpGateway) group(w http.ResponseWriter, r *http.Request, op auth.Operation) *Group {
+	name := r.PathValue("group")
+	if !h.allowed(w, r, name, op) {
+		return nil
+	}
+	g := GetGroup(name)
+	if g == nil {
+		writeHTTPError(w, http.StatusNotFound, "group %s not exist", name)
+		return nil
+	}
+	return g
+}
+
+func (h *httpGateway) listGroups(w http.ResponseWriter, r *http.Request) {
+	names := []string{}
+	for _, name := range ListGroups() {
+		if h.server.authn == nil || h.readable(r, name) {
+			names = append(names, name)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"groups": names})
+}
+
+// readable 不写响应的读权限检查，用于过滤列表
+func (h *httpGateway) readable(r *http.Request, group string) bool {
+	id, err := h.identity(r)
+	return err == nil && h.server.acl.Allowed(id.Name, group, auth.OpRead)
+}
+
+// httpStats Group统计的JSON格式，字段名与ResponseForStats一致
+type httpStats struct {
+	Group            string  `json:"group"`
+	Gets             int64   `json:"gets"`
+	Hits             int64   `json:"hits"`
+	Misses           int64   `json:"misses"`
+	HitRate          float64 `json:"hit_rate"`
+	PeerLoads        int64   `json:"peer_loads"`
+	PeerErrors       int64   `json:"peer_errors"`
+	LocalLoads       int64   `json:"local_loads"`
+	LocalLoadErrors  int64   `json:"local_load_errors"`
+	Dedups           int64   `json:"dedups"`
+	StaleHits        int64   `json:"stale_hits"`
+	Refreshes        int64   `json:"refreshes"`
+	LoadLatencyP50Us int64   `json:"load_latency_p50_us"`
+	LoadLatencyP90Us int64   `json:"load_latency_p90_us"`
+	LoadLatencyP99Us int64   `json:"load_latency_p99_us"`
+	Bytes            int64   `json:"bytes"`
+	Items            int     `json:"items"`
+	Evictions        uint64  `json:"evictions"`
+	Expirations      uint64  `json:"expirations"`
+	CompressionRatio float64 `json:"compression_ratio"`
+}
+
+func newHTTPStats(s Stats) httpStats {
+	return httpStats{
+		Group:            s.Name,
+		Gets:             s.Gets,
+		Hits:             s.Hits,
+		Misses:           s.Misses,
+		HitRate:          s.HitRate(),
+		PeerLoads:        s.PeerLoads,
+		PeerErrors:       s.PeerErrors,
+		LocalLoads:       s.LocalLoads,
+		LocalLoadErrors:  s.LocalLoadErrors,
+		Dedups:           s.Dedups,
+		StaleHits:        s.StaleHits,
+		Refreshes:        s.Refreshes,
+		LoadLatencyP50Us: s.LoadLatencyP50.Microseconds(),
+		LoadLatencyP90Us: s.LoadLatencyP90.Microseconds(),
+		LoadLatencyP99Us: s.LoadLatencyP99.Microseconds(),
+		Bytes:            s.Bytes,
+		Items:            s.Items,
+		Evictions:        s.Evictions,
+		Expirations:      s.Expirations,
+		CompressionRatio: s.CompressionRatio(),
+	}
+}
+
+// stats 返回本节点上Group的统计，指定group参数时只返回该Group
+func (h *httpGateway) stats(w http.ResponseWriter, r *http.Request) {
+	names := ListGroups()
+	if name := r.URL.Query().Get("group"); name != "" {
+		if !h.allowed(w, r, name, auth.OpRead) {
+			return
+		}
+		if GetGroup(name) == nil {
+			writeHTTPError(w, http.StatusNotFound, "group %s not exist", name)
+			return
+		}
+		names = []string{name}
+	}
+
+	stats := []httpStats{}
+	for _, name := range names {
+		if h.server.authn != nil && !h.readable(r, name) {
+			continue
+		}
+		if g := GetGroup(name); g != nil {
+			stats = append(stats, newHTTPStats(g.Stats()))
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string][]httpStats{"groups": stats})
+}
+
+// etag 由条目的CAS版本号生成强ETag
+func etag(view ByteView) string {
+	return `"` + strconv.FormatUint(view.CAS(), 16) + `"`
+}
+
+// parseETag 解析If-Match中的ETag为CAS版本号，不支持弱ETag和多个ETag
+func parseETag(s string) (uint64, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < 3 || s[0] != '"' || s[len(s)-1] != '"' {
+		return 0, false
+	}
+	cas, err := strconv.ParseUint(s[1:len(s)-1], 16, 64)
+	return cas, err == nil && cas != 0
+}
+
+// etagMatch If-None-Match是否匹配，支持逗号分隔的多个ETag和弱比较
+func etagMatch(header, tag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTTL 解析TTL头，支持秒数和time.ParseDuration格式
+func parseTTL(s string) (time.Duration, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && n >= 0 {
+		return time.Duration(n) * time.Second, nil
+	}
+	ttl, err := time.ParseDuration(s)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("invalid %s header %q", HeaderTTL, s)
+	}
+	return ttl, nil
+}
+
+// setItemHeaders 写入条目的ETag、过期时间和Content-Type
+func setItemHeaders(w http.ResponseWriter, view ByteView) {
+	header := w.Header()
+	if view.CAS() != 0 {
+		header.Set("ETag", etag(view))
+	}
+	if ttl := view.TTL(); ttl > 0 {
+		header.Set(HeaderTTL, strconv.FormatInt(int64((ttl+time.Second-1)/time.Second), 10))
+		header.Set("Expires", view.Expire().UTC().Format(http.TimeFormat))
+	}
+}
+
+func (h *httpGateway) get(w http.ResponseWriter, r *http.Request) {
+	g := h.group(w, r, auth.OpRead)
+	if g == nil {
+		return
+	}
+	view, err := g.getPlain(r.Context(), r.PathValue("key"))
+	if errors.Is(err, ErrNotFound) {
+		writeHTTPError(w, http.StatusNotFound, "key not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, http.StatusBadGateway, "%v", err)
+		return
+	}
+
+	setItemHeaders(w, view)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && view.CAS() != 0 && etagMatch(inm, etag(view)) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	contentType := view.ContentType()
+	if contentType == "" {
+		contentType = defaultContentType
+	}
+	w.Header().Set("Content-Type", contentType)
+	data := view.data()
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func (h *httpGateway) put(w http.ResponseWriter, r *http.Request) {
+	g := h.group(w, r, auth.OpWrite)
+	if g == nil {
+		return
+	}
+
+	opts := WriteOptions{ContentType: r.Header.Get("Content-Type")}
+	if s := r.Header.Get(HeaderTTL); s != "" {
+		ttl, err := parseTTL(s)
+		if err != nil {
+			writeHTTPError(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+		opts.TTL = ttl
+	}
+	if im := r.Header.Get("If-Match"); im == "*" {
+		opts.Mode = WriteReplace
+	} else if im != "" {
+		cas, ok := parseETag(im)
+		if !ok {
+			writeHTTPError(w, http.StatusPreconditionFailed, "unsupported If-Match %q", im)
+			return
+		}
+		opts.CAS = cas
+	}
+	if inm := r.Header.Get("If-None-Match"); inm == "*" {
+		opts.Mode = WriteAdd
+	} else if inm != "" {
+		writeHTTPError(w, http.StatusBadRequest, "only If-None-Match: * is supported for PUT")
+		return
+	}
+
+	value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.cfg.MaxValueBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeHTTPError(w, http.StatusRequestEntityTooLarge, "value exceeds %d bytes", h.cfg.MaxValueBytes)
+			return
+		}
+		writeHTTPError(w, http.StatusBadRequest, "read body: %v", err)
+		return
+	}
+
+	cas, err := g.Write(r.Context(), r.PathValue("key"), value, opts)
+	switch {
+	case errors.Is(err, ErrNotStored), errors.Is(err, ErrCASConflict), errors.Is(err, ErrNotFound):
+		writeHTTPError(w, http.StatusPreconditionFailed, "%v", err)
+	case err != nil:
+		writeHTTPError(w, http.StatusBadGateway, "%v", err)
+	default:
+		w.Header().Set("ETag", etag(ByteView{c: cas}))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *httpGateway) delete(w http.ResponseWriter, r *http.Request) {
+	g := h.group(w, r, auth.OpDelete)
+	if g == nil {
+		return
+	}
+	deleted, err := g.Delete(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeHTTPError(w, http.StatusBadGateway, "%v", err)
+		return
+	}
+	if !deleted {
+		writeHTTPError(w, http.StatusNotFound, "key not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// httpItem mget结果中的一项，value不是合法UTF-8时按base64编码
+type httpItem struct {
+	Key         string `json:"key"`
+	Found       bool   `json:"found"`
+	Value       string `json:"value,omitempty"`
+	Encoding    string `json:"encoding,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	ETag        string `json:"etag,omitempty"`
+	TTLMs       int64  `json:"ttl_ms,omitempty"`
+}
+
+func (h *httpGateway) mget(w http.ResponseWriter, r *http.Request) {
+	g := h.group(w, r, auth.OpRead)
+	if g == nil {
+		return
+	}
+	var req struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.cfg.MaxValueBytes)).Decode(&req); err != nil {
+		writeHTTPError(w, http.StatusBadRequest, "invalid request body: %v", err)
+		return
+	}
+	if len(req.Keys) == 0 || len(req.Keys) > maxHTTPMGetKeys {
+		writeHTTPError(w, http.StatusBadRequest, "keys must contain 1 to %d keys", maxHTTPMGetKeys)
+		return
+	}
+
+	groups := make([]*Group, len(req.Keys))
+	for i := range groups {
+		groups[i] = g
+	}
+	views := getAll(r.Context(), groups, req.Keys, h.logger)
+
+	items := make([]httpItem, len(req.Keys))
+	for i, view := range views {
+		items[i] = httpItem{Key: req.Keys[i]}
+		if view == nil {
+			continue
+		}
+		item := &items[i]
+		item.Found, item.ContentType, item.TTLMs = true, view.ContentType(), ttlMillis(view.TTL())
+		if view.CAS() != 0 {
+			item.ETag = etag(*view)
+		}
+		if data := view.data(); utf8.Valid(data) {
+			item.Value = string(data)
+		} else {
+			item.Value, item.Encoding = base64.StdEncoding.EncodeToString(data), "base64"
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string][]httpItem{"items": items})
+}
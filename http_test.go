@@ -0,0 +1,156 @@
+package gocache
+
+import (
+	"encoding/json"
+	"gocache/auth"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// startGateway 用httptest承载网关的handler，不依赖etcd
+func startGateway(t *testing.T, opts ...ServerOptions) *httptest.Server {
+	t.Helper()
+	server, err := NewServer("localhost:9999", append(opts, WithHTTP(HTTPConfig{MaxValueBytes: 64}))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := server.gateway
+	h.server, h.logger = server, server.logger
+	ts := httptest.NewServer(h.handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func doHTTP(t *testing.T, method, url, body string, header map[string]string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp, string(b)
+}
+
+func TestHTTP_Keys(t *testing.T) {
+	newProtocolGroup(t, "http-keys")
+	ts := startGateway(t)
+	url := ts.URL + "/groups/http-keys/keys/"
+
+	resp, _ := doHTTP(t, "PUT", url+"a/b", `{"n":1}`, map[string]string{"Content-Type": "application/json", HeaderTTL: "1m"})
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get("ETag") == "" {
+		t.Fatalf("put: %d %v", resp.StatusCode, resp.Header)
+	}
+	tag := resp.Header.Get("ETag")
+
+	resp, body := doHTTP(t, "GET", url+"a/b", "", nil)
+	if resp.StatusCode != http.StatusOK || body != `{"n":1}` || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("get: %d %q %v", resp.StatusCode, body, resp.Header)
+	}
+	if resp.Header.Get("ETag") != tag || resp.Header.Get(HeaderTTL) != "60" || resp.Header.Get("Expires") == "" {
+		t.Fatalf("unexpected headers %v", resp.Header)
+	}
+	if resp, _ := doHTTP(t, "GET", url+"a/b", "", map[string]string{"If-None-Match": tag}); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("expect 304, got %d", resp.StatusCode)
+	}
+	if resp, body := doHTTP(t, "GET", url+"loaded", "", nil); body != "from-getter" || resp.Header.Get("Content-Type") != defaultContentType {
+		t.Fatalf("get through getter: %q %v", body, resp.Header)
+	}
+	if resp, _ := doHTTP(t, "GET", url+"missing", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expect 404, got %d", resp.StatusCode)
+	}
+
+	// 条件写入
+	if resp, _ := doHTTP(t, "PUT", url+"a/b", "x", map[string]string{"If-None-Match": "*"}); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("expect 412 for existing key, got %d", resp.StatusCode)
+	}
+	if resp, _ := doHTTP(t, "PUT", url+"a/b", "x", map[string]string{"If-Match": `"1"`}); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("expect 412 for stale etag, got %d", resp.StatusCode)
+	}
+	if resp, _ := doHTTP(t, "PUT", url+"a/b", "x", map[string]string{"If-Match": tag}); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expect 204 for matching etag, got %d", resp.StatusCode)
+	}
+	if resp, _ := doHTTP(t, "PUT", url+"c", "x", map[string]string{"If-Match": "*"}); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("expect 412 for missing key, got %d", resp.StatusCode)
+	}
+	if resp, _ := doHTTP(t, "PUT", url+"c", "x", map[string]string{HeaderTTL: "soon"}); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expect 400 for invalid ttl, got %d", resp.StatusCode)
+	}
+	if resp, _ := doHTTP(t, "PUT", url+"c", strings.Repeat("x", 65), nil); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expect 413, got %d", resp.StatusCode)
+	}
+
+	if resp, _ := doHTTP(t, "DELETE", url+"a/b", "", nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expect 204, got %d", resp.StatusCode)
+	}
+	if resp, _ := doHTTP(t, "DELETE", url+"a/b", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expect 404, got %d", resp.StatusCode)
+	}
+	if resp, _ := doHTTP(t, "GET", ts.URL+"/groups/missing/keys/k", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expect 404 for missing group, got %d", resp.StatusCode)
+	}
+}
+
+func TestHTTP_MGetAndStats(t *testing.T) {
+	g := newProtocolGroup(t, "http-mget")
+	ts := startGateway(t)
+	doHTTP(t, "PUT", ts.URL+"/groups/http-mget/keys/bin", "\xff\x00", nil)
+
+	resp, body := doHTTP(t, "POST", ts.URL+"/groups/http-mget/mget", `{"keys":["loaded","missing","bin"]}`, nil)
+	var out struct {
+		Items []httpItem `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(body), &out); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("mget: %d %q %v", resp.StatusCode, body, err)
+	}
+	if len(out.Items) != 3 || out.Items[0].Value != "from-getter" || out.Items[1].Found || out.Items[2].Encoding != "base64" || out.Items[2].Value != "/wA=" {
+		t.Fatalf("unexpected mget items %+v", out.Items)
+	}
+	if resp, _ := doHTTP(t, "POST", ts.URL+"/groups/http-mget/mget", `{"keys":[]}`, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expect 400 for empty keys, got %d", resp.StatusCode)
+	}
+
+	_, body = doHTTP(t, "GET", ts.URL+"/groups", "", nil)
+	if !strings.Contains(body, `"http-mget"`) {
+		t.Fatalf("group not listed: %s", body)
+	}
+	_, body = doHTTP(t, "GET", ts.URL+"/stats?group=http-mget", "", nil)
+	var stats struct {
+		Groups []httpStats `json:"groups"`
+	}
+	if err := json.Unmarshal([]byte(body), &stats); err != nil || len(stats.Groups) != 1 || stats.Groups[0].Items != g.Stats().Items {
+		t.Fatalf("unexpected stats %s %v", body, err)
+	}
+}
+
+func TestHTTP_Auth(t *testing.T) {
+	newProtocolGroup(t, "http-auth")
+	newProtocolGroup(t, "http-auth-hidden")
+	acl := auth.NewACL()
+	acl.Grant("svc", "http-auth", auth.OpRead)
+	ts := startGateway(t, WithAuth(auth.TokenAuthenticator{"secret": "svc"}, acl))
+	bearer := map[string]string{"Authorization": "Bearer secret"}
+
+	if resp, _ := doHTTP(t, "GET", ts.URL+"/groups/http-auth/keys/loaded", "", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expect 401, got %d", resp.StatusCode)
+	}
+	if resp, _ := doHTTP(t, "GET", ts.URL+"/groups/http-auth/keys/loaded", "", bearer); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expect 200, got %d", resp.StatusCode)
+	}
+	if resp, _ := doHTTP(t, "PUT", ts.URL+"/groups/http-auth/keys/k", "v", bearer); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expect 403, got %d", resp.StatusCode)
+	}
+	if _, body := doHTTP(t, "GET", ts.URL+"/groups", "", bearer); strings.Contains(body, "http-auth-hidden") {
+		t.Fatalf("unreadable group listed: %s", body)
+	}
+}
@@ -0,0 +1,65 @@
+package gocache
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const latencyWindow = 256
+
+// latencyTracker 记录最近的请求延迟，用于估算p95
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples [latencyWindow]time.Duration
+	next    int
+	count   int
+	p95     time.Duration
+}
+
+// observe 记录一次延迟，每积累一定样本重新计算p95
+func (t *latencyTracker) observe(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.samples[t.next] = d
+	t.next = (t.next + 1) % latencyWindow
+	if t.count < latencyWindow {
+		t.count++
+	}
+	if t.count%32 == 0 || t.next%32 == 0 {
+		sorted := make([]time.Duration, t.count)
+		copy(sorted, t.samples[:t.count])
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		t.p95 = sorted[(t.count*95)/100]
+	}
+}
+
+// percentile95 返回当前估算的p95，样本不足时返回0
+func (t *latencyTracker) percentile95() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.p95
+}
+
+// quantiles 根据当前窗口内的样本计算多个分位数，样本为空时全部返回0
+func (t *latencyTracker) quantiles(qs ...float64) []time.Duration {
+	t.mu.Lock()
+	sorted := make([]time.Duration, t.count)
+	copy(sorted, t.samples[:t.count])
+	t.mu.Unlock()
+
+	result := make([]time.Duration, len(qs))
+	if len(sorted) == 0 {
+		return result
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i, q := range qs {
+		idx := int(q * float64(len(sorted)))
+		if idx >= len(sorted) {
+			idx = len(sorted) - 1
+		}
+		result[i] = sorted[idx]
+	}
+	return result
+}
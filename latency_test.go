@@ -0,0 +1,31 @@
+package gocache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyTracker_P95(t *testing.T) {
+	var tracker latencyTracker
+	for i := 1; i <= 100; i++ {
+		tracker.observe(time.Duration(i) * time.Millisecond)
+	}
+	p95 := tracker.percentile95()
+	if p95 < 90*time.Millisecond || p95 > 100*time.Millisecond {
+		t.Fatalf("expect p95 around 95ms, got %v", p95)
+	}
+}
+
+func TestLatencyTracker_Quantiles(t *testing.T) {
+	var tracker latencyTracker
+	if q := tracker.quantiles(0.5); q[0] != 0 {
+		t.Fatalf("expect 0 without samples, got %v", q[0])
+	}
+	for i := 1; i <= 100; i++ {
+		tracker.observe(time.Duration(i) * time.Millisecond)
+	}
+	q := tracker.quantiles(0.5, 0.99, 1)
+	if q[0] != 51*time.Millisecond || q[1] != 100*time.Millisecond || q[2] != 100*time.Millisecond {
+		t.Fatalf("unexpected quantiles %v", q)
+	}
+}
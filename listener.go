@@ -0,0 +1,167 @@
+package gocache
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"gocache/auth"
+	"gocache/logger"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// connListener 文本协议前端共用的TCP监听，负责TLS握手和连接的生命周期
+type connListener struct {
+	addr   string
+	logger *slog.Logger
+	lis    net.Listener
+	// handle 处理一个连接，返回后连接被关闭。TLS连接的ctx中带有对端证书信息
+	handle func(ctx context.Context, conn net.Conn)
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// listen 开始监听，tlsConfig不为nil时使用TLS
+func (l *connListener) listen(tlsConfig *tls.Config) error {
+	lis, err := net.Listen("tcp", l.addr)
+	if err != nil {
+		return fmt.Errorf("listen %s error: %v", l.addr, err)
+	}
+	if tlsConfig != nil {
+		lis = tls.NewListener(lis, tlsConfig)
+	}
+	l.lis = lis
+	l.conns = make(map[net.Conn]struct{})
+
+	l.wg.Add(1)
+	go l.serve()
+	return nil
+}
+
+func (l *connListener) serve() {
+	defer l.wg.Done()
+	for {
+		conn, err := l.lis.Accept()
+		if err != nil {
+			l.mu.Lock()
+			closed := l.closed
+			l.mu.Unlock()
+			if !closed {
+				l.logger.Error("accept failed", "error", err)
+			}
+			return
+		}
+
+		l.mu.Lock()
+		if l.closed {
+			l.mu.Unlock()
+			conn.Close()
+			return
+		}
+		l.conns[conn] = struct{}{}
+		l.mu.Unlock()
+
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			l.serveConn(conn)
+			l.mu.Lock()
+			delete(l.conns, conn)
+			l.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+func (l *connListener) serveConn(conn net.Conn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			l.logger.Debug("tls handshake failed", "remote", conn.RemoteAddr().String(), "error", err)
+			return
+		}
+		// 与gRPC一致，mTLS证书中的身份可以直接用于认证
+		ctx = peer.NewContext(ctx, &peer.Peer{
+			Addr:     conn.RemoteAddr(),
+			AuthInfo: credentials.TLSInfo{State: tlsConn.ConnectionState()},
+		})
+	}
+	l.handle(ctx, conn)
+}
+
+// stop 关闭监听和所有连接，等待处理中的命令结束
+func (l *connListener) stop() {
+	l.mu.Lock()
+	l.closed = true
+	if l.lis != nil {
+		l.lis.Close()
+	}
+	for conn := range l.conns {
+		conn.Close()
+	}
+	l.mu.Unlock()
+	l.wg.Wait()
+}
+
+func (l *connListener) connections() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.conns)
+}
+
+// authenticateConn 使用token或连接上的mTLS证书认证，未配置认证时返回nil
+func (s *Server) authenticateConn(ctx context.Context, protocol, token string) (*auth.Identity, error) {
+	if s.authn == nil {
+		return nil, nil
+	}
+	if token != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(auth.HeaderAuthorization, "Bearer "+token))
+	}
+	return s.authn.Authenticate(ctx, protocol, nil)
+}
+
+// routeKey 返回key所属的Group。prefix为true时，形如"group:key"且group存在的key路由到该Group并去掉前缀
+func routeKey(group, key string, prefix bool) (string, string) {
+	if prefix {
+		if name, rest, ok := strings.Cut(key, ":"); ok && rest != "" && GetGroup(name) != nil {
+			return name, rest
+		}
+	}
+	return group, key
+}
+
+// getAllConcurrency 批量读取时同时进行的Get数量上限，避免一条MGET占用过多goroutine
+const getAllConcurrency = 16
+
+// getAll 并发读取多个key，groups[i]对应keys[i]。不存在或读取失败的key返回nil，失败时记录日志
+func getAll(ctx context.Context, groups []*Group, keys []string, l *slog.Logger) []*ByteView {
+	views := make([]*ByteView, len(keys))
+	var eg errgroup.Group
+	eg.SetLimit(getAllConcurrency)
+	for i := range keys {
+		eg.Go(func() error {
+			view, err := groups[i].getPlain(ctx, keys[i])
+			if err == nil {
+				views[i] = &view
+			} else if !errors.Is(err, ErrNotFound) {
+				l.Warn("get failed", "group", groups[i].Name(), logger.Key(keys[i]), "error", err)
+			}
+			return nil
+		})
+	}
+	eg.Wait()
+	return views
+}
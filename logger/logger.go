@@ -0,0 +1,144 @@
+package logger
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	levelsMu     sync.RWMutex
+	levels       = make(map[string]*slog.LevelVar)
+	defaultLevel slog.LevelVar // 未单独设置级别的组件使用该级别，默认Info
+
+	redactKeys atomic.Bool
+)
+
+func init() {
+	redactKeys.Store(true)
+}
+
+// SetLevel 设置组件的日志级别，component为空时设置默认级别
+func SetLevel(component string, level slog.Level) {
+	if component == "" {
+		defaultLevel.Set(level)
+		return
+	}
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	v, ok := levels[component]
+	if !ok {
+		v = new(slog.LevelVar)
+		levels[component] = v
+	}
+	v.Set(level)
+}
+
+// Level 返回组件当前生效的日志级别
+func Level(component string) slog.Level {
+	levelsMu.RLock()
+	v, ok := levels[component]
+	levelsMu.RUnlock()
+	if ok {
+		return v.Level()
+	}
+	return defaultLevel.Level()
+}
+
+// New 返回组件logger，日志带component字段并按组件级别过滤。
+// base为nil时每条日志都交给当时的slog.Default()处理，With添加的属性和分组在输出时再应用，
+// 调用方可以在创建logger之后再设置默认logger
+func New(component string, base *slog.Logger) *slog.Logger {
+	h := &componentHandler{component: component}
+	if base != nil {
+		h.inner = base.Handler()
+	}
+	return slog.New(h).With("component", component)
+}
+
+// componentHandler 在内部handler之前按组件级别过滤日志
+type componentHandler struct {
+	component string
+	inner     slog.Handler // 为nil时使用slog.Default()
+	ops       []handlerOp  // inner为nil时，输出前依次应用到默认handler上
+}
+
+// handlerOp 一次WithAttrs或WithGroup调用
+type handlerOp struct {
+	group string
+	attrs []slog.Attr
+}
+
+func (h *componentHandler) handler() slog.Handler {
+	if h.inner != nil {
+		return h.inner
+	}
+	inner := slog.Default().Handler()
+	for _, op := range h.ops {
+		if op.group != "" {
+			inner = inner.WithGroup(op.group)
+		} else {
+			inner = inner.WithAttrs(op.attrs)
+		}
+	}
+	return inner
+}
+
+func (h *componentHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if level < Level(h.component) {
+		return false
+	}
+	if h.inner != nil {
+		return h.inner.Enabled(ctx, level)
+	}
+	return slog.Default().Handler().Enabled(ctx, level)
+}
+
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if h.inner != nil {
+		return &componentHandler{component: h.component, inner: h.inner.WithAttrs(attrs)}
+	}
+	return h.with(handlerOp{attrs: attrs})
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	if h.inner != nil {
+		return &componentHandler{component: h.component, inner: h.inner.WithGroup(name)}
+	}
+	return h.with(handlerOp{group: name})
+}
+
+// with 返回追加了op的副本
+func (h *componentHandler) with(op handlerOp) slog.Handler {
+	ops := make([]handlerOp, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &componentHandler{component: h.component, ops: append(ops, op)}
+}
+
+// SetRedactKeys 设置是否在日志中脱敏缓存key，默认开启
+func SetRedactKeys(enabled bool) {
+	redactKeys.Store(enabled)
+}
+
+// Key 返回缓存key的日志属性，开启脱敏时只输出key的哈希前缀。
+// 脱敏在日志真正输出时才计算，级别被过滤时没有额外开销
+func Key(key string) slog.Attr {
+	return slog.Any("key", redactedKey(key))
+}
+
+type redactedKey string
+
+func (k redactedKey) LogValue() slog.Value {
+	if !redactKeys.Load() {
+		return slog.StringValue(string(k))
+	}
+	sum := sha256.Sum256([]byte(k))
+	return slog.StringValue("sha256:" + hex.EncodeToString(sum[:6]))
+}
@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestComponentLevels(t *testing.T) {
+	var buf bytes.Buffer
+	base := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	group := New("test-group", base)
+	server := New("test-server", base)
+
+	SetLevel("test-group", slog.LevelDebug)
+	defer SetLevel("test-group", slog.LevelInfo)
+
+	group.Debug("group debug")
+	server.Debug("server debug")
+	server.Info("server info")
+
+	out := buf.String()
+	if !strings.Contains(out, "group debug") || !strings.Contains(out, "component=test-group") {
+		t.Fatalf("group debug log missing: %s", out)
+	}
+	if strings.Contains(out, "server debug") {
+		t.Fatalf("server debug log should be filtered: %s", out)
+	}
+	if !strings.Contains(out, "server info") {
+		t.Fatalf("server info log missing: %s", out)
+	}
+}
+
+func TestLazyDefault(t *testing.T) {
+	l := New("test-lazy", nil).With("addr", "a:1").WithGroup("req")
+
+	// 创建logger之后再设置默认logger
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(old)
+
+	l.Info("served", "key", "k")
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expect log written by the new default handler, got %q: %v", buf.String(), err)
+	}
+	req, _ := entry["req"].(map[string]interface{})
+	if entry["component"] != "test-lazy" || entry["addr"] != "a:1" || req["key"] != "k" {
+		t.Fatalf("expect attrs and groups kept structured, got %v", entry)
+	}
+}
+
+func TestKeyRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, nil))
+
+	l.Info("hit", Key("user:42:email"))
+	if strings.Contains(buf.String(), "user:42:email") || !strings.Contains(buf.String(), "key=sha256:") {
+		t.Fatalf("key should be redacted: %s", buf.String())
+	}
+
+	buf.Reset()
+	SetRedactKeys(false)
+	defer SetRedactKeys(true)
+	l.Info("hit", Key("user:42:email"))
+	if !strings.Contains(buf.String(), "key=user:42:email") {
+		t.Fatalf("key should be logged as is: %s", buf.String())
+	}
+}
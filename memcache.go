@@ -0,0 +1,1030 @@
+package gocache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gocache/auth"
+	"gocache/logger"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// memcacheVersion version命令返回的版本
+	memcacheVersion = "1.6.21"
+	// defaultMemcacheItemSize 与memcached的item_size_max默认值一致
+	defaultMemcacheItemSize = 1 << 20
+	memcacheMaxKeyLen       = 250
+	memcacheMaxLineLen      = 8 << 10
+	// memcacheRelativeExpire exptime超过30天时按unix时间戳处理
+	memcacheRelativeExpire = 30 * 24 * 3600
+	// memcacheCASRetries incr/decr、touch等读改写命令遇到并发修改时的重试次数
+	memcacheCASRetries = 16
+)
+
+// MemcacheConfig memcached协议监听配置
+type MemcacheConfig struct {
+	Addr string
+	// Group key默认所属的Group
+	Group string
+	// KeyPrefix 为true时，形如"group:key"且group存在的key路由到该Group，key去掉前缀
+	KeyPrefix bool
+	// MaxItemSize 单个值的最大字节数，默认1MB
+	MaxItemSize int
+}
+
+// WithMemcache 在cfg.Addr上开启memcached文本协议和meta协议监听。
+// flags和CAS版本号随条目保存在key所在节点上，add/replace/cas等条件写入由该节点原子执行。
+// Server配置了TLS时该监听同样使用TLS，配置了认证时只能通过mTLS证书认证
+func WithMemcache(cfg MemcacheConfig) ServerOptions {
+	return func(server *Server) {
+		server.memcache = &memcacheServer{cfg: cfg}
+	}
+}
+
+// memcacheServer memcached协议前端，命令映射到Group操作
+type memcacheServer struct {
+	connListener
+	cfg    MemcacheConfig
+	server *Server
+}
+
+// start 开始监听，由Server.Run调用
+func (m *memcacheServer) start(s *Server) error {
+	if m.cfg.Group == "" {
+		return fmt.Errorf("memcache: group is required")
+	}
+	if m.cfg.MaxItemSize <= 0 {
+		m.cfg.MaxItemSize = defaultMemcacheItemSize
+	}
+	m.server = s
+	m.addr = m.cfg.Addr
+	m.logger = logger.New("memcache", s.baseLogger).With("addr", m.cfg.Addr)
+	m.handle = m.handleConn
+	if err := m.listen(s.tlsConfig); err != nil {
+		return fmt.Errorf("memcache: %v", err)
+	}
+	m.logger.Info("memcache listener is running")
+	return nil
+}
+
+// memcacheError 直接作为一行回复的错误，如"CLIENT_ERROR bad command line format"
+type memcacheError string
+
+func (e memcacheError) Error() string { return string(e) }
+
+var (
+	errMemcacheUnknown   = memcacheError("ERROR")
+	errMemcacheFormat    = memcacheError("CLIENT_ERROR bad command line format")
+	errMemcacheChunk     = memcacheError("CLIENT_ERROR bad data chunk")
+	errMemcacheTooLarge  = memcacheError("SERVER_ERROR object too large for cache")
+	errMemcacheNonNumber = memcacheError("CLIENT_ERROR cannot increment or decrement non-numeric value")
+	errMemcacheDelta     = memcacheError("CLIENT_ERROR invalid numeric delta argument")
+	errMemcacheFlag      = memcacheError("CLIENT_ERROR invalid flag")
+	errMemcacheNoAuth    = memcacheError("CLIENT_ERROR authentication required")
+	errMemcacheBusy      = memcacheError("SERVER_ERROR too many concurrent modifications")
+)
+
+// memcacheConn 一个客户端连接的状态
+type memcacheConn struct {
+	srv *memcacheServer
+	ctx context.Context
+	r   *bufio.Reader
+	w   *bufio.Writer
+	id  *auth.Identity
+	// noreply 当前命令带有noreply，成功时不写回复
+	noreply bool
+}
+
+func (m *memcacheServer) handleConn(ctx context.Context, conn net.Conn) {
+	c := &memcacheConn{
+		srv: m,
+		ctx: ctx,
+		r:   bufio.NewReader(conn),
+		w:   bufio.NewWriter(conn),
+	}
+	if _, ok := conn.(*tls.Conn); ok {
+		if id, err := m.server.authenticateConn(ctx, "memcache", ""); err == nil {
+			c.id = id
+		}
+	}
+
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			if errors.Is(err, errMemcacheFormat) {
+				c.writeError(err)
+				c.w.Flush()
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				m.logger.Debug("read command failed", "remote", conn.RemoteAddr().String(), "error", err)
+			}
+			return
+		}
+
+		quit, err := c.dispatch(strings.Fields(line))
+		if err != nil {
+			// 数据块格式错误后无法确定下一条命令的起始位置，只能关闭连接
+			c.writeError(err)
+			c.w.Flush()
+			return
+		}
+		// 客户端使用pipeline时合并写回
+		if c.r.Buffered() == 0 || quit {
+			if err := c.w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// readLine 读取一行命令，不包含行尾的CRLF
+func (c *memcacheConn) readLine() (string, error) {
+	var line []byte
+	for {
+		chunk, err := c.r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return "", err
+		}
+		if len(line) > memcacheMaxLineLen {
+			return "", errMemcacheFormat
+		}
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// readData 读取n字节的数据块及其后的CRLF
+func (c *memcacheConn) readData(n int) ([]byte, error) {
+	if n > c.srv.cfg.MaxItemSize {
+		// 丢弃数据块，连接可以继续使用
+		if _, err := io.CopyN(io.Discard, c.r, int64(n)+2); err != nil {
+			return nil, err
+		}
+		return nil, errMemcacheTooLarge
+	}
+	buf := make([]byte, n+2)
+	if _, err := io.ReadFull(c.r, buf); err != nil {
+		return nil, err
+	}
+	if buf[n] != '\r' || buf[n+1] != '\n' {
+		return nil, errMemcacheChunk
+	}
+	return buf[:n], nil
+}
+
+// reply 写入一行回复，命令带有noreply时忽略
+func (c *memcacheConn) reply(format string, args ...interface{}) {
+	if c.noreply {
+		return
+	}
+	fmt.Fprintf(c.w, format, args...)
+	c.w.WriteString("\r\n")
+}
+
+// writeError 写入错误回复，错误不受noreply影响
+func (c *memcacheConn) writeError(err error) {
+	var me memcacheError
+	if !errors.As(err, &me) {
+		me = memcacheError("SERVER_ERROR " + strings.NewReplacer("\r", " ", "\n", " ").Replace(err.Error()))
+	}
+	c.w.WriteString(string(me))
+	c.w.WriteString("\r\n")
+}
+
+// authorize 检查当前身份对group的操作权限
+func (c *memcacheConn) authorize(group string, op auth.Operation) error {
+	s := c.srv.server
+	if s.authn == nil {
+		return nil
+	}
+	if c.id == nil {
+		return errMemcacheNoAuth
+	}
+	if !s.acl.Allowed(c.id.Name, group, op) {
+		return memcacheError(fmt.Sprintf("CLIENT_ERROR %s is not allowed to %s group %s", c.id.Name, op, group))
+	}
+	return nil
+}
+
+// group 校验key并解析其所属的Group，返回去掉前缀后的key
+func (c *memcacheConn) group(key string, op auth.Operation) (*Group, string, error) {
+	if !validMemcacheKey(key) {
+		return nil, "", errMemcacheFormat
+	}
+	return c.route(key, op)
+}
+
+// route 解析key所属的Group并检查权限
+func (c *memcacheConn) route(key string, op auth.Operation) (*Group, string, error) {
+	name, key := routeKey(c.srv.cfg.Group, key, c.srv.cfg.KeyPrefix)
+	if err := c.authorize(name, op); err != nil {
+		return nil, "", err
+	}
+	g := GetGroup(name)
+	if g == nil {
+		return nil, "", memcacheError(fmt.Sprintf("SERVER_ERROR group %s not exist", name))
+	}
+	return g, key, nil
+}
+
+// validMemcacheKey key最长250字节，不能包含空白和控制字符
+func validMemcacheKey(key string) bool {
+	if len(key) == 0 || len(key) > memcacheMaxKeyLen {
+		return false
+	}
+	return strings.IndexFunc(key, func(r rune) bool { return r <= ' ' || r == 0x7f }) < 0
+}
+
+// dispatch 执行一条命令，返回是否需要关闭连接。返回error表示连接已无法继续使用
+func (c *memcacheConn) dispatch(args []string) (bool, error) {
+	c.noreply = false
+	if len(args) == 0 {
+		c.writeError(errMemcacheUnknown)
+		return false, nil
+	}
+
+	var err error
+	switch cmd := args[0]; cmd {
+	case "get", "gets":
+		err = c.get(args[1:], cmd == "gets", nil)
+	case "gat", "gats":
+		err = c.gat(args, cmd == "gats")
+	case "set", "add", "replace", "append", "prepend", "cas":
+		err = c.store(args)
+	case "delete":
+		err = c.delete(args)
+	case "incr", "decr":
+		err = c.incr(args)
+	case "touch":
+		err = c.touch(args)
+	case "mg":
+		err = c.metaGet(args)
+	case "ms":
+		err = c.metaSet(args)
+	case "md":
+		err = c.metaDelete(args)
+	case "ma":
+		err = c.metaArithmetic(args)
+	case "mn":
+		c.reply("MN")
+	case "stats":
+		err = c.stats(args)
+	case "version":
+		c.reply("VERSION %s", memcacheVersion)
+	case "verbosity":
+		c.noreply = args[len(args)-1] == "noreply"
+		c.reply("OK")
+	case "quit":
+		return true, nil
+	default:
+		err = errMemcacheUnknown
+	}
+
+	if errors.Is(err, errMemcacheChunk) || isConnError(err) {
+		return false, err
+	}
+	if err != nil {
+		c.writeError(err)
+	}
+	return false, nil
+}
+
+// isConnError 读取数据块时连接出错
+func isConnError(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed)
+}
+
+// parseNoreply 去掉末尾的noreply参数
+func (c *memcacheConn) parseNoreply(args []string) []string {
+	if n := len(args); n > 0 && args[n-1] == "noreply" {
+		c.noreply = true
+		return args[:n-1]
+	}
+	return args
+}
+
+// memcacheTTL 将exptime转换为TTL。超过30天的值为unix时间戳，负数或已过去的时间戳表示立即过期
+func memcacheTTL(exptime int64) (ttl time.Duration, expired bool) {
+	switch {
+	case exptime == 0:
+		return 0, false
+	case exptime < 0:
+		return 0, true
+	case exptime > memcacheRelativeExpire:
+		ttl = time.Until(time.Unix(exptime, 0))
+		if ttl <= 0 {
+			return 0, true
+		}
+		return ttl, false
+	}
+	return time.Duration(exptime) * time.Second, false
+}
+
+func parseExptime(s string) (time.Duration, bool, error) {
+	exptime, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, false, errMemcacheFormat
+	}
+	ttl, expired := memcacheTTL(exptime)
+	return ttl, expired, nil
+}
+
+// write 写入条目，已过期时在条件满足后原子地删除条目，与memcached保存已过期条目的效果一致
+func (c *memcacheConn) write(g *Group, key string, value []byte, opts WriteOptions, expired bool) (uint64, error) {
+	opts.Expire = expired
+	return g.Write(c.ctx, key, value, opts)
+}
+
+// fetch 并发读取多个key，不存在或读取失败的key返回nil
+func (c *memcacheConn) fetch(keys []string, op auth.Operation) ([]*ByteView, error) {
+	groups := make([]*Group, len(keys))
+	names := make([]string, len(keys))
+	for i, arg := range keys {
+		g, key, err := c.group(arg, op)
+		if err != nil {
+			return nil, err
+		}
+		groups[i], names[i] = g, key
+	}
+
+	return getAll(c.ctx, groups, names, c.srv.logger), nil
+}
+
+// get get|gets <key>*，views不为nil时使用已读取的结果
+func (c *memcacheConn) get(keys []string, withCAS bool, views []*ByteView) error {
+	if len(keys) == 0 {
+		return errMemcacheUnknown
+	}
+	if views == nil {
+		var err error
+		if views, err = c.fetch(keys, auth.OpRead); err != nil {
+			return err
+		}
+	}
+	for i, view := range views {
+		if view == nil {
+			continue
+		}
+		data := view.data()
+		if withCAS {
+			c.reply("VALUE %s %d %d %d", keys[i], view.Flags(), len(data), view.CAS())
+		} else {
+			c.reply("VALUE %s %d %d", keys[i], view.Flags(), len(data))
+		}
+		c.w.Write(data)
+		c.w.WriteString("\r\n")
+	}
+	c.reply("END")
+	return nil
+}
+
+// gat gat|gats <exptime> <key>*
+func (c *memcacheConn) gat(args []string, withCAS bool) error {
+	if len(args) < 3 {
+		return errMemcacheUnknown
+	}
+	ttl, expired, err := parseExptime(args[1])
+	if err != nil {
+		return err
+	}
+	keys := args[2:]
+	views := make([]*ByteView, len(keys))
+	for i, arg := range keys {
+		g, key, err := c.group(arg, auth.OpRead|auth.OpWrite)
+		if err != nil {
+			return err
+		}
+		view, err := c.touchKey(g, key, ttl, expired)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		views[i] = &view
+	}
+	return c.get(keys, withCAS, views)
+}
+
+// store <cmd> <key> <flags> <exptime> <bytes> [cas unique] [noreply]
+func (c *memcacheConn) store(args []string) error {
+	cmd := args[0]
+	args = c.parseNoreply(args)
+	want := 5
+	if cmd == "cas" {
+		want = 6
+	}
+	if len(args) != want {
+		return errMemcacheFormat
+	}
+	n, err := strconv.Atoi(args[4])
+	if err != nil || n < 0 {
+		return errMemcacheFormat
+	}
+	// 参数错误时也要读走数据块
+	value, err := c.readData(n)
+	if err != nil {
+		return err
+	}
+
+	flags, err := strconv.ParseUint(args[2], 10, 32)
+	if err != nil {
+		return errMemcacheFormat
+	}
+	ttl, expired, err := parseExptime(args[3])
+	if err != nil {
+		return err
+	}
+	opts := WriteOptions{TTL: ttl, Flags: uint32(flags)}
+	switch cmd {
+	case "add":
+		opts.Mode = WriteAdd
+	case "replace":
+		opts.Mode = WriteReplace
+	case "append":
+		opts.Mode = WriteAppend
+	case "prepend":
+		opts.Mode = WritePrepend
+	case "cas":
+		if opts.CAS, err = strconv.ParseUint(args[5], 10, 64); err != nil {
+			return errMemcacheFormat
+		}
+	}
+
+	g, key, err := c.group(args[1], auth.OpWrite)
+	if err != nil {
+		return err
+	}
+	_, err = c.write(g, key, value, opts, expired)
+	switch {
+	case err == nil:
+		c.reply("STORED")
+	case errors.Is(err, ErrNotStored):
+		c.reply("NOT_STORED")
+	case errors.Is(err, ErrCASConflict):
+		c.reply("EXISTS")
+	case errors.Is(err, ErrNotFound):
+		c.reply("NOT_FOUND")
+	default:
+		return err
+	}
+	return nil
+}
+
+// delete delete <key> [0] [noreply]
+func (c *memcacheConn) delete(args []string) error {
+	args = c.parseNoreply(args)
+	if len(args) == 3 && args[2] == "0" {
+		args = args[:2]
+	}
+	if len(args) != 2 {
+		return memcacheError("CLIENT_ERROR bad command line format.  Usage: delete <key> [noreply]")
+	}
+	g, key, err := c.group(args[1], auth.OpDelete)
+	if err != nil {
+		return err
+	}
+	deleted, err := g.Delete(c.ctx, key)
+	if err != nil {
+		return err
+	}
+	if deleted {
+		c.reply("DELETED")
+	} else {
+		c.reply("NOT_FOUND")
+	}
+	return nil
+}
+
+// incr incr|decr <key> <value> [noreply]
+func (c *memcacheConn) incr(args []string) error {
+	incr := args[0] == "incr"
+	args = c.parseNoreply(args)
+	if len(args) != 3 {
+		return errMemcacheFormat
+	}
+	delta, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		return errMemcacheDelta
+	}
+	g, key, err := c.group(args[1], auth.OpRead|auth.OpWrite)
+	if err != nil {
+		return err
+	}
+	view, err := c.arith(g, key, arithOptions{delta: delta, incr: incr})
+	if errors.Is(err, ErrNotFound) {
+		c.reply("NOT_FOUND")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.reply("%s", view.data())
+	return nil
+}
+
+// touch touch <key> <exptime> [noreply]
+func (c *memcacheConn) touch(args []string) error {
+	args = c.parseNoreply(args)
+	if len(args) != 3 {
+		return errMemcacheFormat
+	}
+	ttl, expired, err := parseExptime(args[2])
+	if err != nil {
+		return err
+	}
+	g, key, err := c.group(args[1], auth.OpWrite)
+	if err != nil {
+		return err
+	}
+	_, err = c.touchKey(g, key, ttl, expired)
+	if errors.Is(err, ErrNotFound) {
+		c.reply("NOT_FOUND")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.reply("TOUCHED")
+	return nil
+}
+
+// touchKey 以CAS方式重写条目来更新过期时间，返回更新后的条目。只读取缓存，key不在缓存中时返回ErrNotFound
+func (c *memcacheConn) touchKey(g *Group, key string, ttl time.Duration, expired bool) (ByteView, error) {
+	for i := 0; i < memcacheCASRetries; i++ {
+		view, err := g.lookup(c.ctx, key)
+		if err != nil {
+			return ByteView{}, err
+		}
+		data, err := view.plain()
+		if err != nil {
+			return ByteView{}, ErrNotFound
+		}
+		cas, err := c.write(g, key, data, WriteOptions{TTL: ttl, Flags: view.f, ContentType: view.t, CAS: view.c}, expired)
+		if errors.Is(err, ErrCASConflict) || errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return ByteView{}, err
+		}
+		view.c, view.e = cas, expireAt(g, ttl)
+		return view, nil
+	}
+	return ByteView{}, errMemcacheBusy
+}
+
+// expireAt 返回按ttl写入g的条目的过期时间，ttl<=0时与写入一样使用Group的默认TTL
+func expireAt(g *Group, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		ttl = g.mainCache.ttl
+	}
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+type arithOptions struct {
+	delta uint64
+	incr  bool
+	cas   uint64 // 不为0时要求当前条目的版本号相同
+	ttl   *time.Duration
+	// vivify 不为nil时key不存在则以initial创建，过期时间为*vivify
+	vivify  *time.Duration
+	initial uint64
+}
+
+// arith incr/decr的实现，以CAS方式重试保证并发安全。incr按64位无符号数回绕，decr最小为0。只读取缓存，不调用Getter
+func (c *memcacheConn) arith(g *Group, key string, opts arithOptions) (ByteView, error) {
+	for i := 0; i < memcacheCASRetries; i++ {
+		view, err := g.lookup(c.ctx, key)
+		if errors.Is(err, ErrNotFound) && opts.vivify != nil {
+			value := []byte(strconv.FormatUint(opts.initial, 10))
+			cas, err := g.Write(c.ctx, key, value, WriteOptions{TTL: *opts.vivify, Mode: WriteAdd})
+			if errors.Is(err, ErrNotStored) {
+				continue
+			}
+			if err != nil {
+				return ByteView{}, err
+			}
+			return ByteView{b: value, c: cas, e: expireAt(g, *opts.vivify)}, nil
+		}
+		if err != nil {
+			return ByteView{}, err
+		}
+		if opts.cas != 0 && view.c != opts.cas {
+			return ByteView{}, ErrCASConflict
+		}
+
+		n, err := strconv.ParseUint(strings.TrimSpace(view.String()), 10, 64)
+		if err != nil {
+			return ByteView{}, errMemcacheNonNumber
+		}
+		switch {
+		case opts.incr:
+			n += opts.delta
+		case n < opts.delta:
+			n = 0
+		default:
+			n -= opts.delta
+		}
+
+		ttl := view.TTL()
+		if opts.ttl != nil {
+			ttl = *opts.ttl
+		}
+		value := []byte(strconv.FormatUint(n, 10))
+		cas, err := g.Write(c.ctx, key, value, WriteOptions{TTL: ttl, Flags: view.f, ContentType: view.t, CAS: view.c})
+		if errors.Is(err, ErrCASConflict) || errors.Is(err, ErrNotFound) {
+			if opts.cas != 0 {
+				return ByteView{}, ErrCASConflict
+			}
+			continue
+		}
+		if err != nil {
+			return ByteView{}, err
+		}
+		return ByteView{b: value, f: view.f, c: cas, t: view.t, e: expireAt(g, ttl)}, nil
+	}
+	return ByteView{}, errMemcacheBusy
+}
+
+// stats 返回本节点上默认Group的统计
+func (c *memcacheConn) stats(args []string) error {
+	if len(args) > 1 {
+		// 不支持stats items、stats slabs等子命令，返回空结果
+		c.reply("END")
+		return nil
+	}
+	name := c.srv.cfg.Group
+	if err := c.authorize(name, auth.OpRead); err != nil {
+		return err
+	}
+	g := GetGroup(name)
+	if g == nil {
+		return memcacheError(fmt.Sprintf("SERVER_ERROR group %s not exist", name))
+	}
+	s := g.Stats()
+	c.reply("STAT pid %d", os.Getpid())
+	c.reply("STAT time %d", time.Now().Unix())
+	c.reply("STAT version %s", memcacheVersion)
+	c.reply("STAT curr_connections %d", c.srv.connections())
+	c.reply("STAT cmd_get %d", s.Gets)
+	c.reply("STAT get_hits %d", s.Hits)
+	c.reply("STAT get_misses %d", s.Misses)
+	c.reply("STAT curr_items %d", s.Items)
+	c.reply("STAT bytes %d", s.Bytes)
+	c.reply("STAT evictions %d", s.Evictions)
+	c.reply("STAT expired_unfetched %d", s.Expirations)
+	c.reply("END")
+	return nil
+}
+
+// metaFlags meta命令的标记，每个标记为一个字母，后面可以跟一个token
+type metaFlags []string
+
+// parseMetaFlags 解析标记，allowed为支持的标记字母
+func parseMetaFlags(args []string, allowed string) (metaFlags, error) {
+	for _, arg := range args {
+		if !strings.ContainsRune(allowed, rune(arg[0])) {
+			return nil, errMemcacheFlag
+		}
+	}
+	return metaFlags(args), nil
+}
+
+func (f metaFlags) has(flag byte) bool {
+	_, ok := f.token(flag)
+	return ok
+}
+
+func (f metaFlags) token(flag byte) (string, bool) {
+	for _, arg := range f {
+		if arg[0] == flag {
+			return arg[1:], true
+		}
+	}
+	return "", false
+}
+
+// ttl 解析T、N等以秒为单位的标记
+func (f metaFlags) ttl(flag byte) (*time.Duration, bool, error) {
+	s, ok := f.token(flag)
+	if !ok {
+		return nil, false, nil
+	}
+	ttl, expired, err := parseExptime(s)
+	if err != nil {
+		return nil, false, errMemcacheFlag
+	}
+	return &ttl, expired, nil
+}
+
+func (f metaFlags) uint(flag byte, bits int) (uint64, bool, error) {
+	s, ok := f.token(flag)
+	if !ok {
+		return 0, false, nil
+	}
+	n, err := strconv.ParseUint(s, 10, bits)
+	if err != nil {
+		return 0, false, errMemcacheFlag
+	}
+	return n, true, nil
+}
+
+// metaKey 解析meta命令的key，带b标记时key为base64编码，可以是任意字节
+func metaKey(key string, flags metaFlags) (string, error) {
+	if !validMemcacheKey(key) {
+		return "", errMemcacheFormat
+	}
+	if !flags.has('b') {
+		return key, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return "", errMemcacheFormat
+	}
+	return string(b), nil
+}
+
+// metaReturn 按请求中标记的顺序生成返回标记
+func metaReturn(flags metaFlags, key string, view ByteView) string {
+	var b strings.Builder
+	for _, arg := range flags {
+		switch arg[0] {
+		case 'c':
+			fmt.Fprintf(&b, " c%d", view.CAS())
+		case 'f':
+			fmt.Fprintf(&b, " f%d", view.Flags())
+		case 'k':
+			if flags.has('b') {
+				fmt.Fprintf(&b, " k%s b", base64.StdEncoding.EncodeToString([]byte(key)))
+			} else {
+				fmt.Fprintf(&b, " k%s", key)
+			}
+		case 'O':
+			fmt.Fprintf(&b, " O%s", arg[1:])
+		case 's':
+			fmt.Fprintf(&b, " s%d", view.Len())
+		case 't':
+			if ttl := view.TTL(); ttl > 0 {
+				fmt.Fprintf(&b, " t%d", int64((ttl+time.Second-1)/time.Second))
+			} else {
+				b.WriteString(" t-1")
+			}
+		}
+	}
+	return b.String()
+}
+
+// metaStatus 写入meta命令的状态回复，quiet为true时不写，用于q标记隐藏的状态
+func (c *memcacheConn) metaStatus(quiet bool, code, ret string) {
+	if quiet {
+		return
+	}
+	c.w.WriteString(code)
+	c.w.WriteString(ret)
+	c.w.WriteString("\r\n")
+}
+
+// metaValue 写入VA或HD回复，q标记只隐藏HD
+func (c *memcacheConn) metaValue(flags metaFlags, key string, view ByteView) {
+	view = view.decompressed()
+	ret := metaReturn(flags, key, view)
+	if !flags.has('v') {
+		c.metaStatus(flags.has('q'), "HD", ret)
+		return
+	}
+	fmt.Fprintf(c.w, "VA %d%s\r\n", view.Len(), ret)
+	c.w.Write(view.b)
+	c.w.WriteString("\r\n")
+}
+
+// metaGet mg <key> <flags>*
+func (c *memcacheConn) metaGet(args []string) error {
+	if len(args) < 2 {
+		return errMemcacheFormat
+	}
+	flags, err := parseMetaFlags(args[2:], "bcfhklOqstTv")
+	if err != nil {
+		return err
+	}
+	key, err := metaKey(args[1], flags)
+	if err != nil {
+		return err
+	}
+
+	ttl, expired, err := flags.ttl('T')
+	if err != nil {
+		return err
+	}
+	op := auth.OpRead
+	if ttl != nil {
+		op |= auth.OpWrite
+	}
+	g, name, err := c.route(key, op)
+	if err != nil {
+		return err
+	}
+
+	var view ByteView
+	if ttl != nil {
+		view, err = c.touchKey(g, name, *ttl, expired)
+	} else {
+		view, err = g.getPlain(c.ctx, name)
+	}
+	if errors.Is(err, ErrNotFound) {
+		c.metaStatus(flags.has('q'), "EN", "")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.metaValue(flags, key, view)
+	return nil
+}
+
+// metaSet ms <key> <datalen> <flags>*
+func (c *memcacheConn) metaSet(args []string) error {
+	if len(args) < 3 {
+		return errMemcacheFormat
+	}
+	n, err := strconv.Atoi(args[2])
+	if err != nil || n < 0 {
+		return errMemcacheFormat
+	}
+	value, err := c.readData(n)
+	if err != nil {
+		return err
+	}
+
+	flags, err := parseMetaFlags(args[3:], "bcCFIkOqTM")
+	if err != nil {
+		return err
+	}
+	key, err := metaKey(args[1], flags)
+	if err != nil {
+		return err
+	}
+	if flags.has('I') {
+		// 不支持标记为失效(stale)的语义
+		return errMemcacheFlag
+	}
+
+	var opts WriteOptions
+	itemFlags, _, err := flags.uint('F', 32)
+	if err != nil {
+		return err
+	}
+	opts.Flags = uint32(itemFlags)
+	if opts.CAS, _, err = flags.uint('C', 64); err != nil {
+		return err
+	}
+	ttl, expired, err := flags.ttl('T')
+	if err != nil {
+		return err
+	}
+	if ttl != nil {
+		opts.TTL = *ttl
+	}
+	if mode, ok := flags.token('M'); ok {
+		switch mode {
+		case "S", "s":
+			opts.Mode = WriteSet
+		case "E", "e":
+			opts.Mode = WriteAdd
+		case "R", "r":
+			opts.Mode = WriteReplace
+		case "A", "a":
+			opts.Mode = WriteAppend
+		case "P", "p":
+			opts.Mode = WritePrepend
+		default:
+			return errMemcacheFlag
+		}
+	}
+
+	g, name, err := c.route(key, auth.OpWrite)
+	if err != nil {
+		return err
+	}
+	cas, err := c.write(g, name, value, opts, expired)
+	switch {
+	case err == nil:
+		c.metaStatus(flags.has('q'), "HD", metaReturn(flags, key, ByteView{b: value, f: opts.Flags, c: cas, e: expireAt(g, opts.TTL)}))
+	case errors.Is(err, ErrNotStored):
+		c.metaStatus(false, "NS", metaReturn(flags, key, ByteView{}))
+	case errors.Is(err, ErrCASConflict):
+		c.metaStatus(false, "EX", metaReturn(flags, key, ByteView{}))
+	case errors.Is(err, ErrNotFound):
+		c.metaStatus(false, "NF", metaReturn(flags, key, ByteView{}))
+	default:
+		return err
+	}
+	return nil
+}
+
+// metaDelete md <key> <flags>*，不支持C、I标记
+func (c *memcacheConn) metaDelete(args []string) error {
+	if len(args) < 2 {
+		return errMemcacheFormat
+	}
+	flags, err := parseMetaFlags(args[2:], "bkOq")
+	if err != nil {
+		return err
+	}
+	key, err := metaKey(args[1], flags)
+	if err != nil {
+		return err
+	}
+
+	g, name, err := c.route(key, auth.OpDelete)
+	if err != nil {
+		return err
+	}
+	deleted, err := g.Delete(c.ctx, name)
+	if err != nil {
+		return err
+	}
+	// 删除成功和key不存在都是q标记隐藏的状态
+	if deleted {
+		c.metaStatus(flags.has('q'), "HD", metaReturn(flags, key, ByteView{}))
+	} else {
+		c.metaStatus(flags.has('q'), "NF", metaReturn(flags, key, ByteView{}))
+	}
+	return nil
+}
+
+// metaArithmetic ma <key> <flags>*
+func (c *memcacheConn) metaArithmetic(args []string) error {
+	if len(args) < 2 {
+		return errMemcacheFormat
+	}
+	flags, err := parseMetaFlags(args[2:], "bcCDJkMNOqtTv")
+	if err != nil {
+		return err
+	}
+	key, err := metaKey(args[1], flags)
+	if err != nil {
+		return err
+	}
+
+	opts := arithOptions{delta: 1, incr: true}
+	if delta, ok, err := flags.uint('D', 64); err != nil {
+		return errMemcacheDelta
+	} else if ok {
+		opts.delta = delta
+	}
+	if opts.initial, _, err = flags.uint('J', 64); err != nil {
+		return err
+	}
+	if opts.cas, _, err = flags.uint('C', 64); err != nil {
+		return err
+	}
+	if opts.ttl, _, err = flags.ttl('T'); err != nil {
+		return err
+	}
+	if opts.vivify, _, err = flags.ttl('N'); err != nil {
+		return err
+	}
+	if mode, ok := flags.token('M'); ok {
+		switch mode {
+		case "I", "i", "+":
+		case "D", "d", "-":
+			opts.incr = false
+		default:
+			return errMemcacheFlag
+		}
+	}
+
+	g, name, err := c.route(key, auth.OpRead|auth.OpWrite)
+	if err != nil {
+		return err
+	}
+	view, err := c.arith(g, name, opts)
+	switch {
+	case err == nil:
+		c.metaValue(flags, key, view)
+	case errors.Is(err, ErrNotFound):
+		c.metaStatus(false, "NF", metaReturn(flags, key, ByteView{}))
+	case errors.Is(err, ErrCASConflict):
+		c.metaStatus(false, "EX", metaReturn(flags, key, ByteView{}))
+	default:
+		return err
+	}
+	return nil
+}
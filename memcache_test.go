@@ -0,0 +1,232 @@
+package gocache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"gocache/auth"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memcacheClient 按行收发的测试客户端
+type memcacheClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func startMemcache(t *testing.T, cfg MemcacheConfig, opts ...ServerOptions) *memcacheClient {
+	t.Helper()
+	cfg.Addr = "127.0.0.1:0"
+	server, err := NewServer("localhost:9999", append(opts, WithMemcache(cfg))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.memcache.start(server); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.memcache.stop)
+
+	conn, err := net.Dial("tcp", server.memcache.lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	t.Cleanup(func() { conn.Close() })
+	return &memcacheClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+// do 发送请求并读取n行回复
+func (c *memcacheClient) do(req string, n int) string {
+	c.t.Helper()
+	if _, err := fmt.Fprint(c.conn, req); err != nil {
+		c.t.Fatal(err)
+	}
+	var lines []string
+	for i := 0; i < n; i++ {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("%q: read reply: %v", req, err)
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\r\n"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (c *memcacheClient) expect(req string, want ...string) {
+	c.t.Helper()
+	if got := c.do(req, len(want)); got != strings.Join(want, "\n") {
+		c.t.Fatalf("%q: got %q, want %q", req, got, strings.Join(want, "\n"))
+	}
+}
+
+func TestMemcache_TextProtocol(t *testing.T) {
+	newProtocolGroup(t, "mc-text")
+	newProtocolGroup(t, "mc-other")
+	c := startMemcache(t, MemcacheConfig{Group: "mc-text", KeyPrefix: true, MaxItemSize: 16})
+
+	c.expect("set k 42 0 5\r\nhello\r\n", "STORED")
+	c.expect("get k missing\r\n", "VALUE k 42 5", "hello", "END")
+	c.expect("get loaded\r\n", "VALUE loaded 0 11", "from-getter", "END")
+	c.expect("add k 0 0 1\r\nx\r\n", "NOT_STORED")
+	c.expect("replace nokey 0 0 1\r\nx\r\n", "NOT_STORED")
+	c.expect("append k 0 0 1\r\n!\r\n", "STORED")
+	c.expect("prepend k 0 0 1\r\n>\r\n", "STORED")
+	c.expect("get k\r\n", "VALUE k 42 7", ">hello!", "END")
+
+	var cas uint64
+	fmt.Sscanf(c.do("gets k\r\n", 3), "VALUE k 42 7 %d", &cas)
+	c.expect(fmt.Sprintf("cas k 1 0 1 %d\r\nx\r\n", cas+1), "EXISTS")
+	c.expect(fmt.Sprintf("cas k 1 0 1 %d\r\nx\r\n", cas), "STORED")
+	c.expect(fmt.Sprintf("cas k 1 0 1 %d\r\nx\r\n", cas), "EXISTS")
+	c.expect("cas nokey 0 0 1 1\r\nx\r\n", "NOT_FOUND")
+
+	c.expect("set n 0 0 2\r\n10\r\n", "STORED")
+	c.expect("incr n 5\r\n", "15")
+	c.expect("decr n 100\r\n", "0")
+	c.expect("incr k 1\r\n", "CLIENT_ERROR cannot increment or decrement non-numeric value")
+	c.expect("incr nokey 1\r\n", "NOT_FOUND")
+	c.expect("incr n abc\r\n", "CLIENT_ERROR invalid numeric delta argument")
+
+	c.expect("touch n 100\r\n", "TOUCHED")
+	c.expect("touch nokey 100\r\n", "NOT_FOUND")
+	c.expect("gat 0 n\r\n", "VALUE n 0 1", "0", "END")
+	c.expect("delete n\r\n", "DELETED")
+	c.expect("delete n\r\n", "NOT_FOUND")
+	c.expect("set gone 0 -1 1\r\nx\r\n", "STORED")
+	c.expect("get gone\r\n", "END")
+
+	// noreply不返回成功回复，后续命令的回复仍然按序返回
+	c.expect("set q 0 0 1 noreply\r\nq\r\ndelete q noreply\r\nget q\r\n", "END")
+
+	c.expect("set mc-other:k 3 0 2\r\nok\r\n", "STORED")
+	if v, err := GetGroup("mc-other").Get(context.Background(), "k"); err != nil || v.String() != "ok" || v.Flags() != 3 {
+		t.Fatalf("expect key prefix routing, got %q %v", v.String(), err)
+	}
+
+	c.expect("set big 0 0 17\r\n01234567890123456\r\n", "SERVER_ERROR object too large for cache")
+	c.expect("get\r\n", "ERROR")
+	c.expect("bogus\r\n", "ERROR")
+	c.expect("set k 0 0 x\r\n", "CLIENT_ERROR bad command line format")
+	c.expect("version\r\n", "VERSION "+memcacheVersion)
+	c.expect("set k 0 0 1\r\nxy\r\n", "CLIENT_ERROR bad data chunk")
+}
+
+func TestMemcache_MetaProtocol(t *testing.T) {
+	newProtocolGroup(t, "mc-meta")
+	c := startMemcache(t, MemcacheConfig{Group: "mc-meta"})
+
+	c.expect("ms foo 3 F9 T100 Oab k\r\nbar\r\n", "HD Oab kfoo")
+	c.expect("mg foo v f s t\r\n", "VA 3 f9 s3 t100", "bar")
+	c.expect("mg missing v\r\n", "EN")
+	c.expect("ms foo 1 ME\r\nx\r\n", "NS")
+	c.expect("ms foo 1 MA\r\n!\r\n", "HD")
+
+	var cas uint64
+	fmt.Sscanf(c.do("mg foo c\r\n", 1), "HD c%d", &cas)
+	c.expect(fmt.Sprintf("ms foo 1 C%d\r\nx\r\n", cas+1), "EX")
+	c.expect(fmt.Sprintf("ms foo 1 C%d\r\nx\r\n", cas), "HD")
+	c.expect("mg foo v\r\n", "VA 1", "x")
+
+	// base64编码的key可以包含空格
+	c.expect("ms YSBi 1 b k\r\n1\r\n", "HD kYSBi b")
+	if v, err := GetGroup("mc-meta").Get(context.Background(), "a b"); err != nil || v.String() != "1" {
+		t.Fatalf("expect binary key stored, got %q %v", v.String(), err)
+	}
+
+	c.expect("ma cnt\r\n", "NF")
+	c.expect("ma cnt N0 J10 v\r\n", "VA 2", "10")
+	c.expect("ma cnt D5 v\r\n", "VA 2", "15")
+	c.expect("ma cnt MD D20 v\r\n", "VA 1", "0")
+
+	// q隐藏成功和未命中的回复，mn用于标记pipeline结束
+	c.expect("mg missing v q\r\nms foo 1 q\r\ny\r\nmd foo q\r\nmd foo q\r\nmn\r\n", "MN")
+	c.expect("md foo\r\n", "NF")
+	c.expect("mg foo x\r\n", "CLIENT_ERROR invalid flag")
+}
+
+func TestMemcache_CacheOnly(t *testing.T) {
+	var loads atomic.Int32
+	g := NewGroup("mc-cache-only", 1<<20, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		loads.Add(1)
+		return []byte("1"), true, time.Time{}
+	}))
+	t.Cleanup(func() { DestroyGroup("mc-cache-only") })
+	c := startMemcache(t, MemcacheConfig{Group: "mc-cache-only"})
+
+	// touch、incr和gat只读取缓存，与memcached一样对未缓存的key返回NOT_FOUND
+	c.expect("touch k 100\r\n", "NOT_FOUND")
+	c.expect("incr k 1\r\n", "NOT_FOUND")
+	c.expect("gat 100 k\r\n", "END")
+	c.expect("mg k v T100\r\n", "EN")
+	if n := loads.Load(); n != 0 {
+		t.Fatalf("expect getter not called, got %d calls", n)
+	}
+
+	// 重写条目时保留ContentType
+	if _, err := g.Write(context.Background(), "k", []byte("1"), WriteOptions{ContentType: "text/plain"}); err != nil {
+		t.Fatal(err)
+	}
+	c.expect("touch k 100\r\n", "TOUCHED")
+	c.expect("incr k 1\r\n", "2")
+	if v, ok := g.mainCache.get("k"); !ok || v.ContentType() != "text/plain" {
+		t.Fatalf("expect content type kept, got %q %v", v.ContentType(), ok)
+	}
+
+	// 已过期的写入删除条目
+	c.expect("touch k -1\r\n", "TOUCHED")
+	if _, ok := g.mainCache.get("k"); ok {
+		t.Fatal("expect expired touch to remove the entry")
+	}
+}
+
+func TestMemcache_Auth(t *testing.T) {
+	newProtocolGroup(t, "mc-auth")
+	c := startMemcache(t, MemcacheConfig{Group: "mc-auth"},
+		WithAuth(auth.TokenAuthenticator{"secret": "svc"}, auth.NewACL()))
+
+	c.expect("get k\r\n", "CLIENT_ERROR authentication required")
+	c.expect("version\r\n", "VERSION "+memcacheVersion)
+}
+
+func TestMemcacheTTL(t *testing.T) {
+	tests := []struct {
+		exptime int64
+		ttl     time.Duration
+		expired bool
+	}{
+		{0, 0, false},
+		{-1, 0, true},
+		{60, time.Minute, false},
+		{memcacheRelativeExpire, memcacheRelativeExpire * time.Second, false},
+		{time.Now().Add(-time.Hour).Unix(), 0, true},
+	}
+	for _, tt := range tests {
+		ttl, expired := memcacheTTL(tt.exptime)
+		if ttl != tt.ttl || expired != tt.expired {
+			t.Errorf("memcacheTTL(%d) = %v %v, want %v %v", tt.exptime, ttl, expired, tt.ttl, tt.expired)
+		}
+	}
+	if ttl, _ := memcacheTTL(time.Now().Add(time.Hour).Unix()); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("unexpected ttl %v for absolute exptime", ttl)
+	}
+}
+
+func TestMemcache_DefaultTTL(t *testing.T) {
+	NewGroup("mc-default-ttl", 1<<20, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		return nil, false, time.Time{}
+	}), WithDefaultTTL(time.Hour))
+	t.Cleanup(func() { DestroyGroup("mc-default-ttl") })
+	c := startMemcache(t, MemcacheConfig{Group: "mc-default-ttl"})
+
+	// exptime为0时使用默认TTL，回复与写入的过期时间一致
+	c.expect("set foo 0 0 1\r\nx\r\n", "STORED")
+	c.expect("mg foo t\r\n", "HD t3600")
+	c.expect("mg foo T100 t\r\n", "HD t100")
+	c.expect("mg foo T0 t\r\n", "HD t3600")
+	c.expect("mg foo t\r\n", "HD t3600")
+}
@@ -0,0 +1,188 @@
+package gocache
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const metricsNamespace = "gocache"
+
+var (
+	// metricsRegistry 所有gocache指标注册在独立的registry中，避免污染调用方的默认registry
+	metricsRegistry = prometheus.NewRegistry()
+
+	peerRPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "peer",
+		Name:      "rpc_duration_seconds",
+		Help:      "Latency of RPCs sent to peers.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 3},
+	}, []string{"peer", "method", "code"})
+
+	// pickers 当前存活的ClientPicker，用于导出哈希环统计
+	pickersMu sync.RWMutex
+	pickers   = make(map[*ClientPicker]struct{})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		peerRPCDuration,
+		groupCollector{},
+		ringCollector{},
+	)
+}
+
+// MetricsHandler 返回暴露Prometheus指标的http.Handler，便于挂载到调用方自己的HTTP服务
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// RegisterMetrics 向gocache的registry注册额外的指标
+func RegisterMetrics(cs ...prometheus.Collector) error {
+	for _, c := range cs {
+		if err := metricsRegistry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	groupLabels = []string{"group"}
+
+	groupGetsDesc            = groupDesc("gets_total", "Number of Get calls.")
+	groupHitsDesc            = groupDesc("hits_total", "Number of Gets served from the local cache.")
+	groupMissesDesc          = groupDesc("misses_total", "Number of Gets not served from the local cache.")
+	groupPeerLoadsDesc       = groupDesc("peer_loads_total", "Number of values loaded from peers.")
+	groupPeerErrorsDesc      = groupDesc("peer_errors_total", "Number of failed loads from peers.")
+	groupLocalLoadsDesc      = groupDesc("loads_total", "Number of values loaded by the Getter.")
+	groupLocalLoadErrorsDesc = groupDesc("load_errors_total", "Number of failed Getter loads.")
+	groupDedupsDesc          = groupDesc("singleflight_dedups_total", "Number of Gets deduplicated by singleflight.")
+	groupStaleHitsDesc       = groupDesc("stale_hits_total", "Number of hits served from soft-expired entries.")
+	groupRefreshesDesc       = groupDesc("refreshes_total", "Number of background refreshes by the Getter.")
+
+	storeBytesDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "store", "bytes"),
+		"Bytes used by the group's store.", groupLabels, nil)
+	storeEntriesDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "store", "entries"),
+		"Number of entries in the group's store.", groupLabels, nil)
+	storeEvictionsDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "store", "evictions_total"),
+		"Number of entries removed from the group's store by reason.", []string{"group", "reason"}, nil)
+	compressionBytesDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "compression", "bytes_total"),
+		"Bytes of values considered for compression, before (in) and after (out) compressing.", []string{"group", "stage"}, nil)
+
+	ringLoadDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "ring", "load_ratio"),
+		"Share of keys routed to each node since the last rebalance.", []string{"service", "node"}, nil)
+	peerAvailableDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "peer", "available"),
+		"Whether the peer is healthy and its circuit breaker is not open.", []string{"service", "peer"}, nil)
+)
+
+func groupDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "group", name), help, groupLabels, nil)
+}
+
+// groupCollector 在采集时读取所有Group的计数和存储统计
+type groupCollector struct{}
+
+func (groupCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		groupGetsDesc, groupHitsDesc, groupMissesDesc, groupPeerLoadsDesc, groupPeerErrorsDesc,
+		groupLocalLoadsDesc, groupLocalLoadErrorsDesc, groupDedupsDesc, groupStaleHitsDesc, groupRefreshesDesc,
+		storeBytesDesc, storeEntriesDesc, storeEvictionsDesc, compressionBytesDesc,
+	} {
+		ch <- d
+	}
+}
+
+func (groupCollector) Collect(ch chan<- prometheus.Metric) {
+	lock.RLock()
+	snapshot := make([]*Group, 0, len(groups))
+	for _, g := range groups {
+		snapshot = append(snapshot, g)
+	}
+	lock.RUnlock()
+
+	counter := func(desc *prometheus.Desc, v int64, name string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(v), name)
+	}
+	for _, g := range snapshot {
+		stats := g.Stats()
+		counter(groupGetsDesc, stats.Gets, g.name)
+		counter(groupHitsDesc, stats.Hits, g.name)
+		counter(groupMissesDesc, stats.Misses, g.name)
+		counter(groupPeerLoadsDesc, stats.PeerLoads, g.name)
+		counter(groupPeerErrorsDesc, stats.PeerErrors, g.name)
+		counter(groupLocalLoadsDesc, stats.LocalLoads, g.name)
+		counter(groupLocalLoadErrorsDesc, stats.LocalLoadErrors, g.name)
+		counter(groupDedupsDesc, stats.Dedups, g.name)
+		counter(groupStaleHitsDesc, stats.StaleHits, g.name)
+		counter(groupRefreshesDesc, stats.Refreshes, g.name)
+
+		ch <- prometheus.MustNewConstMetric(storeBytesDesc, prometheus.GaugeValue, float64(stats.Bytes), g.name)
+		ch <- prometheus.MustNewConstMetric(storeEntriesDesc, prometheus.GaugeValue, float64(stats.Items), g.name)
+		ch <- prometheus.MustNewConstMetric(storeEvictionsDesc, prometheus.CounterValue, float64(stats.Evictions), g.name, "capacity")
+		ch <- prometheus.MustNewConstMetric(storeEvictionsDesc, prometheus.CounterValue, float64(stats.Expirations), g.name, "expired")
+		ch <- prometheus.MustNewConstMetric(compressionBytesDesc, prometheus.CounterValue, float64(stats.CompressionInBytes), g.name, "in")
+		ch <- prometheus.MustNewConstMetric(compressionBytesDesc, prometheus.CounterValue, float64(stats.CompressionOutBytes), g.name, "out")
+	}
+}
+
+// ringCollector 导出所有ClientPicker的哈希环负载分布和peer可用性
+type ringCollector struct{}
+
+func (ringCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ringLoadDesc
+	ch <- peerAvailableDesc
+}
+
+func (ringCollector) Collect(ch chan<- prometheus.Metric) {
+	pickersMu.RLock()
+	defer pickersMu.RUnlock()
+
+	for p := range pickers {
+		for node, ratio := range p.consHash.GetStats() {
+			ch <- prometheus.MustNewConstMetric(ringLoadDesc, prometheus.GaugeValue, ratio, p.svcName, node)
+		}
+		p.mu.RLock()
+		for addr, client := range p.clients {
+			available := 0.0
+			if client.Available() {
+				available = 1
+			}
+			ch <- prometheus.MustNewConstMetric(peerAvailableDesc, prometheus.GaugeValue, available, p.svcName, addr)
+		}
+		p.mu.RUnlock()
+	}
+}
+
+func trackPicker(p *ClientPicker) {
+	pickersMu.Lock()
+	pickers[p] = struct{}{}
+	pickersMu.Unlock()
+}
+
+func untrackPicker(p *ClientPicker) {
+	pickersMu.Lock()
+	delete(pickers, p)
+	pickersMu.Unlock()
+}
+
+// peerMetricsInterceptor 记录发往peer的每个RPC的延迟
+func peerMetricsInterceptor(addr string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		peerRPCDuration.WithLabelValues(addr, path.Base(method), status.Code(err).String()).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
@@ -0,0 +1,33 @@
+package gocache
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	g := NewGroup("metrics-test", 1<<10, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		return []byte("v"), true, time.Time{}
+	}))
+	defer DestroyGroup("metrics-test")
+
+	g.Get(context.Background(), "k")
+	g.Get(context.Background(), "k")
+
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`gocache_group_gets_total{group="metrics-test"} 2`,
+		`gocache_group_hits_total{group="metrics-test"} 1`,
+		`gocache_group_loads_total{group="metrics-test"} 1`,
+		`gocache_store_entries{group="metrics-test"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+}
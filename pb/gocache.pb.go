@@ -22,12 +22,20 @@ const (
 )
 
 type Request struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Group         string                 `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
-	Key           string                 `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
-	Value         []byte                 `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Group            string                 `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	Key              string                 `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Value            []byte                 `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	TtlMs            int64                  `protobuf:"varint,4,opt,name=ttl_ms,json=ttlMs,proto3" json:"ttl_ms,omitempty"` // Set时条目的过期时间，0表示使用Group的默认TTL
+	Flags            uint32                 `protobuf:"varint,5,opt,name=flags,proto3" json:"flags,omitempty"`              // Set时随值保存的客户端标记
+	Cas              uint64                 `protobuf:"varint,6,opt,name=cas,proto3" json:"cas,omitempty"`                  // Set时不为0表示仅在条目版本号相同时写入
+	Mode             int32                  `protobuf:"varint,7,opt,name=mode,proto3" json:"mode,omitempty"`                // Set的写入模式，对应gocache.WriteMode
+	ContentType      string                 `protobuf:"bytes,8,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	AcceptCompressed bool                   `protobuf:"varint,9,opt,name=accept_compressed,json=acceptCompressed,proto3" json:"accept_compressed,omitempty"` // Get时客户端能够解压压缩的值
+	CacheOnly        bool                   `protobuf:"varint,10,opt,name=cache_only,json=cacheOnly,proto3" json:"cache_only,omitempty"`                     // Get时只读取缓存，不调用Getter
+	Expire           bool                   `protobuf:"varint,11,opt,name=expire,proto3" json:"expire,omitempty"`                                            // Set时条件满足后删除条目而不是写入
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
 }
 
 func (x *Request) Reset() {
@@ -81,9 +89,70 @@ func (x *Request) GetValue() []byte {
 	return nil
 }
 
+func (x *Request) GetTtlMs() int64 {
+	if x != nil {
+		return x.TtlMs
+	}
+	return 0
+}
+
+func (x *Request) GetFlags() uint32 {
+	if x != nil {
+		return x.Flags
+	}
+	return 0
+}
+
+func (x *Request) GetCas() uint64 {
+	if x != nil {
+		return x.Cas
+	}
+	return 0
+}
+
+func (x *Request) GetMode() int32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+func (x *Request) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *Request) GetAcceptCompressed() bool {
+	if x != nil {
+		return x.AcceptCompressed
+	}
+	return false
+}
+
+func (x *Request) GetCacheOnly() bool {
+	if x != nil {
+		return x.CacheOnly
+	}
+	return false
+}
+
+func (x *Request) GetExpire() bool {
+	if x != nil {
+		return x.Expire
+	}
+	return false
+}
+
 type ResponseForGet struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Value         []byte                 `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	TtlMs         int64                  `protobuf:"varint,2,opt,name=ttl_ms,json=ttlMs,proto3" json:"ttl_ms,omitempty"` // 条目的剩余过期时间，0表示不过期
+	Flags         uint32                 `protobuf:"varint,3,opt,name=flags,proto3" json:"flags,omitempty"`
+	Cas           uint64                 `protobuf:"varint,4,opt,name=cas,proto3" json:"cas,omitempty"` // 条目的版本号，Set时为新写入条目的版本号
+	ContentType   string                 `protobuf:"bytes,5,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	Compression   string                 `protobuf:"bytes,6,opt,name=compression,proto3" json:"compression,omitempty"` // value的压缩算法，为空时未压缩
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -125,6 +194,41 @@ func (x *ResponseForGet) GetValue() []byte {
 	return nil
 }
 
+func (x *ResponseForGet) GetTtlMs() int64 {
+	if x != nil {
+		return x.TtlMs
+	}
+	return 0
+}
+
+func (x *ResponseForGet) GetFlags() uint32 {
+	if x != nil {
+		return x.Flags
+	}
+	return 0
+}
+
+func (x *ResponseForGet) GetCas() uint64 {
+	if x != nil {
+		return x.Cas
+	}
+	return 0
+}
+
+func (x *ResponseForGet) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *ResponseForGet) GetCompression() string {
+	if x != nil {
+		return x.Compression
+	}
+	return ""
+}
+
 type ResponseForDelete struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Value         bool                   `protobuf:"varint,1,opt,name=value,proto3" json:"value,omitempty"`
@@ -213,25 +317,843 @@ func (x *ResponseForSet) GetSuccess() bool {
 	return false
 }
 
-var File_gocache_proto protoreflect.FileDescriptor
+type StatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Group         string                 `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-const file_gocache_proto_rawDesc = "" +
-	"\n" +
-	"\rgocache.proto\x12\x05proto\"G\n" +
-	"\aRequest\x12\x14\n" +
-	"\x05group\x18\x01 \x01(\tR\x05group\x12\x10\n" +
-	"\x03key\x18\x02 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x03 \x01(\fR\x05value\"&\n" +
-	"\x0eResponseForGet\x12\x14\n" +
-	"\x05value\x18\x01 \x01(\fR\x05value\")\n" +
-	"\x11ResponseForDelete\x12\x14\n" +
-	"\x05value\x18\x01 \x01(\bR\x05value\"*\n" +
-	"\x0eResponseForSet\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess2\x99\x01\n" +
-	"\aGoCache\x12,\n" +
-	"\x03Get\x12\x0e.proto.Request\x1a\x15.proto.ResponseForGet\x12,\n" +
-	"\x03Set\x12\x0e.proto.Request\x1a\x15.proto.ResponseForGet\x122\n" +
-	"\x06Delete\x12\x0e.proto.Request\x1a\x18.proto.ResponseForDeleteB\x04Z\x02./b\x06proto3"
+func (x *StatsRequest) Reset() {
+	*x = StatsRequest{}
+	mi := &file_gocache_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsRequest) ProtoMessage() {}
+
+func (x *StatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gocache_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsRequest.ProtoReflect.Descriptor instead.
+func (*StatsRequest) Descriptor() ([]byte, []int) {
+	return file_gocache_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *StatsRequest) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+type ResponseForStats struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	Group               string                 `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	Gets                int64                  `protobuf:"varint,2,opt,name=gets,proto3" json:"gets,omitempty"`
+	Hits                int64                  `protobuf:"varint,3,opt,name=hits,proto3" json:"hits,omitempty"`
+	Misses              int64                  `protobuf:"varint,4,opt,name=misses,proto3" json:"misses,omitempty"`
+	PeerLoads           int64                  `protobuf:"varint,5,opt,name=peer_loads,json=peerLoads,proto3" json:"peer_loads,omitempty"`
+	PeerErrors          int64                  `protobuf:"varint,6,opt,name=peer_errors,json=peerErrors,proto3" json:"peer_errors,omitempty"`
+	LocalLoads          int64                  `protobuf:"varint,7,opt,name=local_loads,json=localLoads,proto3" json:"local_loads,omitempty"`
+	LocalLoadErrors     int64                  `protobuf:"varint,8,opt,name=local_load_errors,json=localLoadErrors,proto3" json:"local_load_errors,omitempty"`
+	Dedups              int64                  `protobuf:"varint,9,opt,name=dedups,proto3" json:"dedups,omitempty"`
+	LoadLatencyP50Us    int64                  `protobuf:"varint,10,opt,name=load_latency_p50_us,json=loadLatencyP50Us,proto3" json:"load_latency_p50_us,omitempty"`
+	LoadLatencyP90Us    int64                  `protobuf:"varint,11,opt,name=load_latency_p90_us,json=loadLatencyP90Us,proto3" json:"load_latency_p90_us,omitempty"`
+	LoadLatencyP99Us    int64                  `protobuf:"varint,12,opt,name=load_latency_p99_us,json=loadLatencyP99Us,proto3" json:"load_latency_p99_us,omitempty"`
+	Bytes               int64                  `protobuf:"varint,13,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	Items               int64                  `protobuf:"varint,14,opt,name=items,proto3" json:"items,omitempty"`
+	Evictions           uint64                 `protobuf:"varint,15,opt,name=evictions,proto3" json:"evictions,omitempty"`
+	Expirations         uint64                 `protobuf:"varint,16,opt,name=expirations,proto3" json:"expirations,omitempty"`
+	CompressionInBytes  int64                  `protobuf:"varint,17,opt,name=compression_in_bytes,json=compressionInBytes,proto3" json:"compression_in_bytes,omitempty"`    // 尝试压缩的值压缩前的累计字节数
+	CompressionOutBytes int64                  `protobuf:"varint,18,opt,name=compression_out_bytes,json=compressionOutBytes,proto3" json:"compression_out_bytes,omitempty"` // 这些值实际保存的累计字节数
+	StaleHits           int64                  `protobuf:"varint,19,opt,name=stale_hits,json=staleHits,proto3" json:"stale_hits,omitempty"`                                 // 返回了软过期的旧值，同时计入hits
+	Refreshes           int64                  `protobuf:"varint,20,opt,name=refreshes,proto3" json:"refreshes,omitempty"`                                                  // 软过期、提前刷新和XFetch触发的后台加载
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *ResponseForStats) Reset() {
+	*x = ResponseForStats{}
+	mi := &file_gocache_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResponseForStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResponseForStats) ProtoMessage() {}
+
+func (x *ResponseForStats) ProtoReflect() protoreflect.Message {
+	mi := &file_gocache_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResponseForStats.ProtoReflect.Descriptor instead.
+func (*ResponseForStats) Descriptor() ([]byte, []int) {
+	return file_gocache_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ResponseForStats) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *ResponseForStats) GetGets() int64 {
+	if x != nil {
+		return x.Gets
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetHits() int64 {
+	if x != nil {
+		return x.Hits
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetMisses() int64 {
+	if x != nil {
+		return x.Misses
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetPeerLoads() int64 {
+	if x != nil {
+		return x.PeerLoads
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetPeerErrors() int64 {
+	if x != nil {
+		return x.PeerErrors
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetLocalLoads() int64 {
+	if x != nil {
+		return x.LocalLoads
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetLocalLoadErrors() int64 {
+	if x != nil {
+		return x.LocalLoadErrors
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetDedups() int64 {
+	if x != nil {
+		return x.Dedups
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetLoadLatencyP50Us() int64 {
+	if x != nil {
+		return x.LoadLatencyP50Us
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetLoadLatencyP90Us() int64 {
+	if x != nil {
+		return x.LoadLatencyP90Us
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetLoadLatencyP99Us() int64 {
+	if x != nil {
+		return x.LoadLatencyP99Us
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetItems() int64 {
+	if x != nil {
+		return x.Items
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetEvictions() uint64 {
+	if x != nil {
+		return x.Evictions
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetExpirations() uint64 {
+	if x != nil {
+		return x.Expirations
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetCompressionInBytes() int64 {
+	if x != nil {
+		return x.CompressionInBytes
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetCompressionOutBytes() int64 {
+	if x != nil {
+		return x.CompressionOutBytes
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetStaleHits() int64 {
+	if x != nil {
+		return x.StaleHits
+	}
+	return 0
+}
+
+func (x *ResponseForStats) GetRefreshes() int64 {
+	if x != nil {
+		return x.Refreshes
+	}
+	return 0
+}
+
+type AdminRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Group         string                 `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AdminRequest) Reset() {
+	*x = AdminRequest{}
+	mi := &file_gocache_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AdminRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AdminRequest) ProtoMessage() {}
+
+func (x *AdminRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gocache_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AdminRequest.ProtoReflect.Descriptor instead.
+func (*AdminRequest) Descriptor() ([]byte, []int) {
+	return file_gocache_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *AdminRequest) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+type ListGroupsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Groups        []string               `protobuf:"bytes,1,rep,name=groups,proto3" json:"groups,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListGroupsResponse) Reset() {
+	*x = ListGroupsResponse{}
+	mi := &file_gocache_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListGroupsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListGroupsResponse) ProtoMessage() {}
+
+func (x *ListGroupsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gocache_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListGroupsResponse.ProtoReflect.Descriptor instead.
+func (*ListGroupsResponse) Descriptor() ([]byte, []int) {
+	return file_gocache_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListGroupsResponse) GetGroups() []string {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+type FlushResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Removed       int64                  `protobuf:"varint,1,opt,name=removed,proto3" json:"removed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FlushResponse) Reset() {
+	*x = FlushResponse{}
+	mi := &file_gocache_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FlushResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FlushResponse) ProtoMessage() {}
+
+func (x *FlushResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gocache_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FlushResponse.ProtoReflect.Descriptor instead.
+func (*FlushResponse) Descriptor() ([]byte, []int) {
+	return file_gocache_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *FlushResponse) GetRemoved() int64 {
+	if x != nil {
+		return x.Removed
+	}
+	return 0
+}
+
+type ResizeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Group         string                 `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	MaxBytes      int64                  `protobuf:"varint,2,opt,name=max_bytes,json=maxBytes,proto3" json:"max_bytes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResizeRequest) Reset() {
+	*x = ResizeRequest{}
+	mi := &file_gocache_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResizeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResizeRequest) ProtoMessage() {}
+
+func (x *ResizeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gocache_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResizeRequest.ProtoReflect.Descriptor instead.
+func (*ResizeRequest) Descriptor() ([]byte, []int) {
+	return file_gocache_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ResizeRequest) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *ResizeRequest) GetMaxBytes() int64 {
+	if x != nil {
+		return x.MaxBytes
+	}
+	return 0
+}
+
+type ResizeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OldMaxBytes   int64                  `protobuf:"varint,1,opt,name=old_max_bytes,json=oldMaxBytes,proto3" json:"old_max_bytes,omitempty"`
+	MaxBytes      int64                  `protobuf:"varint,2,opt,name=max_bytes,json=maxBytes,proto3" json:"max_bytes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResizeResponse) Reset() {
+	*x = ResizeResponse{}
+	mi := &file_gocache_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResizeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResizeResponse) ProtoMessage() {}
+
+func (x *ResizeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gocache_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResizeResponse.ProtoReflect.Descriptor instead.
+func (*ResizeResponse) Descriptor() ([]byte, []int) {
+	return file_gocache_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ResizeResponse) GetOldMaxBytes() int64 {
+	if x != nil {
+		return x.OldMaxBytes
+	}
+	return 0
+}
+
+func (x *ResizeResponse) GetMaxBytes() int64 {
+	if x != nil {
+		return x.MaxBytes
+	}
+	return 0
+}
+
+type RingNode struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Addr          string                 `protobuf:"bytes,1,opt,name=addr,proto3" json:"addr,omitempty"`
+	Replicas      int32                  `protobuf:"varint,2,opt,name=replicas,proto3" json:"replicas,omitempty"`
+	LoadRatio     float64                `protobuf:"fixed64,3,opt,name=load_ratio,json=loadRatio,proto3" json:"load_ratio,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RingNode) Reset() {
+	*x = RingNode{}
+	mi := &file_gocache_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RingNode) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RingNode) ProtoMessage() {}
+
+func (x *RingNode) ProtoReflect() protoreflect.Message {
+	mi := &file_gocache_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RingNode.ProtoReflect.Descriptor instead.
+func (*RingNode) Descriptor() ([]byte, []int) {
+	return file_gocache_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *RingNode) GetAddr() string {
+	if x != nil {
+		return x.Addr
+	}
+	return ""
+}
+
+func (x *RingNode) GetReplicas() int32 {
+	if x != nil {
+		return x.Replicas
+	}
+	return 0
+}
+
+func (x *RingNode) GetLoadRatio() float64 {
+	if x != nil {
+		return x.LoadRatio
+	}
+	return 0
+}
+
+type RingResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Nodes         []*RingNode            `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RingResponse) Reset() {
+	*x = RingResponse{}
+	mi := &file_gocache_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RingResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RingResponse) ProtoMessage() {}
+
+func (x *RingResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gocache_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RingResponse.ProtoReflect.Descriptor instead.
+func (*RingResponse) Descriptor() ([]byte, []int) {
+	return file_gocache_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *RingResponse) GetNodes() []*RingNode {
+	if x != nil {
+		return x.Nodes
+	}
+	return nil
+}
+
+type PeerInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Addr          string                 `protobuf:"bytes,1,opt,name=addr,proto3" json:"addr,omitempty"`
+	Healthy       bool                   `protobuf:"varint,2,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	BreakerState  string                 `protobuf:"bytes,3,opt,name=breaker_state,json=breakerState,proto3" json:"breaker_state,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PeerInfo) Reset() {
+	*x = PeerInfo{}
+	mi := &file_gocache_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeerInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerInfo) ProtoMessage() {}
+
+func (x *PeerInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_gocache_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerInfo.ProtoReflect.Descriptor instead.
+func (*PeerInfo) Descriptor() ([]byte, []int) {
+	return file_gocache_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *PeerInfo) GetAddr() string {
+	if x != nil {
+		return x.Addr
+	}
+	return ""
+}
+
+func (x *PeerInfo) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+func (x *PeerInfo) GetBreakerState() string {
+	if x != nil {
+		return x.BreakerState
+	}
+	return ""
+}
+
+type PeersResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Self          string                 `protobuf:"bytes,1,opt,name=self,proto3" json:"self,omitempty"`
+	Peers         []*PeerInfo            `protobuf:"bytes,2,rep,name=peers,proto3" json:"peers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PeersResponse) Reset() {
+	*x = PeersResponse{}
+	mi := &file_gocache_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeersResponse) ProtoMessage() {}
+
+func (x *PeersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gocache_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeersResponse.ProtoReflect.Descriptor instead.
+func (*PeersResponse) Descriptor() ([]byte, []int) {
+	return file_gocache_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *PeersResponse) GetSelf() string {
+	if x != nil {
+		return x.Self
+	}
+	return ""
+}
+
+func (x *PeersResponse) GetPeers() []*PeerInfo {
+	if x != nil {
+		return x.Peers
+	}
+	return nil
+}
+
+type RebalanceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Nodes         []*RingNode            `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RebalanceResponse) Reset() {
+	*x = RebalanceResponse{}
+	mi := &file_gocache_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RebalanceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RebalanceResponse) ProtoMessage() {}
+
+func (x *RebalanceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gocache_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RebalanceResponse.ProtoReflect.Descriptor instead.
+func (*RebalanceResponse) Descriptor() ([]byte, []int) {
+	return file_gocache_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *RebalanceResponse) GetNodes() []*RingNode {
+	if x != nil {
+		return x.Nodes
+	}
+	return nil
+}
+
+var File_gocache_proto protoreflect.FileDescriptor
+
+const file_gocache_proto_rawDesc = "" +
+	"\n" +
+	"\rgocache.proto\x12\x05proto\"\xa1\x02\n" +
+	"\aRequest\x12\x14\n" +
+	"\x05group\x18\x01 \x01(\tR\x05group\x12\x10\n" +
+	"\x03key\x18\x02 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x03 \x01(\fR\x05value\x12\x15\n" +
+	"\x06ttl_ms\x18\x04 \x01(\x03R\x05ttlMs\x12\x14\n" +
+	"\x05flags\x18\x05 \x01(\rR\x05flags\x12\x10\n" +
+	"\x03cas\x18\x06 \x01(\x04R\x03cas\x12\x12\n" +
+	"\x04mode\x18\a \x01(\x05R\x04mode\x12!\n" +
+	"\fcontent_type\x18\b \x01(\tR\vcontentType\x12+\n" +
+	"\x11accept_compressed\x18\t \x01(\bR\x10acceptCompressed\x12\x1d\n" +
+	"\n" +
+	"cache_only\x18\n" +
+	" \x01(\bR\tcacheOnly\x12\x16\n" +
+	"\x06expire\x18\v \x01(\bR\x06expire\"\xaa\x01\n" +
+	"\x0eResponseForGet\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\fR\x05value\x12\x15\n" +
+	"\x06ttl_ms\x18\x02 \x01(\x03R\x05ttlMs\x12\x14\n" +
+	"\x05flags\x18\x03 \x01(\rR\x05flags\x12\x10\n" +
+	"\x03cas\x18\x04 \x01(\x04R\x03cas\x12!\n" +
+	"\fcontent_type\x18\x05 \x01(\tR\vcontentType\x12 \n" +
+	"\vcompression\x18\x06 \x01(\tR\vcompression\")\n" +
+	"\x11ResponseForDelete\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\bR\x05value\"*\n" +
+	"\x0eResponseForSet\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\"$\n" +
+	"\fStatsRequest\x12\x14\n" +
+	"\x05group\x18\x01 \x01(\tR\x05group\"\xa9\x05\n" +
+	"\x10ResponseForStats\x12\x14\n" +
+	"\x05group\x18\x01 \x01(\tR\x05group\x12\x12\n" +
+	"\x04gets\x18\x02 \x01(\x03R\x04gets\x12\x12\n" +
+	"\x04hits\x18\x03 \x01(\x03R\x04hits\x12\x16\n" +
+	"\x06misses\x18\x04 \x01(\x03R\x06misses\x12\x1d\n" +
+	"\n" +
+	"peer_loads\x18\x05 \x01(\x03R\tpeerLoads\x12\x1f\n" +
+	"\vpeer_errors\x18\x06 \x01(\x03R\n" +
+	"peerErrors\x12\x1f\n" +
+	"\vlocal_loads\x18\a \x01(\x03R\n" +
+	"localLoads\x12*\n" +
+	"\x11local_load_errors\x18\b \x01(\x03R\x0flocalLoadErrors\x12\x16\n" +
+	"\x06dedups\x18\t \x01(\x03R\x06dedups\x12-\n" +
+	"\x13load_latency_p50_us\x18\n" +
+	" \x01(\x03R\x10loadLatencyP50Us\x12-\n" +
+	"\x13load_latency_p90_us\x18\v \x01(\x03R\x10loadLatencyP90Us\x12-\n" +
+	"\x13load_latency_p99_us\x18\f \x01(\x03R\x10loadLatencyP99Us\x12\x14\n" +
+	"\x05bytes\x18\r \x01(\x03R\x05bytes\x12\x14\n" +
+	"\x05items\x18\x0e \x01(\x03R\x05items\x12\x1c\n" +
+	"\tevictions\x18\x0f \x01(\x04R\tevictions\x12 \n" +
+	"\vexpirations\x18\x10 \x01(\x04R\vexpirations\x120\n" +
+	"\x14compression_in_bytes\x18\x11 \x01(\x03R\x12compressionInBytes\x122\n" +
+	"\x15compression_out_bytes\x18\x12 \x01(\x03R\x13compressionOutBytes\x12\x1d\n" +
+	"\n" +
+	"stale_hits\x18\x13 \x01(\x03R\tstaleHits\x12\x1c\n" +
+	"\trefreshes\x18\x14 \x01(\x03R\trefreshes\"$\n" +
+	"\fAdminRequest\x12\x14\n" +
+	"\x05group\x18\x01 \x01(\tR\x05group\",\n" +
+	"\x12ListGroupsResponse\x12\x16\n" +
+	"\x06groups\x18\x01 \x03(\tR\x06groups\")\n" +
+	"\rFlushResponse\x12\x18\n" +
+	"\aremoved\x18\x01 \x01(\x03R\aremoved\"B\n" +
+	"\rResizeRequest\x12\x14\n" +
+	"\x05group\x18\x01 \x01(\tR\x05group\x12\x1b\n" +
+	"\tmax_bytes\x18\x02 \x01(\x03R\bmaxBytes\"Q\n" +
+	"\x0eResizeResponse\x12\"\n" +
+	"\rold_max_bytes\x18\x01 \x01(\x03R\voldMaxBytes\x12\x1b\n" +
+	"\tmax_bytes\x18\x02 \x01(\x03R\bmaxBytes\"Y\n" +
+	"\bRingNode\x12\x12\n" +
+	"\x04addr\x18\x01 \x01(\tR\x04addr\x12\x1a\n" +
+	"\breplicas\x18\x02 \x01(\x05R\breplicas\x12\x1d\n" +
+	"\n" +
+	"load_ratio\x18\x03 \x01(\x01R\tloadRatio\"5\n" +
+	"\fRingResponse\x12%\n" +
+	"\x05nodes\x18\x01 \x03(\v2\x0f.proto.RingNodeR\x05nodes\"]\n" +
+	"\bPeerInfo\x12\x12\n" +
+	"\x04addr\x18\x01 \x01(\tR\x04addr\x12\x18\n" +
+	"\ahealthy\x18\x02 \x01(\bR\ahealthy\x12#\n" +
+	"\rbreaker_state\x18\x03 \x01(\tR\fbreakerState\"J\n" +
+	"\rPeersResponse\x12\x12\n" +
+	"\x04self\x18\x01 \x01(\tR\x04self\x12%\n" +
+	"\x05peers\x18\x02 \x03(\v2\x0f.proto.PeerInfoR\x05peers\":\n" +
+	"\x11RebalanceResponse\x12%\n" +
+	"\x05nodes\x18\x01 \x03(\v2\x0f.proto.RingNodeR\x05nodes2\xd0\x01\n" +
+	"\aGoCache\x12,\n" +
+	"\x03Get\x12\x0e.proto.Request\x1a\x15.proto.ResponseForGet\x12,\n" +
+	"\x03Set\x12\x0e.proto.Request\x1a\x15.proto.ResponseForGet\x122\n" +
+	"\x06Delete\x12\x0e.proto.Request\x1a\x18.proto.ResponseForDelete\x125\n" +
+	"\x05Stats\x12\x13.proto.StatsRequest\x1a\x17.proto.ResponseForStats2\x8e\x03\n" +
+	"\x05Admin\x12<\n" +
+	"\n" +
+	"ListGroups\x12\x13.proto.AdminRequest\x1a\x19.proto.ListGroupsResponse\x12:\n" +
+	"\n" +
+	"GroupStats\x12\x13.proto.AdminRequest\x1a\x17.proto.ResponseForStats\x122\n" +
+	"\x05Flush\x12\x13.proto.AdminRequest\x1a\x14.proto.FlushResponse\x125\n" +
+	"\x06Resize\x12\x14.proto.ResizeRequest\x1a\x15.proto.ResizeResponse\x120\n" +
+	"\x04Ring\x12\x13.proto.AdminRequest\x1a\x13.proto.RingResponse\x122\n" +
+	"\x05Peers\x12\x13.proto.AdminRequest\x1a\x14.proto.PeersResponse\x12:\n" +
+	"\tRebalance\x12\x13.proto.AdminRequest\x1a\x18.proto.RebalanceResponseB\x04Z\x02./b\x06proto3"
 
 var (
 	file_gocache_proto_rawDescOnce sync.Once
@@ -245,25 +1167,56 @@ func file_gocache_proto_rawDescGZIP() []byte {
 	return file_gocache_proto_rawDescData
 }
 
-var file_gocache_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_gocache_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
 var file_gocache_proto_goTypes = []any{
-	(*Request)(nil),           // 0: proto.Request
-	(*ResponseForGet)(nil),    // 1: proto.ResponseForGet
-	(*ResponseForDelete)(nil), // 2: proto.ResponseForDelete
-	(*ResponseForSet)(nil),    // 3: proto.ResponseForSet
+	(*Request)(nil),            // 0: proto.Request
+	(*ResponseForGet)(nil),     // 1: proto.ResponseForGet
+	(*ResponseForDelete)(nil),  // 2: proto.ResponseForDelete
+	(*ResponseForSet)(nil),     // 3: proto.ResponseForSet
+	(*StatsRequest)(nil),       // 4: proto.StatsRequest
+	(*ResponseForStats)(nil),   // 5: proto.ResponseForStats
+	(*AdminRequest)(nil),       // 6: proto.AdminRequest
+	(*ListGroupsResponse)(nil), // 7: proto.ListGroupsResponse
+	(*FlushResponse)(nil),      // 8: proto.FlushResponse
+	(*ResizeRequest)(nil),      // 9: proto.ResizeRequest
+	(*ResizeResponse)(nil),     // 10: proto.ResizeResponse
+	(*RingNode)(nil),           // 11: proto.RingNode
+	(*RingResponse)(nil),       // 12: proto.RingResponse
+	(*PeerInfo)(nil),           // 13: proto.PeerInfo
+	(*PeersResponse)(nil),      // 14: proto.PeersResponse
+	(*RebalanceResponse)(nil),  // 15: proto.RebalanceResponse
 }
 var file_gocache_proto_depIdxs = []int32{
-	0, // 0: proto.GoCache.Get:input_type -> proto.Request
-	0, // 1: proto.GoCache.Set:input_type -> proto.Request
-	0, // 2: proto.GoCache.Delete:input_type -> proto.Request
-	1, // 3: proto.GoCache.Get:output_type -> proto.ResponseForGet
-	1, // 4: proto.GoCache.Set:output_type -> proto.ResponseForGet
-	2, // 5: proto.GoCache.Delete:output_type -> proto.ResponseForDelete
-	3, // [3:6] is the sub-list for method output_type
-	0, // [0:3] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	11, // 0: proto.RingResponse.nodes:type_name -> proto.RingNode
+	13, // 1: proto.PeersResponse.peers:type_name -> proto.PeerInfo
+	11, // 2: proto.RebalanceResponse.nodes:type_name -> proto.RingNode
+	0,  // 3: proto.GoCache.Get:input_type -> proto.Request
+	0,  // 4: proto.GoCache.Set:input_type -> proto.Request
+	0,  // 5: proto.GoCache.Delete:input_type -> proto.Request
+	4,  // 6: proto.GoCache.Stats:input_type -> proto.StatsRequest
+	6,  // 7: proto.Admin.ListGroups:input_type -> proto.AdminRequest
+	6,  // 8: proto.Admin.GroupStats:input_type -> proto.AdminRequest
+	6,  // 9: proto.Admin.Flush:input_type -> proto.AdminRequest
+	9,  // 10: proto.Admin.Resize:input_type -> proto.ResizeRequest
+	6,  // 11: proto.Admin.Ring:input_type -> proto.AdminRequest
+	6,  // 12: proto.Admin.Peers:input_type -> proto.AdminRequest
+	6,  // 13: proto.Admin.Rebalance:input_type -> proto.AdminRequest
+	1,  // 14: proto.GoCache.Get:output_type -> proto.ResponseForGet
+	1,  // 15: proto.GoCache.Set:output_type -> proto.ResponseForGet
+	2,  // 16: proto.GoCache.Delete:output_type -> proto.ResponseForDelete
+	5,  // 17: proto.GoCache.Stats:output_type -> proto.ResponseForStats
+	7,  // 18: proto.Admin.ListGroups:output_type -> proto.ListGroupsResponse
+	5,  // 19: proto.Admin.GroupStats:output_type -> proto.ResponseForStats
+	8,  // 20: proto.Admin.Flush:output_type -> proto.FlushResponse
+	10, // 21: proto.Admin.Resize:output_type -> proto.ResizeResponse
+	12, // 22: proto.Admin.Ring:output_type -> proto.RingResponse
+	14, // 23: proto.Admin.Peers:output_type -> proto.PeersResponse
+	15, // 24: proto.Admin.Rebalance:output_type -> proto.RebalanceResponse
+	14, // [14:25] is the sub-list for method output_type
+	3,  // [3:14] is the sub-list for method input_type
+	3,  // [3:3] is the sub-list for extension type_name
+	3,  // [3:3] is the sub-list for extension extendee
+	0,  // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_gocache_proto_init() }
@@ -277,9 +1230,9 @@ func file_gocache_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_gocache_proto_rawDesc), len(file_gocache_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   16,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   2,
 		},
 		GoTypes:           file_gocache_proto_goTypes,
 		DependencyIndexes: file_gocache_proto_depIdxs,
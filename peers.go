@@ -3,14 +3,24 @@ package gocache
 import (
 	"context"
 	"fmt"
+	"gocache/breaker"
+	"gocache/consistenthash"
+	"gocache/logger"
+	"gocache/registry"
+	"log/slog"
+	"sort"
 	"strings"
 	"sync"
 	"time"
-	"gocache/consistenthash"
-	"gocache/registry"
-	"github.com/sirupsen/logrus"
+
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
+
+const (
+	defaultHealthCheckInterval = 2 * time.Second
+	defaultHealthCheckTimeout  = time.Second
+)
+
 // PeerPicker 定义了peer选择器的接口
 type PeerPicker interface {
 	PickPeer(key string) (peer Peer, ok bool, self bool)
@@ -19,22 +29,29 @@ type PeerPicker interface {
 
 // Peer 定义了缓存节点的接口
 type Peer interface {
-	Get(group string, key string) ([]byte, error)
-	Set(group string, key string, value []byte) error
-	Delete(group string, key string) (bool, error)
+	Get(ctx context.Context, group string, key string) (ByteView, error)
+	Write(ctx context.Context, group string, key string, value []byte, opts WriteOptions) (uint64, error)
+	Delete(ctx context.Context, group string, key string) (bool, error)
 	Close() error
 }
 
 // ClientPicker 实现了PeerPicker接口
 type ClientPicker struct {
-	selfAddr string
-	svcName  string
-	mu       sync.RWMutex
-	consHash *consistenthash.Map
-	clients  map[string]*Client
-	etcdCli  *clientv3.Client
-	ctx      context.Context
-	cancel   context.CancelFunc
+	selfAddr      string
+	svcName       string
+	mu            sync.RWMutex
+	consHash      *consistenthash.Map
+	clients       map[string]*Client
+	etcdCli       *clientv3.Client
+	etcdCfg       *registry.Config
+	ctx           context.Context
+	cancel        context.CancelFunc
+	breakerCfg    *breaker.Config
+	clientOpts    []ClientOption
+	checkInterval time.Duration
+	checkTimeout  time.Duration
+	logger        *slog.Logger
+	baseLogger    *slog.Logger
 }
 
 // PickerOption 定义配置选项
@@ -47,25 +64,69 @@ func WithServiceName(name string) PickerOption {
 	}
 }
 
+// WithEtcdConfig 设置服务发现使用的etcd配置，默认使用registry.DefaultConfig
+func WithEtcdConfig(cfg *registry.Config) PickerOption {
+	return func(p *ClientPicker) {
+		p.etcdCfg = cfg
+	}
+}
+
+// WithPickerLogger 设置ClientPicker及其创建的Client使用的logger
+func WithPickerLogger(l *slog.Logger) PickerOption {
+	return func(p *ClientPicker) {
+		p.baseLogger = l
+	}
+}
+
+// WithPeerBreaker 设置每个peer的熔断器配置
+func WithPeerBreaker(cfg *breaker.Config) PickerOption {
+	return func(p *ClientPicker) {
+		p.breakerCfg = cfg
+	}
+}
+
+// WithClientOptions 设置创建peer客户端时使用的选项，如超时、重试和对冲策略
+func WithClientOptions(opts ...ClientOption) PickerOption {
+	return func(p *ClientPicker) {
+		p.clientOpts = append(p.clientOpts, opts...)
+	}
+}
+
+// WithHealthCheck 设置主动健康检查的间隔和超时，interval<=0时关闭健康检查
+func WithHealthCheck(interval, timeout time.Duration) PickerOption {
+	return func(p *ClientPicker) {
+		p.checkInterval = interval
+		p.checkTimeout = timeout
+	}
+}
+
 // NewClientPicker 创建新的ClientPicker实例
 func NewClientPicker(addr string, opts ...PickerOption) (*ClientPicker, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	picker := &ClientPicker{
-		selfAddr: addr,
-		svcName:  defaultSvcName,
-		clients:  make(map[string]*Client),
-		consHash: consistenthash.New(),
-		ctx:      ctx,
-		cancel:   cancel,
+		selfAddr:      addr,
+		svcName:       defaultSvcName,
+		clients:       make(map[string]*Client),
+		consHash:      consistenthash.New(),
+		etcdCfg:       registry.DefaultConfig,
+		ctx:           ctx,
+		cancel:        cancel,
+		checkInterval: defaultHealthCheckInterval,
+		checkTimeout:  defaultHealthCheckTimeout,
 	}
 
 	for _, opt := range opts {
 		opt(picker)
 	}
+	picker.logger = logger.New("picker", picker.baseLogger).With("service", picker.svcName)
 
+	// 本节点也在哈希环上，所有节点对key的归属才能一致，否则请求会在节点间来回转发
+	if addr != "" {
+		picker.consHash.Add(addr)
+	}
 	cli, err := clientv3.New(clientv3.Config{
-		Endpoints:   registry.DefaultConfig.Endpoints,
-		DialTimeout: registry.DefaultConfig.DialTimeout,
+		Endpoints:   picker.etcdCfg.Endpoints,
+		DialTimeout: picker.etcdCfg.DialTimeout,
 	})
 	if err != nil {
 		cancel()
@@ -79,6 +140,7 @@ func NewClientPicker(addr string, opts ...PickerOption) (*ClientPicker, error) {
 		cli.Close()
 		return nil, err
 	}
+	trackPicker(picker)
 
 	return picker, nil
 }
@@ -92,9 +154,48 @@ func (p *ClientPicker) startServiceDiscovery() error {
 
 	// 启动增量更新
 	go p.watchServiceChanges()
+
+	if p.checkInterval > 0 {
+		go p.healthCheckLoop()
+	}
 	return nil
 }
 
+// healthCheckLoop 定期探测所有peer的健康状态
+func (p *ClientPicker) healthCheckLoop() {
+	ticker := time.NewTicker(p.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.checkPeers()
+		}
+	}
+}
+
+// checkPeers 并发探测一轮peer
+func (p *ClientPicker) checkPeers() {
+	p.mu.RLock()
+	clients := make([]*Client, 0, len(p.clients))
+	for _, client := range p.clients {
+		clients = append(clients, client)
+	}
+	p.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		wg.Add(1)
+		go func(c *Client) {
+			defer wg.Done()
+			c.probe(p.checkTimeout)
+		}(client)
+	}
+	wg.Wait()
+}
+
 // watchServiceChanges 监听服务实例变化
 func (p *ClientPicker) watchServiceChanges() {
 	watcher := clientv3.NewWatcher(p.etcdCli)
@@ -160,35 +261,125 @@ func (p *ClientPicker) fetchAllServices() error {
 
 // set 添加服务实例
 func (p *ClientPicker) set(addr string) {
-	if client, err := NewClient(addr, p.svcName, p.etcdCli); err == nil {
-		p.consHash.Add(addr)
-		p.clients[addr] = client
+	opts := append([]ClientOption{WithBreakerConfig(p.breakerCfg), WithClientLogger(p.baseLogger), asPeer()}, p.clientOpts...)
+	if client, err := NewClient(addr, p.svcName, p.etcdCli, opts...); err == nil {
+		p.add(addr, client)
 	} else {
-		logrus.Errorf("failed to create client for %s: %v", addr, err)
+		p.logger.Error("failed to create client", "peer", addr, "error", err)
 	}
 }
 
+// add 将client加入哈希环，调用方需持有写锁
+func (p *ClientPicker) add(addr string, client *Client) {
+	client.replicas = p.replicas
+	p.consHash.Add(addr)
+	p.clients[addr] = client
+}
+
 // remove 移除服务实例
 func (p *ClientPicker) remove(addr string) {
 	p.consHash.Remove(addr)
 	delete(p.clients, addr)
 }
 
-// PickPeer 选择peer节点
+// PickPeer 选择peer节点，节点不健康或熔断时跳过，由调用方回源本地加载
 func (p *ClientPicker) PickPeer(key string) (Peer, bool, bool) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
 	if addr := p.consHash.Get(key); addr != "" {
+		if addr == p.selfAddr {
+			return nil, true, true
+		}
 		if client, ok := p.clients[addr]; ok {
-			return client, true, addr == p.selfAddr
+			if !client.Available() {
+				p.logger.Debug("skip unavailable peer", "peer", addr, logger.Key(key))
+				return nil, false, false
+			}
+			return client, true, false
 		}
 	}
 	return nil, false, false
 }
 
+// replicas 按哈希环顺序返回key的owner之后可用的peer，不包含本节点和owner，用于对冲请求
+func (p *ClientPicker) replicas(key, owner string) []*Client {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var clients []*Client
+	for _, addr := range p.consHash.GetN(key, len(p.clients)+1) {
+		if addr == p.selfAddr || addr == owner {
+			continue
+		}
+		if client, ok := p.clients[addr]; ok && client.Available() {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// RingNode 哈希环上一个节点的状态
+type RingNode struct {
+	Addr      string
+	Replicas  int     // 虚拟节点数
+	LoadRatio float64 // 上次重新平衡以来路由到该节点的请求占比
+}
+
+// PeerStatus peer的健康状态
+type PeerStatus struct {
+	Addr    string
+	Healthy bool
+	Breaker breaker.State
+}
+
+// Self 返回本节点地址
+func (p *ClientPicker) Self() string {
+	return p.selfAddr
+}
+
+// Ring 返回哈希环上所有节点的状态，按地址排序
+func (p *ClientPicker) Ring() []RingNode {
+	stats := p.consHash.GetStats()
+	nodes := make([]RingNode, 0)
+	for addr, replicas := range p.consHash.Nodes() {
+		nodes = append(nodes, RingNode{Addr: addr, Replicas: replicas, LoadRatio: stats[addr]})
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Addr < nodes[j].Addr })
+	return nodes
+}
+
+// RebalancePlan 返回按本节点负载统计重新平衡后的哈希环，只是预览，不修改哈希环。
+// 哈希环由每个节点各自维护，只修改本节点会让各节点对key的归属不一致
+func (p *ClientPicker) RebalancePlan() []RingNode {
+	stats := p.consHash.GetStats()
+	nodes := make([]RingNode, 0)
+	for addr, replicas := range p.consHash.PlanRebalance() {
+		nodes = append(nodes, RingNode{Addr: addr, Replicas: replicas, LoadRatio: stats[addr]})
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Addr < nodes[j].Addr })
+	return nodes
+}
+
+// Peers 返回所有peer的健康状态，按地址排序
+func (p *ClientPicker) Peers() []PeerStatus {
+	p.mu.RLock()
+	peers := make([]PeerStatus, 0, len(p.clients))
+	for addr, client := range p.clients {
+		peers = append(peers, PeerStatus{
+			Addr:    addr,
+			Healthy: client.healthy.Load(),
+			Breaker: client.breaker.State(),
+		})
+	}
+	p.mu.RUnlock()
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Addr < peers[j].Addr })
+	return peers
+}
+
 // Close 关闭所有资源
 func (p *ClientPicker) Close() error {
+	untrackPicker(p)
 	p.cancel()
 	p.mu.Lock()
 	defer p.mu.Unlock()
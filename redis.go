@@ -0,0 +1,582 @@
+package gocache
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"gocache/auth"
+	"gocache/logger"
+	"gocache/resp"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisVersion INFO和HELLO中上报的版本，部分客户端会据此判断支持的命令
+const redisVersion = "7.2.0"
+
+// RedisConfig RESP协议监听配置
+type RedisConfig struct {
+	Addr string
+	// Databases SELECT n 使用Databases[n]对应的Group，连接默认使用第0个
+	Databases []string
+	// KeyPrefix 为true时，形如"group:key"且group存在的key路由到该Group，key去掉前缀
+	KeyPrefix bool
+}
+
+// WithRedis 在cfg.Addr上开启RESP2/RESP3协议监听，使Redis客户端可以直接访问缓存。
+// Server配置了TLS时该监听同样使用TLS，配置了认证时客户端需要先AUTH，token作为密码
+func WithRedis(cfg RedisConfig) ServerOptions {
+	return func(server *Server) {
+		server.redis = &redisServer{cfg: cfg}
+	}
+}
+
+// redisServer RESP协议前端，命令映射到Group操作
+type redisServer struct {
+	connListener
+	cfg    RedisConfig
+	server *Server
+}
+
+// start 开始监听，由Server.Run调用
+func (r *redisServer) start(s *Server) error {
+	if len(r.cfg.Databases) == 0 {
+		return fmt.Errorf("redis: at least one database group is required")
+	}
+	r.server = s
+	r.addr = r.cfg.Addr
+	r.logger = logger.New("redis", s.baseLogger).With("addr", r.cfg.Addr)
+	r.handle = r.handleConn
+	if err := r.listen(s.tlsConfig); err != nil {
+		return fmt.Errorf("redis: %v", err)
+	}
+	r.logger.Info("redis listener is running")
+	return nil
+}
+
+// redisConn 一个客户端连接的状态
+type redisConn struct {
+	srv *redisServer
+	ctx context.Context
+	w   *resp.Writer
+	db  int
+	id  *auth.Identity
+}
+
+// redisError 以Redis错误码开头的错误回复
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+// redisPreAuthLimits 认证前的连接只需要发送AUTH或HELLO，与Redis一样限制命令的参数个数和长度
+var redisPreAuthLimits = resp.Limits{MaxBulkLen: 16 << 10, MaxArrayLen: 10, MaxDepth: 1}
+
+var (
+	errRedisSyntax = redisError("ERR syntax error")
+	errRedisNoAuth = redisError("NOAUTH Authentication required.")
+)
+
+func errWrongArgs(cmd string) error {
+	return redisError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd)))
+}
+
+func (r *redisServer) handleConn(ctx context.Context, conn net.Conn) {
+	c := &redisConn{srv: r, ctx: ctx, w: resp.NewWriter(conn)}
+	if _, ok := conn.(*tls.Conn); ok {
+		c.authenticate("")
+	}
+
+	reader := resp.NewReader(conn)
+	for {
+		reader.Limits = c.limits()
+		args, err := reader.ReadCommand()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				r.logger.Debug("read command failed", "remote", conn.RemoteAddr().String(), "error", err)
+				if errors.Is(err, resp.ErrProtocol) {
+					c.w.WriteError("ERR Protocol error: " + err.Error())
+					c.w.Flush()
+				}
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := c.dispatch(args)
+		// 客户端使用pipeline时合并写回
+		if reader.Buffered() == 0 || quit {
+			if err := c.w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// limits 连接需要认证且尚未认证时使用更严格的读取限制
+func (c *redisConn) limits() resp.Limits {
+	if c.srv.server.authn != nil && c.id == nil {
+		return redisPreAuthLimits
+	}
+	return resp.Limits{}
+}
+
+// authenticate 使用token或连接上的mTLS证书认证
+func (c *redisConn) authenticate(token string) error {
+	id, err := c.srv.server.authenticateConn(c.ctx, "redis", token)
+	if err != nil {
+		return err
+	}
+	c.id = id
+	return nil
+}
+
+// authorize 检查当前身份对group的操作权限
+func (c *redisConn) authorize(group string, op auth.Operation) error {
+	if c.srv.server.authn == nil {
+		return nil
+	}
+	if c.id == nil {
+		return errRedisNoAuth
+	}
+	if !c.srv.server.acl.Allowed(c.id.Name, group, op) {
+		return redisError(fmt.Sprintf("NOPERM %s is not allowed to %s group '%s'", c.id.Name, op, group))
+	}
+	return nil
+}
+
+// group 解析key对应的Group，返回去掉前缀后的key
+func (c *redisConn) group(key string, op auth.Operation) (*Group, string, error) {
+	name, key := routeKey(c.srv.cfg.Databases[c.db], key, c.srv.cfg.KeyPrefix)
+	if err := c.authorize(name, op); err != nil {
+		return nil, "", err
+	}
+	g := GetGroup(name)
+	if g == nil {
+		return nil, "", redisError(fmt.Sprintf("ERR group %s not exist", name))
+	}
+	return g, key, nil
+}
+
+type redisCommand struct {
+	arity   int // 与Redis一致，负数表示至少-arity个参数(含命令名)
+	noAuth  bool
+	handler func(c *redisConn, args [][]byte) error
+}
+
+var redisCommands = map[string]redisCommand{
+	"PING":    {arity: -1, noAuth: true, handler: (*redisConn).ping},
+	"ECHO":    {arity: 2, handler: (*redisConn).echo},
+	"HELLO":   {arity: -1, noAuth: true, handler: (*redisConn).hello},
+	"AUTH":    {arity: -2, noAuth: true, handler: (*redisConn).auth},
+	"SELECT":  {arity: 2, handler: (*redisConn).selectDB},
+	"GET":     {arity: 2, handler: (*redisConn).get},
+	"SET":     {arity: -3, handler: (*redisConn).set},
+	"DEL":     {arity: -2, handler: (*redisConn).del},
+	"MGET":    {arity: -2, handler: (*redisConn).mget},
+	"EXISTS":  {arity: -2, handler: (*redisConn).exists},
+	"TTL":     {arity: 2, handler: (*redisConn).ttl},
+	"PTTL":    {arity: 2, handler: (*redisConn).ttl},
+	"DBSIZE":  {arity: 1, handler: (*redisConn).dbsize},
+	"INFO":    {arity: -1, handler: (*redisConn).info},
+	"COMMAND": {arity: -1, noAuth: true, handler: (*redisConn).command},
+	"CLIENT":  {arity: -2, noAuth: true, handler: (*redisConn).client},
+	"QUIT":    {arity: -1, noAuth: true, handler: (*redisConn).quit},
+}
+
+// errQuit 处理完QUIT后关闭连接
+var errQuit = errors.New("quit")
+
+// dispatch 执行一条命令并写入回复，返回是否需要关闭连接
+func (c *redisConn) dispatch(args [][]byte) bool {
+	name := strings.ToUpper(string(args[0]))
+	cmd, ok := redisCommands[name]
+	if !ok {
+		c.w.WriteError(fmt.Sprintf("ERR unknown command '%s'", string(args[0])))
+		return false
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		c.w.WriteError(errWrongArgs(name).Error())
+		return false
+	}
+	if !cmd.noAuth && c.srv.server.authn != nil && c.id == nil {
+		c.w.WriteError(errRedisNoAuth.Error())
+		return false
+	}
+
+	err := cmd.handler(c, args)
+	if errors.Is(err, errQuit) {
+		return true
+	}
+	if err != nil {
+		var re redisError
+		if errors.As(err, &re) {
+			c.w.WriteError(re.Error())
+		} else {
+			c.w.WriteError("ERR " + err.Error())
+		}
+	}
+	return false
+}
+
+func (c *redisConn) ping(args [][]byte) error {
+	if len(args) > 2 {
+		return errWrongArgs("ping")
+	}
+	if len(args) == 2 {
+		c.w.WriteBulk(args[1])
+		return nil
+	}
+	c.w.WriteSimple("PONG")
+	return nil
+}
+
+func (c *redisConn) echo(args [][]byte) error {
+	c.w.WriteBulk(args[1])
+	return nil
+}
+
+// hello HELLO [protover [AUTH username password] [SETNAME clientname]]
+func (c *redisConn) hello(args [][]byte) error {
+	proto := c.w.Proto
+	if len(args) > 1 {
+		v, err := strconv.Atoi(string(args[1]))
+		if err != nil {
+			return redisError("ERR Protocol version is not an integer or out of range")
+		}
+		if v != 2 && v != 3 {
+			return redisError("NOPROTO unsupported protocol version")
+		}
+		proto = v
+	}
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "AUTH":
+			if i+2 >= len(args) {
+				return errRedisSyntax
+			}
+			if err := c.authenticate(string(args[i+2])); err != nil {
+				return redisError("WRONGPASS invalid username-password pair or user is disabled.")
+			}
+			i += 2
+		case "SETNAME":
+			if i+1 >= len(args) {
+				return errRedisSyntax
+			}
+			i++
+		default:
+			return errRedisSyntax
+		}
+	}
+	if c.srv.server.authn != nil && c.id == nil {
+		return errRedisNoAuth
+	}
+
+	c.w.Proto = proto
+	c.w.WriteMap(7)
+	c.w.WriteBulkString("server")
+	c.w.WriteBulkString("redis")
+	c.w.WriteBulkString("version")
+	c.w.WriteBulkString(redisVersion)
+	c.w.WriteBulkString("proto")
+	c.w.WriteInt(int64(proto))
+	c.w.WriteBulkString("id")
+	c.w.WriteInt(0)
+	c.w.WriteBulkString("mode")
+	c.w.WriteBulkString("standalone")
+	c.w.WriteBulkString("role")
+	c.w.WriteBulkString("master")
+	c.w.WriteBulkString("modules")
+	c.w.WriteArray(0)
+	return nil
+}
+
+// auth AUTH [username] password，password为token
+func (c *redisConn) auth(args [][]byte) error {
+	if len(args) > 3 {
+		return errRedisSyntax
+	}
+	if c.srv.server.authn == nil {
+		return redisError("ERR AUTH called without any password configured for the default user.")
+	}
+	if err := c.authenticate(string(args[len(args)-1])); err != nil {
+		c.id = nil
+		return redisError("WRONGPASS invalid username-password pair or user is disabled.")
+	}
+	c.w.WriteSimple("OK")
+	return nil
+}
+
+func (c *redisConn) selectDB(args [][]byte) error {
+	db, err := strconv.Atoi(string(args[1]))
+	if err != nil {
+		return redisError("ERR value is not an integer or out of range")
+	}
+	if db < 0 || db >= len(c.srv.cfg.Databases) {
+		return redisError("ERR DB index is out of range")
+	}
+	c.db = db
+	c.w.WriteSimple("OK")
+	return nil
+}
+
+func (c *redisConn) get(args [][]byte) error {
+	g, key, err := c.group(string(args[1]), auth.OpRead)
+	if err != nil {
+		return err
+	}
+	view, err := g.getPlain(c.ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		c.w.WriteNull()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.w.WriteBulk(view.data())
+	return nil
+}
+
+// set SET key value [EX seconds | PX milliseconds]
+func (c *redisConn) set(args [][]byte) error {
+	var ttl time.Duration
+	for i := 3; i < len(args); i++ {
+		opt := strings.ToUpper(string(args[i]))
+		if (opt != "EX" && opt != "PX") || ttl != 0 || i+1 >= len(args) {
+			return errRedisSyntax
+		}
+		n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+		if err != nil || n <= 0 {
+			return redisError("ERR invalid expire time in 'set' command")
+		}
+		if opt == "EX" {
+			ttl = time.Duration(n) * time.Second
+		} else {
+			ttl = time.Duration(n) * time.Millisecond
+		}
+		i++
+	}
+
+	g, key, err := c.group(string(args[1]), auth.OpWrite)
+	if err != nil {
+		return err
+	}
+	if err := g.SetWithTTL(c.ctx, key, args[2], ttl); err != nil {
+		return err
+	}
+	c.w.WriteSimple("OK")
+	return nil
+}
+
+func (c *redisConn) del(args [][]byte) error {
+	var n int64
+	for _, arg := range args[1:] {
+		g, key, err := c.group(string(arg), auth.OpDelete)
+		if err != nil {
+			return err
+		}
+		deleted, err := g.Delete(c.ctx, key)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			n++
+		}
+	}
+	c.w.WriteInt(n)
+	return nil
+}
+
+// mget 并发读取，不存在或读取失败的key返回空值
+func (c *redisConn) mget(args [][]byte) error {
+	keys := args[1:]
+	groups := make([]*Group, len(keys))
+	names := make([]string, len(keys))
+	for i, arg := range keys {
+		g, key, err := c.group(string(arg), auth.OpRead)
+		if err != nil {
+			return err
+		}
+		groups[i], names[i] = g, key
+	}
+
+	views := getAll(c.ctx, groups, names, c.srv.logger)
+
+	c.w.WriteArray(len(views))
+	for _, view := range views {
+		if view == nil {
+			c.w.WriteNull()
+			continue
+		}
+		c.w.WriteBulk(view.data())
+	}
+	return nil
+}
+
+// exists 缓存中没有时会尝试通过Getter加载，能读到即视为存在
+func (c *redisConn) exists(args [][]byte) error {
+	var n int64
+	for _, arg := range args[1:] {
+		g, key, err := c.group(string(arg), auth.OpRead)
+		if err != nil {
+			return err
+		}
+		_, err = g.Get(c.ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		n++
+	}
+	c.w.WriteInt(n)
+	return nil
+}
+
+// ttl TTL和PTTL，key不存在返回-2，没有过期时间返回-1
+func (c *redisConn) ttl(args [][]byte) error {
+	g, key, err := c.group(string(args[1]), auth.OpRead)
+	if err != nil {
+		return err
+	}
+	view, err := g.Get(c.ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		c.w.WriteInt(-2)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ttl := view.TTL()
+	switch {
+	case ttl == 0:
+		c.w.WriteInt(-1)
+	case strings.EqualFold(string(args[0]), "PTTL"):
+		c.w.WriteInt(ttlMillis(ttl))
+	default:
+		c.w.WriteInt(int64((ttl + 500*time.Millisecond) / time.Second))
+	}
+	return nil
+}
+
+// dbsize 返回当前库对应Group在本节点上的条目数
+func (c *redisConn) dbsize(args [][]byte) error {
+	name := c.srv.cfg.Databases[c.db]
+	if err := c.authorize(name, auth.OpRead); err != nil {
+		return err
+	}
+	g := GetGroup(name)
+	if g == nil {
+		return redisError(fmt.Sprintf("ERR group %s not exist", name))
+	}
+	c.w.WriteInt(int64(g.Stats().Items))
+	return nil
+}
+
+// info 返回兼容Redis INFO格式的节点信息，Keyspace中的dbN对应Databases[N]
+func (c *redisConn) info(args [][]byte) error {
+	if len(args) > 2 {
+		return errRedisSyntax
+	}
+	section := "all"
+	if len(args) == 2 {
+		section = strings.ToLower(string(args[1]))
+	}
+	want := func(name string) bool {
+		return section == "all" || section == "default" || section == "everything" || section == name
+	}
+
+	var b strings.Builder
+	if want("server") {
+		b.WriteString("# Server\r\n")
+		fmt.Fprintf(&b, "redis_version:%s\r\n", redisVersion)
+		b.WriteString("redis_mode:standalone\r\n")
+		fmt.Fprintf(&b, "tcp_port:%s\r\n", portOf(c.srv.cfg.Addr))
+		b.WriteString("\r\n")
+	}
+	if want("clients") {
+		b.WriteString("# Clients\r\n")
+		fmt.Fprintf(&b, "connected_clients:%d\r\n", c.srv.connections())
+		b.WriteString("\r\n")
+	}
+
+	var hits, misses int64
+	stats := make([]*Stats, len(c.srv.cfg.Databases))
+	for i, name := range c.srv.cfg.Databases {
+		if c.authorize(name, auth.OpRead) != nil {
+			continue
+		}
+		if g := GetGroup(name); g != nil {
+			s := g.Stats()
+			stats[i] = &s
+			hits += s.Hits
+			misses += s.Misses
+		}
+	}
+	if want("stats") {
+		b.WriteString("# Stats\r\n")
+		fmt.Fprintf(&b, "keyspace_hits:%d\r\n", hits)
+		fmt.Fprintf(&b, "keyspace_misses:%d\r\n", misses)
+		b.WriteString("\r\n")
+	}
+	if want("keyspace") {
+		b.WriteString("# Keyspace\r\n")
+		for i, s := range stats {
+			if s != nil && s.Items > 0 {
+				fmt.Fprintf(&b, "db%d:keys=%d,expires=0,avg_ttl=0\r\n", i, s.Items)
+			}
+		}
+		b.WriteString("\r\n")
+	}
+	if want("gocache") {
+		b.WriteString("# Gocache\r\n")
+		for i, name := range c.srv.cfg.Databases {
+			fmt.Fprintf(&b, "db%d:group=%s\r\n", i, name)
+		}
+	}
+	c.w.WriteBulkString(b.String())
+	return nil
+}
+
+// command 客户端启动时会查询命令表，返回空表即可
+func (c *redisConn) command(args [][]byte) error {
+	c.w.WriteArray(0)
+	return nil
+}
+
+// client 兼容CLIENT SETNAME等客户端连接时发送的命令
+func (c *redisConn) client(args [][]byte) error {
+	switch strings.ToUpper(string(args[1])) {
+	case "SETNAME", "SETINFO", "NO-EVICT", "NO-TOUCH":
+		c.w.WriteSimple("OK")
+	case "GETNAME":
+		c.w.WriteNull()
+	case "ID":
+		c.w.WriteInt(0)
+	default:
+		return redisError(fmt.Sprintf("ERR unknown subcommand '%s'", string(args[1])))
+	}
+	return nil
+}
+
+func (c *redisConn) quit(args [][]byte) error {
+	c.w.WriteSimple("OK")
+	return errQuit
+}
+
+func portOf(addr string) string {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return ""
+	}
+	return port
+}
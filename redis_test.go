@@ -0,0 +1,226 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"gocache/auth"
+	"gocache/logger"
+	"gocache/resp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startRedis 只启动RESP监听，不依赖etcd
+func startRedis(t *testing.T, cfg RedisConfig, opts ...ServerOptions) *resp.Client {
+	t.Helper()
+	cfg.Addr = "127.0.0.1:0"
+	server, err := NewServer("localhost:9999", append(opts, WithRedis(cfg))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.redis.start(server); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.redis.stop)
+
+	client, err := resp.Dial(server.redis.lis.Addr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Timeout = 5 * time.Second
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// newProtocolGroup 创建协议前端测试使用的Group，Getter只能加载"loaded"
+func newProtocolGroup(t *testing.T, name string) *Group {
+	g := NewGroup(name, 1<<20, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		if key == "loaded" {
+			return []byte("from-getter"), true, time.Time{}
+		}
+		return nil, false, time.Time{}
+	}))
+	t.Cleanup(func() { DestroyGroup(name) })
+	return g
+}
+
+func TestRedis_Commands(t *testing.T) {
+	newProtocolGroup(t, "redis-db0")
+	db1 := newProtocolGroup(t, "redis-db1")
+	c := startRedis(t, RedisConfig{Databases: []string{"redis-db0", "redis-db1"}, KeyPrefix: true})
+
+	expect := func(want string, args ...string) {
+		t.Helper()
+		got, err := c.String(args...)
+		if err != nil || got != want {
+			t.Fatalf("%v: got %q %v, want %q", args, got, err, want)
+		}
+	}
+	expectInt := func(want int64, args ...string) {
+		t.Helper()
+		got, err := c.Int(args...)
+		if err != nil || got != want {
+			t.Fatalf("%v: got %d %v, want %d", args, got, err, want)
+		}
+	}
+
+	expect("PONG", "PING")
+	expect("hi", "PING", "hi")
+	expect("OK", "SET", "k1", "v1")
+	expect("v1", "GET", "k1")
+	expect("from-getter", "GET", "loaded")
+	if v, err := c.Do("GET", "missing"); err != nil || !v.IsNil() {
+		t.Fatalf("expect nil for missing key, got %+v %v", v, err)
+	}
+
+	expect("OK", "SET", "k2", "v2", "EX", "100")
+	expectInt(100, "TTL", "k2")
+	expect("OK", "SET", "k3", "v3", "px", "1500")
+	if ms, err := c.Int("PTTL", "k3"); err != nil || ms <= 1000 || ms > 1500 {
+		t.Fatalf("unexpected pttl %d %v", ms, err)
+	}
+	expectInt(-1, "TTL", "k1")
+	expectInt(-2, "TTL", "missing")
+
+	v, err := c.Do("MGET", "k1", "missing", "k2")
+	if err != nil || len(v.Elems) != 3 || v.Elems[0].Text() != "v1" || !v.Elems[1].IsNil() || v.Elems[2].Text() != "v2" {
+		t.Fatalf("unexpected mget reply %+v %v", v, err)
+	}
+	expectInt(2, "EXISTS", "k1", "missing", "loaded")
+	expectInt(2, "DEL", "k1", "k2", "missing")
+	expectInt(0, "EXISTS", "k1")
+
+	// SELECT和key前缀都能路由到其他Group
+	expect("OK", "SELECT", "1")
+	expect("OK", "SET", "k1", "db1")
+	expect("OK", "SET", "redis-db0:k1", "db0")
+	expect("OK", "SELECT", "0")
+	expect("db0", "GET", "k1")
+	expect("db1", "GET", "redis-db1:k1")
+	if view, err := db1.Get(context.Background(), "k1"); err != nil || view.String() != "db1" {
+		t.Fatalf("expect db1 written through SELECT, got %q %v", view.String(), err)
+	}
+
+	info, err := c.String("INFO")
+	if err != nil || !strings.Contains(info, "db1:keys=1") || !strings.Contains(info, "db1:group=redis-db1") {
+		t.Fatalf("unexpected info %q %v", info, err)
+	}
+
+	var se resp.ServerError
+	for _, args := range [][]string{
+		{"SELECT", "2"},
+		{"SET", "k", "v", "EX", "0"},
+		{"SET", "k", "v", "NX"},
+		{"GET"},
+		{"FLUSHALL"},
+	} {
+		if _, err := c.Do(args...); !errors.As(err, &se) || !strings.HasPrefix(string(se), "ERR") {
+			t.Fatalf("%v: expect ERR reply, got %v", args, err)
+		}
+	}
+}
+
+func TestRedis_RESP3(t *testing.T) {
+	newProtocolGroup(t, "redis-resp3")
+	c := startRedis(t, RedisConfig{Databases: []string{"redis-resp3"}})
+
+	v, err := c.Do("HELLO", "3")
+	if err != nil || v.Kind != resp.Map || len(v.Elems) != 14 {
+		t.Fatalf("expect map reply, got %+v %v", v, err)
+	}
+	if v.Elems[4].Text() != "proto" || v.Elems[5].Int != 3 {
+		t.Fatalf("unexpected hello reply %+v", v)
+	}
+	if v, err := c.Do("GET", "missing"); err != nil || v.Kind != resp.Null {
+		t.Fatalf("expect RESP3 null, got %+v %v", v, err)
+	}
+	if _, err := c.Do("HELLO", "4"); err == nil || !strings.HasPrefix(err.Error(), "NOPROTO") {
+		t.Fatalf("expect NOPROTO, got %v", err)
+	}
+}
+
+func TestRedis_Auth(t *testing.T) {
+	newProtocolGroup(t, "redis-auth")
+	newProtocolGroup(t, "redis-auth-other")
+	acl := auth.NewACL()
+	acl.Grant("svc", "redis-auth", auth.OpRead|auth.OpWrite)
+	c := startRedis(t,
+		RedisConfig{Databases: []string{"redis-auth", "redis-auth-other"}},
+		WithAuth(auth.TokenAuthenticator{"secret": "svc"}, acl))
+
+	expectErr := func(prefix string, args ...string) {
+		t.Helper()
+		if _, err := c.Do(args...); err == nil || !strings.HasPrefix(err.Error(), prefix) {
+			t.Fatalf("%v: expect %s, got %v", args, prefix, err)
+		}
+	}
+
+	expectErr("NOAUTH", "GET", "k")
+	expectErr("WRONGPASS", "AUTH", "wrong")
+	if _, err := c.Do("PING"); err != nil {
+		t.Fatalf("ping should not require auth: %v", err)
+	}
+	if _, err := c.Do("AUTH", "default", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Do("SET", "k", "v"); err != nil {
+		t.Fatal(err)
+	}
+	// 认证后不再使用认证前的限制
+	if _, err := c.Do("SET", "big", strings.Repeat("x", 64<<10)); err != nil {
+		t.Fatal(err)
+	}
+	expectErr("NOPERM", "DEL", "k")
+	if _, err := c.Do("SELECT", "1"); err != nil {
+		t.Fatal(err)
+	}
+	expectErr("NOPERM", "GET", "k")
+}
+
+func TestRedis_PreAuthLimits(t *testing.T) {
+	newProtocolGroup(t, "redis-preauth")
+	c := startRedis(t, RedisConfig{Databases: []string{"redis-preauth"}},
+		WithAuth(auth.TokenAuthenticator{"secret": "svc"}, auth.NewACL()))
+
+	// 未认证的连接发送超过限制的值会被断开
+	if _, err := c.Do("AUTH", strings.Repeat("x", 64<<10)); err == nil || !strings.Contains(err.Error(), "Protocol error") {
+		t.Fatalf("expect protocol error for large pre-auth argument, got %v", err)
+	}
+	if _, err := c.Do("PING"); err == nil {
+		t.Fatal("expect connection closed after protocol error")
+	}
+}
+
+func TestGetAll_BoundedConcurrency(t *testing.T) {
+	var running, peak atomic.Int64
+	g := NewGroup("getall", 1<<20, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(time.Millisecond)
+		return []byte(key), true, time.Time{}
+	}))
+	t.Cleanup(func() { DestroyGroup("getall") })
+
+	// 先创建存储，只统计批量读取的并发
+	g.Set(context.Background(), "warm", []byte("warm"))
+
+	keys := make([]string, 10*getAllConcurrency)
+	groups := make([]*Group, len(keys))
+	for i := range keys {
+		keys[i], groups[i] = strconv.Itoa(i), g
+	}
+	views := getAll(context.Background(), groups, keys, logger.New("test", nil))
+	for i, v := range views {
+		if v == nil || v.String() != keys[i] {
+			t.Fatalf("key %s: unexpected value %v", keys[i], v)
+		}
+	}
+	if p := peak.Load(); p > getAllConcurrency {
+		t.Fatalf("expect at most %d concurrent gets, got %d", getAllConcurrency, p)
+	}
+}
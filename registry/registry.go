@@ -3,8 +3,13 @@ package registry
 import (
 	"context"
 	"fmt"
+	"gocache/logger"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"sync"
 	"time"
-	"github.com/sirupsen/logrus"
+
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/naming/endpoints"
 	"google.golang.org/grpc"
@@ -12,27 +17,84 @@ import (
 	resolver "google.golang.org/grpc/resolver"
 )
 
+const (
+	defaultLeaseTTL         = 3
+	defaultRetryInterval    = 500 * time.Millisecond
+	defaultMaxRetryInterval = 30 * time.Second
+	// defaultRevokeTimeout 未配置DialTimeout时撤销租约的超时
+	defaultRevokeTimeout = 5 * time.Second
+)
+
 // Config 定义etcd客户端配置
 type Config struct {
-	Endpoints   []string
-	DialTimeout time.Duration
+	Endpoints        []string
+	DialTimeout      time.Duration
+	LeaseTTL         int64         // 租约TTL(秒)
+	RetryInterval    time.Duration // 租约丢失后重新注册的初始退避时间
+	MaxRetryInterval time.Duration // 重新注册的最大退避时间
 }
 
 // DefaultConfig 提供默认配置
 var DefaultConfig = &Config{
-	Endpoints:   []string{"localhost:2379"},
-	DialTimeout: 5 * time.Second,
+	Endpoints:        []string{"localhost:2379"},
+	DialTimeout:      5 * time.Second,
+	LeaseTTL:         defaultLeaseTTL,
+	RetryInterval:    defaultRetryInterval,
+	MaxRetryInterval: defaultMaxRetryInterval,
+}
+
+// State 服务注册状态
+type State int
+
+const (
+	StateUnregistered State = iota // 未注册或已注销
+	StateRegistered                // 注册成功且租约有效
+	StateLost                      // 租约丢失，正在重新注册
+)
+
+func (s State) String() string {
+	switch s {
+	case StateUnregistered:
+		return "unregistered"
+	case StateRegistered:
+		return "registered"
+	case StateLost:
+		return "lost"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
 }
 
 // ServiceRegistry 服务注册器
 type ServiceRegistry struct {
-	client  *clientv3.Client
-	config  *Config
-	leaseID clientv3.LeaseID
+	client   *clientv3.Client
+	config   *Config
+	mu       sync.Mutex
+	leaseID  clientv3.LeaseID
+	state    State
+	onChange func(State)
+	logger   *slog.Logger
+}
+
+// RegistryOption 定义服务注册器的配置选项
+type RegistryOption func(*ServiceRegistry)
+
+// WithStateHandler 设置注册状态变化时的回调，回调在注册器的goroutine中同步执行
+func WithStateHandler(fn func(State)) RegistryOption {
+	return func(sr *ServiceRegistry) {
+		sr.onChange = fn
+	}
+}
+
+// WithLogger 设置服务注册器使用的logger
+func WithLogger(l *slog.Logger) RegistryOption {
+	return func(sr *ServiceRegistry) {
+		sr.logger = logger.New("registry", l)
+	}
 }
 
 // NewServiceRegistry 创建服务注册器
-func NewServiceRegistry(cfg *Config) (*ServiceRegistry, error) {
+func NewServiceRegistry(cfg *Config, opts ...RegistryOption) (*ServiceRegistry, error) {
 	if cfg == nil {
 		cfg = DefaultConfig
 	}
@@ -43,45 +105,84 @@ func NewServiceRegistry(cfg *Config) (*ServiceRegistry, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create etcd client: %v", err)
 	}
-	return &ServiceRegistry{
+	sr := &ServiceRegistry{
 		client: cli,
 		config: cfg,
-	}, nil
+		logger: logger.New("registry", nil),
+	}
+	for _, opt := range opts {
+		opt(sr)
+	}
+	return sr, nil
+}
+
+// State 返回当前注册状态
+func (sr *ServiceRegistry) State() State {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	return sr.state
 }
 
-// EtcdDial 从 etcd 集群选择一个实例与其建立 grpc 连接
-func EtcdDial(c *clientv3.Client, service, target string) (*grpc.ClientConn, error) {
+// setState 更新注册状态并通知回调
+func (sr *ServiceRegistry) setState(state State) {
+	sr.mu.Lock()
+	changed := sr.state != state
+	sr.state = state
+	fn := sr.onChange
+	sr.mu.Unlock()
+
+	if changed && fn != nil {
+		fn(state)
+	}
+}
+
+// EtcdDial 从 etcd 集群选择一个实例与其建立 grpc 连接。
+// target 不为空时连接只会解析到该地址，为空时在服务的所有实例间负载均衡。
+// 默认使用明文连接，可通过 opts 传入 grpc.WithTransportCredentials 覆盖
+func EtcdDial(c *clientv3.Client, service, target string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
 	em, err := endpoints.NewManager(c, service)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create endpoint manager: %v", err)
 	}
 	builder := &etcdResolverBuilder{
-		client:  c,
+		service: service,
 		manager: em,
+		logger:  logger.New("resolver", nil).With("service", service),
 	}
-	resolver.Register(builder)
-	
-	return grpc.NewClient(
-		fmt.Sprintf("etcd:///%s/%s", service, target),
+
+	// resolver 只对当前连接生效，避免每个连接覆盖全局注册的 builder
+	dialOpts := []grpc.DialOption{
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithResolvers(builder),
+	}
+	return grpc.NewClient(
+		fmt.Sprintf("%s:///%s/%s", builder.Scheme(), service, target),
+		append(dialOpts, opts...)...,
 	)
 }
 
 // etcdResolverBuilder 实现 resolver.Builder 接口
 type etcdResolverBuilder struct {
-	client  *clientv3.Client
+	service string
 	manager endpoints.Manager
+	logger  *slog.Logger
 }
 
 func (b *etcdResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	ctx, cancel := context.WithCancel(context.Background())
 	r := &etcdResolver{
-		client:     b.client,
 		manager:    b.manager,
-		target:     target,
 		cc:         cc,
-		addrsStore: make(map[string]struct{}),
+		filterAddr: parseTargetAddr(target.Endpoint(), b.service),
+		addrsStore: make(map[string]string),
+		logger:     b.logger,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	if err := r.start(); err != nil {
+		cancel()
+		return nil, err
 	}
-	r.start()
 	return r, nil
 }
 
@@ -89,70 +190,147 @@ func (b *etcdResolverBuilder) Scheme() string {
 	return "etcd"
 }
 
-// etcdResolver 实现 resolver.Resolver 接口
+// parseTargetAddr 从 "service/addr" 形式的 target 中解析出要固定连接的地址
+func parseTargetAddr(endpoint, service string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(endpoint, service), "/")
+}
+
+// etcdResolver 实现 resolver.Resolver 接口，监听服务前缀并推送地址变化
 type etcdResolver struct {
-	client     *clientv3.Client
 	manager    endpoints.Manager
-	target     resolver.Target
 	cc         resolver.ClientConn
-	addrsStore map[string]struct{}
+	filterAddr string            // 不为空时只解析该地址
+	addrsStore map[string]string // etcd key 到实例地址的映射
+	logger     *slog.Logger
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
 }
 
-func (r *etcdResolver) start() {
-	endpoints, err := r.manager.List(context.Background())
+// start 建立 watch 并启动处理 goroutine，watch 会先推送一次全量实例
+func (r *etcdResolver) start() error {
+	wch, err := r.manager.NewWatchChannel(r.ctx)
 	if err != nil {
-		logrus.Errorf("failed to list endpoints: %v", err)
-		return
+		return fmt.Errorf("failed to watch endpoints: %v", err)
 	}
 
-	addresses := make([]resolver.Address, 0, len(endpoints))
-	for _, ep := range endpoints {
-		addresses = append(addresses, resolver.Address{Addr: ep.Addr})
-		r.addrsStore[ep.Addr] = struct{}{}
-	}
+	r.wg.Add(1)
+	go r.watch(wch)
+	return nil
+}
+
+// watch 处理实例变化事件
+func (r *etcdResolver) watch(wch endpoints.WatchChannel) {
+	defer r.wg.Done()
 
-	r.cc.UpdateState(resolver.State{Addresses: addresses})
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case updates, ok := <-wch:
+			if !ok {
+				if r.ctx.Err() == nil {
+					r.logger.Warn("endpoints watch channel closed")
+					r.cc.ReportError(fmt.Errorf("endpoints watch channel closed"))
+				}
+				return
+			}
+			r.applyUpdates(updates)
+		}
+	}
 }
 
-func (r *etcdResolver) ResolveNow(resolver.ResolveNowOptions) {
-	r.start()
+// applyUpdates 更新本地地址表并推送给 grpc
+func (r *etcdResolver) applyUpdates(updates []*endpoints.Update) {
+	for _, up := range updates {
+		switch up.Op {
+		case endpoints.Add:
+			r.addrsStore[up.Key] = up.Endpoint.Addr
+		case endpoints.Delete:
+			delete(r.addrsStore, up.Key)
+		}
+	}
+
+	addresses := make([]resolver.Address, 0, len(r.addrsStore))
+	for _, addr := range r.addrsStore {
+		if r.filterAddr != "" && addr != r.filterAddr {
+			continue
+		}
+		addresses = append(addresses, resolver.Address{Addr: addr})
+	}
+
+	if err := r.cc.UpdateState(resolver.State{Addresses: addresses}); err != nil {
+		r.logger.Debug("failed to update resolver state", "error", err)
+	}
 }
 
-func (r *etcdResolver) Close() {}
+// ResolveNow watch 会持续推送变化，无需主动解析
+func (r *etcdResolver) ResolveNow(resolver.ResolveNowOptions) {}
 
-// Register 注册服务
+// Close 停止 watch 并等待处理 goroutine 退出
+func (r *etcdResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+// Register 注册服务，租约丢失后会自动退避重试重新注册，直到ctx被取消
 func (sr *ServiceRegistry) Register(ctx context.Context, service, addr string) error {
+	keepAliveCh, err := sr.register(ctx, service, addr)
+	if err != nil {
+		return err
+	}
+	sr.setState(StateRegistered)
+
+	// 处理租约续约
+	go sr.keepAliveWatch(ctx, service, addr, keepAliveCh)
+
+	return nil
+}
+
+// register 创建租约、写入endpoint并开始续约
+func (sr *ServiceRegistry) register(ctx context.Context, service, addr string) (_ <-chan *clientv3.LeaseKeepAliveResponse, err error) {
+	// etcd不可用时Grant会一直阻塞，单次注册操作需要有超时
+	opCtx, cancel := ctx, context.CancelFunc(func() {})
+	if sr.config.DialTimeout > 0 {
+		opCtx, cancel = context.WithTimeout(ctx, sr.config.DialTimeout)
+	}
+	defer cancel()
+
 	// 创建租约
-	lease, err := sr.client.Grant(ctx, 3)
+	lease, err := sr.client.Grant(opCtx, sr.leaseTTL())
 	if err != nil {
-		return fmt.Errorf("failed to create lease: %v", err)
+		return nil, fmt.Errorf("failed to create lease: %v", err)
 	}
-	sr.leaseID = lease.ID
+	// 之后的步骤失败时撤销租约，避免重试时每次泄漏一个租约
+	defer func() {
+		if err != nil {
+			sr.revokeLease(lease.ID)
+		}
+	}()
 
 	// 注册服务
 	manager, err := endpoints.NewManager(sr.client, service)
 	if err != nil {
-		return fmt.Errorf("failed to create endpoint manager: %v", err)
+		return nil, fmt.Errorf("failed to create endpoint manager: %v", err)
 	}
 
 	endpoint := fmt.Sprintf("%s/%s", service, addr)
-	err = manager.AddEndpoint(ctx, endpoint, endpoints.Endpoint{Addr: addr}, clientv3.WithLease(sr.leaseID))
+	err = manager.AddEndpoint(opCtx, endpoint, endpoints.Endpoint{Addr: addr}, clientv3.WithLease(lease.ID))
 	if err != nil {
-		return fmt.Errorf("failed to add endpoint: %v", err)
+		return nil, fmt.Errorf("failed to add endpoint: %v", err)
 	}
 
 	// 保持租约
-	keepAliveCh, err := sr.client.KeepAlive(ctx, sr.leaseID)
+	keepAliveCh, err := sr.client.KeepAlive(ctx, lease.ID)
 	if err != nil {
-		return fmt.Errorf("failed to keep alive lease: %v", err)
+		return nil, fmt.Errorf("failed to keep alive lease: %v", err)
 	}
+	sr.mu.Lock()
+	sr.leaseID = lease.ID
+	sr.mu.Unlock()
 
-	logrus.Infof("registered service %s at %s with leaseID %d", service, addr, sr.leaseID)
-
-	// 处理租约续约
-	go sr.keepAliveWatch(ctx, service, addr, keepAliveCh)
-
-	return nil
+	sr.logger.Info("registered service", "service", service, "addr", addr, "lease", int64(lease.ID))
+	return keepAliveCh, nil
 }
 
 // keepAliveWatch 监控租约续约
@@ -160,27 +338,103 @@ func (sr *ServiceRegistry) keepAliveWatch(ctx context.Context, service, addr str
 	for {
 		select {
 		case <-ctx.Done():
-			logrus.Info("context cancelled, stopping service registry")
-			sr.revokeLease(context.Background())
+			sr.logger.Info("context cancelled, stopping service registry", "service", service, "addr", addr)
+			sr.revokeLease(sr.currentLease())
+			sr.setState(StateUnregistered)
 			return
 
 		case resp, ok := <-keepAliveCh:
-			if !ok {
-				logrus.Warn("keep alive channel closed")
-				sr.revokeLease(context.Background())
-				return
+			if ok {
+				sr.logger.Debug("received keepalive response", "lease", int64(resp.ID), "ttl", resp.TTL)
+				continue
+			}
+			if ctx.Err() != nil {
+				keepAliveCh = nil
+				continue
 			}
-			logrus.Debugf("received keepalive response: %v", resp)
+			sr.logger.Warn("keep alive channel closed, lease lost", "service", service, "addr", addr, "lease", int64(sr.currentLease()))
+			sr.setState(StateLost)
+
+			keepAliveCh = sr.reRegister(ctx, service, addr)
+			if keepAliveCh == nil {
+				continue
+			}
+			sr.setState(StateRegistered)
 		}
 	}
 }
 
-// revokeLease 撤销租约
-func (sr *ServiceRegistry) revokeLease(ctx context.Context) {
-	if sr.leaseID != 0 {
-		if _, err := sr.client.Revoke(ctx, sr.leaseID); err != nil {
-			logrus.Errorf("failed to revoke lease: %v", err)
+// reRegister 以指数退避的方式重新注册，ctx取消时返回nil
+func (sr *ServiceRegistry) reRegister(ctx context.Context, service, addr string) <-chan *clientv3.LeaseKeepAliveResponse {
+	b := newBackoff(sr.config.RetryInterval, sr.config.MaxRetryInterval)
+	for attempt := 1; ; attempt++ {
+		timer := time.NewTimer(b.next())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
 		}
+
+		keepAliveCh, err := sr.register(ctx, service, addr)
+		if err == nil {
+			sr.logger.Info("re-registered service", "service", service, "addr", addr, "attempts", attempt)
+			return keepAliveCh
+		}
+		sr.logger.Warn("failed to re-register service", "service", service, "addr", addr, "attempt", attempt, "error", err)
+	}
+}
+
+// backoff 指数退避，每次翻倍直到上限
+type backoff struct {
+	cur, max time.Duration
+}
+
+func newBackoff(initial, max time.Duration) *backoff {
+	if initial <= 0 {
+		initial = defaultRetryInterval
+	}
+	if max <= 0 {
+		max = defaultMaxRetryInterval
+	}
+	return &backoff{cur: min(initial, max), max: max}
+}
+
+// next 返回本次等待时间，加入抖动避免大量节点同时重连etcd，结果在[cur/2, cur]之间
+func (b *backoff) next() time.Duration {
+	wait := b.cur/2 + time.Duration(rand.Int63n(int64(b.cur/2)+1))
+	b.cur = min(b.cur*2, b.max)
+	return wait
+}
+
+// leaseTTL 返回配置的租约TTL
+func (sr *ServiceRegistry) leaseTTL() int64 {
+	if sr.config.LeaseTTL > 0 {
+		return sr.config.LeaseTTL
+	}
+	return defaultLeaseTTL
+}
+
+// currentLease 返回当前租约ID
+func (sr *ServiceRegistry) currentLease() clientv3.LeaseID {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	return sr.leaseID
+}
+
+// revokeLease 撤销租约，etcd不可用时最多等待DialTimeout，租约之后会按TTL自然过期
+func (sr *ServiceRegistry) revokeLease(leaseID clientv3.LeaseID) {
+	if leaseID == 0 {
+		return
+	}
+	timeout := sr.config.DialTimeout
+	if timeout <= 0 {
+		timeout = defaultRevokeTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if _, err := sr.client.Revoke(ctx, leaseID); err != nil {
+		sr.logger.Error("failed to revoke lease", "lease", int64(leaseID), "error", err)
 	}
 }
 
@@ -0,0 +1,263 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"gocache/logger"
+	"sync"
+	"testing"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/naming/endpoints"
+	"google.golang.org/grpc/resolver"
+)
+
+type fakeClientConn struct {
+	resolver.ClientConn
+	state resolver.State
+}
+
+func (f *fakeClientConn) UpdateState(state resolver.State) error {
+	f.state = state
+	return nil
+}
+
+func TestParseTargetAddr(t *testing.T) {
+	cases := map[string]string{
+		"lcache/127.0.0.1:8001": "127.0.0.1:8001",
+		"lcache/":               "",
+		"lcache":                "",
+	}
+	for endpoint, want := range cases {
+		if got := parseTargetAddr(endpoint, "lcache"); got != want {
+			t.Errorf("parseTargetAddr(%q) = %q, want %q", endpoint, got, want)
+		}
+	}
+}
+
+func TestResolver_ApplyUpdatesFilter(t *testing.T) {
+	cc := &fakeClientConn{}
+	r := &etcdResolver{
+		cc:         cc,
+		filterAddr: "127.0.0.1:8002",
+		addrsStore: make(map[string]string),
+	}
+
+	r.applyUpdates([]*endpoints.Update{
+		{Op: endpoints.Add, Key: "lcache/127.0.0.1:8001", Endpoint: endpoints.Endpoint{Addr: "127.0.0.1:8001"}},
+		{Op: endpoints.Add, Key: "lcache/127.0.0.1:8002", Endpoint: endpoints.Endpoint{Addr: "127.0.0.1:8002"}},
+	})
+	if len(cc.state.Addresses) != 1 || cc.state.Addresses[0].Addr != "127.0.0.1:8002" {
+		t.Fatalf("expect only pinned addr, got %v", cc.state.Addresses)
+	}
+
+	r.applyUpdates([]*endpoints.Update{
+		{Op: endpoints.Delete, Key: "lcache/127.0.0.1:8002"},
+	})
+	if len(cc.state.Addresses) != 0 {
+		t.Fatalf("expect no addr after delete, got %v", cc.state.Addresses)
+	}
+	if len(r.addrsStore) != 1 {
+		t.Fatalf("expect 1 addr in store, got %d", len(r.addrsStore))
+	}
+}
+
+// fakeLease 模拟etcd租约，Grant按grantErrs依次失败，KeepAlive返回的channel由测试关闭。
+// keepAliveErr不为nil时KeepAlive失败，hangRevoke为true时Revoke阻塞到ctx取消
+type fakeLease struct {
+	clientv3.Lease
+	mu           sync.Mutex
+	grantErrs    []error
+	grants       int
+	nextID       clientv3.LeaseID
+	revoked      []clientv3.LeaseID
+	keepAlive    chan chan *clientv3.LeaseKeepAliveResponse
+	keepAliveErr error
+	hangRevoke   bool
+}
+
+func (f *fakeLease) Grant(ctx context.Context, ttl int64) (*clientv3.LeaseGrantResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.grants++
+	if len(f.grantErrs) > 0 {
+		err := f.grantErrs[0]
+		f.grantErrs = f.grantErrs[1:]
+		return nil, err
+	}
+	f.nextID++
+	return &clientv3.LeaseGrantResponse{ID: f.nextID, TTL: ttl}, nil
+}
+
+func (f *fakeLease) KeepAlive(ctx context.Context, id clientv3.LeaseID) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	if f.keepAliveErr != nil {
+		return nil, f.keepAliveErr
+	}
+	ch := make(chan *clientv3.LeaseKeepAliveResponse)
+	f.keepAlive <- ch
+	return ch, nil
+}
+
+func (f *fakeLease) Revoke(ctx context.Context, id clientv3.LeaseID) (*clientv3.LeaseRevokeResponse, error) {
+	if f.hangRevoke {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.revoked = append(f.revoked, id)
+	return &clientv3.LeaseRevokeResponse{}, nil
+}
+
+// fakeKV 接受endpoint manager的写入事务
+type fakeKV struct {
+	clientv3.KV
+}
+
+func (fakeKV) Txn(ctx context.Context) clientv3.Txn {
+	return fakeTxn{}
+}
+
+type fakeTxn struct {
+	clientv3.Txn
+}
+
+func (t fakeTxn) Then(ops ...clientv3.Op) clientv3.Txn { return t }
+
+func (fakeTxn) Commit() (*clientv3.TxnResponse, error) {
+	return &clientv3.TxnResponse{Succeeded: true}, nil
+}
+
+func TestRegistry_ReRegisterAfterLeaseLoss(t *testing.T) {
+	lease := &fakeLease{
+		keepAlive: make(chan chan *clientv3.LeaseKeepAliveResponse, 1),
+	}
+	states := make(chan State, 8)
+	sr := &ServiceRegistry{
+		client:   &clientv3.Client{KV: fakeKV{}, Lease: lease},
+		config:   &Config{RetryInterval: time.Millisecond, MaxRetryInterval: 4 * time.Millisecond},
+		onChange: func(s State) { states <- s },
+		logger:   logger.New("registry", nil),
+	}
+	expectState := func(want State) {
+		t.Helper()
+		select {
+		case got := <-states:
+			if got != want {
+				t.Fatalf("expect state %v, got %v", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for state %v", want)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := sr.Register(ctx, "svc", "127.0.0.1:8001"); err != nil {
+		t.Fatal(err)
+	}
+	expectState(StateRegistered)
+	ch := <-lease.keepAlive
+
+	// 租约丢失后重新注册，前两次Grant失败
+	lease.mu.Lock()
+	lease.grantErrs = []error{errors.New("etcd unavailable"), errors.New("etcd unavailable")}
+	lease.mu.Unlock()
+	close(ch)
+	expectState(StateLost)
+	<-lease.keepAlive
+	expectState(StateRegistered)
+	if sr.State() != StateRegistered || sr.currentLease() != 2 {
+		t.Fatalf("expect new lease after re-register, got %v lease %d", sr.State(), sr.currentLease())
+	}
+	lease.mu.Lock()
+	if lease.grants != 4 {
+		t.Fatalf("expect 2 failed grants before re-register, got %d grants", lease.grants)
+	}
+	lease.mu.Unlock()
+
+	// ctx取消时撤销当前租约
+	cancel()
+	expectState(StateUnregistered)
+	lease.mu.Lock()
+	defer lease.mu.Unlock()
+	if len(lease.revoked) != 1 || lease.revoked[0] != 2 {
+		t.Fatalf("expect current lease revoked, got %v", lease.revoked)
+	}
+}
+
+func TestRegistry_RevokeLease(t *testing.T) {
+	newRegistry := func(lease *fakeLease) *ServiceRegistry {
+		return &ServiceRegistry{
+			client: &clientv3.Client{KV: fakeKV{}, Lease: lease},
+			config: &Config{DialTimeout: 20 * time.Millisecond},
+			logger: logger.New("registry", nil),
+		}
+	}
+
+	// 续约失败时撤销刚创建的租约
+	lease := &fakeLease{keepAliveErr: errors.New("keep alive failed")}
+	sr := newRegistry(lease)
+	if err := sr.Register(context.Background(), "svc", "127.0.0.1:8001"); err == nil {
+		t.Fatal("expect register error")
+	}
+	if len(lease.revoked) != 1 || lease.revoked[0] != 1 || sr.currentLease() != 0 {
+		t.Fatalf("expect failed lease revoked, got %v current %d", lease.revoked, sr.currentLease())
+	}
+
+	// etcd不可用时撤销租约不会一直阻塞
+	lease = &fakeLease{keepAlive: make(chan chan *clientv3.LeaseKeepAliveResponse, 1), hangRevoke: true}
+	sr = newRegistry(lease)
+	states := make(chan State, 2)
+	sr.onChange = func(s State) { states <- s }
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := sr.Register(ctx, "svc", "127.0.0.1:8001"); err != nil {
+		t.Fatal(err)
+	}
+	<-states
+	cancel()
+	select {
+	case s := <-states:
+		if s != StateUnregistered {
+			t.Fatalf("expect unregistered, got %v", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for shutdown while etcd is unreachable")
+	}
+}
+
+func TestBackoff_CeilingAndJitter(t *testing.T) {
+	b := newBackoff(10*time.Millisecond, 80*time.Millisecond)
+	want := []time.Duration{10, 20, 40, 80, 80, 80}
+	for i, cur := range want {
+		cur *= time.Millisecond
+		for j := 0; j < 100; j++ {
+			probe := *b
+			if wait := probe.next(); wait < cur/2 || wait > cur {
+				t.Fatalf("attempt %d: wait %v out of [%v, %v]", i, wait, cur/2, cur)
+			}
+		}
+		b.next()
+	}
+
+	if b := newBackoff(0, 0); b.cur != defaultRetryInterval || b.max != defaultMaxRetryInterval {
+		t.Fatalf("expect defaults, got %+v", b)
+	}
+	if b := newBackoff(time.Minute, time.Second); b.cur != time.Second {
+		t.Fatalf("expect initial capped by max, got %v", b.cur)
+	}
+}
+
+func TestState_String(t *testing.T) {
+	for s, want := range map[State]string{
+		StateUnregistered: "unregistered",
+		StateRegistered:   "registered",
+		StateLost:         "lost",
+		State(9):          "State(9)",
+	} {
+		if s.String() != want {
+			t.Errorf("expect %q, got %q", want, s.String())
+		}
+	}
+}
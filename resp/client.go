@@ -0,0 +1,95 @@
+package resp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ServerError 服务端返回的错误回复
+type ServerError string
+
+func (e ServerError) Error() string { return string(e) }
+
+// Client 简单的同步RESP客户端，不支持并发使用
+type Client struct {
+	conn net.Conn
+	r    *Reader
+	w    *Writer
+	// Timeout 每条命令的读写超时，为0时不设置
+	Timeout time.Duration
+}
+
+// Dial 连接RESP服务端，tlsConfig不为nil时使用TLS
+func Dial(addr string, tlsConfig *tls.Config) (*Client, error) {
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		conn, err = tls.Dial("tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient 在已建立的连接上创建客户端
+func NewClient(conn net.Conn) *Client {
+	return &Client{conn: conn, r: NewReader(conn), w: NewWriter(conn)}
+}
+
+// Do 发送一条命令并读取回复，服务端返回错误时err为ServerError
+func (c *Client) Do(args ...string) (Value, error) {
+	if c.Timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.Timeout))
+	}
+	cmd := Value{Kind: Array, Elems: make([]Value, len(args))}
+	for i, arg := range args {
+		cmd.Elems[i] = Value{Kind: BulkString, Bulk: []byte(arg)}
+	}
+	c.w.WriteValue(cmd)
+	if err := c.w.Flush(); err != nil {
+		return Value{}, err
+	}
+
+	v, err := c.r.ReadValue()
+	if err != nil {
+		return Value{}, err
+	}
+	if v.Kind == Error || v.Kind == BulkError {
+		return v, ServerError(v.Text())
+	}
+	return v, nil
+}
+
+// String 发送命令并返回字符串形式的回复，空值返回错误
+func (c *Client) String(args ...string) (string, error) {
+	v, err := c.Do(args...)
+	if err != nil {
+		return "", err
+	}
+	if v.IsNil() {
+		return "", fmt.Errorf("nil reply")
+	}
+	return v.Text(), nil
+}
+
+// Int 发送命令并返回整数回复
+func (c *Client) Int(args ...string) (int64, error) {
+	v, err := c.Do(args...)
+	if err != nil {
+		return 0, err
+	}
+	if v.Kind != Integer {
+		return 0, fmt.Errorf("unexpected reply type %q", v.Kind)
+	}
+	return v.Int, nil
+}
+
+// Close 关闭连接
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,402 @@
+// Package resp 实现Redis序列化协议(RESP2/RESP3)的编解码
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Kind RESP值的类型，取值为协议中的类型前缀
+type Kind byte
+
+const (
+	SimpleString Kind = '+'
+	Error        Kind = '-'
+	Integer      Kind = ':'
+	BulkString   Kind = '$'
+	Array        Kind = '*'
+	// 以下为RESP3类型
+	Null      Kind = '_'
+	Double    Kind = ','
+	Boolean   Kind = '#'
+	BigNumber Kind = '('
+	BulkError Kind = '!'
+	Verbatim  Kind = '='
+	Map       Kind = '%'
+	Set       Kind = '~'
+	Push      Kind = '>'
+	Attribute Kind = '|'
+)
+
+const (
+	// MaxBulkLen 单个bulk string的最大长度，与Redis的proto-max-bulk-len默认值一致
+	MaxBulkLen = 512 << 20
+	// MaxArrayLen 单个聚合类型的最大元素数
+	MaxArrayLen = 1 << 20
+	// MaxDepth 聚合类型的最大嵌套深度
+	MaxDepth = 32
+	// maxInlineLen inline命令的最大长度
+	maxInlineLen = 64 << 10
+	// bulkChunk 按块读取bulk string，内存随实际收到的数据增长，不按对端声明的长度一次分配
+	bulkChunk = 64 << 10
+	// elemsPrealloc 聚合类型预分配的最大元素数，其余随读取增长
+	elemsPrealloc = 1024
+)
+
+// Limits 读取时允许的最大长度和嵌套深度，字段为0时使用MaxBulkLen、MaxArrayLen和MaxDepth
+type Limits struct {
+	MaxBulkLen  int
+	MaxArrayLen int
+	MaxDepth    int
+}
+
+func (l Limits) bulkLen() int {
+	if l.MaxBulkLen > 0 {
+		return l.MaxBulkLen
+	}
+	return MaxBulkLen
+}
+
+func (l Limits) arrayLen() int {
+	if l.MaxArrayLen > 0 {
+		return l.MaxArrayLen
+	}
+	return MaxArrayLen
+}
+
+func (l Limits) depth() int {
+	if l.MaxDepth > 0 {
+		return l.MaxDepth
+	}
+	return MaxDepth
+}
+
+// ErrProtocol 对端发送了不合法的数据
+var ErrProtocol = errors.New("resp: protocol error")
+
+// Value 一个RESP值。Str用于简单字符串、错误、Double、BigNumber和Verbatim，
+// Bulk用于bulk string，Elems用于数组、Set、Push以及Map(按key、value交替存放)
+type Value struct {
+	Kind  Kind
+	Str   string
+	Int   int64
+	Bool  bool
+	Bulk  []byte
+	Elems []Value
+	// IsNull 为true表示RESP2中的空bulk string或空数组，RESP3中Kind为Null
+	IsNull bool
+}
+
+// IsNil 是否为空值
+func (v Value) IsNil() bool {
+	return v.IsNull || v.Kind == Null
+}
+
+// Text 返回值的字符串形式，用于简单字符串、bulk string、错误等
+func (v Value) Text() string {
+	switch v.Kind {
+	case BulkString, BulkError:
+		return string(v.Bulk)
+	case Integer:
+		return strconv.FormatInt(v.Int, 10)
+	case Verbatim:
+		// Verbatim格式为"txt:内容"
+		if len(v.Str) > 4 && v.Str[3] == ':' {
+			return v.Str[4:]
+		}
+		return v.Str
+	default:
+		return v.Str
+	}
+}
+
+// Reader 从连接中读取RESP值
+type Reader struct {
+	br *bufio.Reader
+	// Limits 限制对端发送的值，服务端可以在连接认证前使用更严格的限制
+	Limits Limits
+}
+
+// NewReader 创建Reader
+func NewReader(r io.Reader) *Reader {
+	return &Reader{br: bufio.NewReader(r)}
+}
+
+// Buffered 返回已读入缓冲区但尚未解析的字节数
+func (r *Reader) Buffered() int {
+	return r.br.Buffered()
+}
+
+// ReadCommand 读取一条命令，支持bulk string数组和telnet风格的inline命令
+func (r *Reader) ReadCommand() ([][]byte, error) {
+	b, err := r.br.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if Kind(b[0]) != Array {
+		line, err := r.readLine(maxInlineLen)
+		if err != nil {
+			return nil, err
+		}
+		var args [][]byte
+		for _, f := range strings.Fields(string(line)) {
+			args = append(args, []byte(f))
+		}
+		return args, nil
+	}
+
+	// 命令是bulk string组成的数组，不允许嵌套
+	v, err := r.readValue(0, 1)
+	if err != nil {
+		return nil, err
+	}
+	args := make([][]byte, 0, len(v.Elems))
+	for _, e := range v.Elems {
+		if e.Kind != BulkString || e.IsNull {
+			return nil, fmt.Errorf("%w: expected bulk string in command", ErrProtocol)
+		}
+		args = append(args, e.Bulk)
+	}
+	return args, nil
+}
+
+// ReadValue 读取一个完整的RESP值
+func (r *Reader) ReadValue() (Value, error) {
+	return r.readValue(0, r.Limits.depth())
+}
+
+// readValue 读取一个值，depth为当前的嵌套深度，聚合类型只能出现在maxDepth之内
+func (r *Reader) readValue(depth, maxDepth int) (Value, error) {
+	line, err := r.readLine(maxInlineLen)
+	if err != nil {
+		return Value{}, err
+	}
+	if len(line) == 0 {
+		return Value{}, fmt.Errorf("%w: empty line", ErrProtocol)
+	}
+	kind, body := Kind(line[0]), string(line[1:])
+
+	switch kind {
+	case SimpleString, Error, Double, BigNumber:
+		return Value{Kind: kind, Str: body}, nil
+	case Integer:
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil {
+			return Value{}, fmt.Errorf("%w: invalid integer %q", ErrProtocol, body)
+		}
+		return Value{Kind: kind, Int: n}, nil
+	case Null:
+		return Value{Kind: Null}, nil
+	case Boolean:
+		if body != "t" && body != "f" {
+			return Value{}, fmt.Errorf("%w: invalid boolean %q", ErrProtocol, body)
+		}
+		return Value{Kind: kind, Bool: body == "t"}, nil
+	case BulkString, BulkError, Verbatim:
+		n, err := parseLen(body, r.Limits.bulkLen())
+		if err != nil {
+			return Value{}, err
+		}
+		if n < 0 {
+			return Value{Kind: kind, IsNull: true}, nil
+		}
+		buf, err := r.readBulk(n + 2)
+		if err != nil {
+			return Value{}, err
+		}
+		if buf[n] != '\r' || buf[n+1] != '\n' {
+			return Value{}, fmt.Errorf("%w: bulk string not terminated by CRLF", ErrProtocol)
+		}
+		if kind == Verbatim {
+			return Value{Kind: kind, Str: string(buf[:n])}, nil
+		}
+		return Value{Kind: kind, Bulk: buf[:n]}, nil
+	case Array, Set, Push, Map, Attribute:
+		if depth >= maxDepth {
+			return Value{}, fmt.Errorf("%w: nesting too deep", ErrProtocol)
+		}
+		n, err := parseLen(body, r.Limits.arrayLen())
+		if err != nil {
+			return Value{}, err
+		}
+		if n < 0 {
+			return Value{Kind: kind, IsNull: true}, nil
+		}
+		if kind == Map || kind == Attribute {
+			n *= 2
+		}
+		v := Value{Kind: kind, Elems: make([]Value, 0, min(n, elemsPrealloc))}
+		for i := 0; i < n; i++ {
+			e, err := r.readValue(depth+1, maxDepth)
+			if err != nil {
+				return Value{}, err
+			}
+			v.Elems = append(v.Elems, e)
+		}
+		if kind == Attribute {
+			// 属性附加在下一个值上，调用方不关心时直接跳过
+			return r.readValue(depth, maxDepth)
+		}
+		return v, nil
+	default:
+		return Value{}, fmt.Errorf("%w: unknown type %q", ErrProtocol, line[0])
+	}
+}
+
+// readBulk 按块读取n个字节
+func (r *Reader) readBulk(n int) ([]byte, error) {
+	buf := make([]byte, 0, min(n, bulkChunk))
+	for len(buf) < n {
+		m := min(n-len(buf), bulkChunk)
+		buf = slices.Grow(buf, m)
+		k, err := io.ReadFull(r.br, buf[len(buf):len(buf)+m])
+		buf = buf[:len(buf)+k]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// readLine 读取一行，不包含行尾的CRLF。为兼容telnet等工具也接受只以LF结尾的行
+func (r *Reader) readLine(limit int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.br.ReadSlice('\n')
+		line = append(line, chunk...)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+		if len(line) > limit {
+			return nil, fmt.Errorf("%w: line too long", ErrProtocol)
+		}
+	}
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line, nil
+}
+
+func parseLen(s string, limit int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < -1 || n > limit {
+		return 0, fmt.Errorf("%w: invalid length %q", ErrProtocol, s)
+	}
+	return n, nil
+}
+
+// Writer 向连接写入RESP值。Proto为3时使用RESP3的空值和Map类型，否则按RESP2编码
+type Writer struct {
+	bw    *bufio.Writer
+	Proto int
+}
+
+// NewWriter 创建RESP2编码的Writer
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{bw: bufio.NewWriter(w), Proto: 2}
+}
+
+func (w *Writer) writeHeader(kind Kind, n int64) {
+	w.bw.WriteByte(byte(kind))
+	w.bw.WriteString(strconv.FormatInt(n, 10))
+	w.bw.WriteString("\r\n")
+}
+
+// WriteSimple 写入简单字符串，如OK、PONG
+func (w *Writer) WriteSimple(s string) {
+	w.bw.WriteByte(byte(SimpleString))
+	w.bw.WriteString(s)
+	w.bw.WriteString("\r\n")
+}
+
+// WriteError 写入错误，msg应以错误码开头，如"ERR syntax error"
+func (w *Writer) WriteError(msg string) {
+	w.bw.WriteByte(byte(Error))
+	w.bw.WriteString(strings.NewReplacer("\r", " ", "\n", " ").Replace(msg))
+	w.bw.WriteString("\r\n")
+}
+
+// WriteInt 写入整数
+func (w *Writer) WriteInt(n int64) {
+	w.writeHeader(Integer, n)
+}
+
+// WriteBulk 写入bulk string
+func (w *Writer) WriteBulk(b []byte) {
+	w.writeHeader(BulkString, int64(len(b)))
+	w.bw.Write(b)
+	w.bw.WriteString("\r\n")
+}
+
+// WriteBulkString 写入字符串形式的bulk string
+func (w *Writer) WriteBulkString(s string) {
+	w.writeHeader(BulkString, int64(len(s)))
+	w.bw.WriteString(s)
+	w.bw.WriteString("\r\n")
+}
+
+// WriteNull 写入空值，RESP2中为空bulk string
+func (w *Writer) WriteNull() {
+	if w.Proto >= 3 {
+		w.bw.WriteString("_\r\n")
+		return
+	}
+	w.bw.WriteString("$-1\r\n")
+}
+
+// WriteArray 写入数组头，随后需要写入n个元素
+func (w *Writer) WriteArray(n int) {
+	w.writeHeader(Array, int64(n))
+}
+
+// WriteMap 写入Map头，随后需要交替写入n对key和value。RESP2中编码为2n个元素的数组
+func (w *Writer) WriteMap(n int) {
+	if w.Proto >= 3 {
+		w.writeHeader(Map, int64(n))
+		return
+	}
+	w.writeHeader(Array, int64(2*n))
+}
+
+// WriteValue 写入任意值，用于客户端发送命令和测试
+func (w *Writer) WriteValue(v Value) {
+	switch v.Kind {
+	case SimpleString:
+		w.WriteSimple(v.Str)
+	case Error:
+		w.WriteError(v.Str)
+	case Integer:
+		w.WriteInt(v.Int)
+	case Null:
+		w.WriteNull()
+	case BulkString:
+		if v.IsNull {
+			w.WriteNull()
+			return
+		}
+		w.WriteBulk(v.Bulk)
+	default:
+		n := len(v.Elems)
+		if v.Kind == Map {
+			n /= 2
+		}
+		w.writeHeader(v.Kind, int64(n))
+		for _, e := range v.Elems {
+			w.WriteValue(e)
+		}
+	}
+}
+
+// Flush 将缓冲的数据写入连接
+func (w *Writer) Flush() error {
+	return w.bw.Flush()
+}
@@ -0,0 +1,144 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReader_ReadCommand(t *testing.T) {
+	r := NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n$3\r\nkey\r\nset  k v\nPING\r\n"))
+	for _, want := range []string{"GET key", "set k v", "PING"} {
+		args, err := r.ReadCommand()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(bytes.Join(args, []byte(" "))); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+
+	r = NewReader(strings.NewReader("*1\r\n:1\r\n"))
+	if _, err := r.ReadCommand(); !errors.Is(err, ErrProtocol) {
+		t.Fatalf("expect protocol error, got %v", err)
+	}
+}
+
+func TestReader_ReadValue(t *testing.T) {
+	tests := map[string]Value{
+		"+OK\r\n":                     {Kind: SimpleString, Str: "OK"},
+		"-ERR bad\r\n":                {Kind: Error, Str: "ERR bad"},
+		":-42\r\n":                    {Kind: Integer, Int: -42},
+		"$-1\r\n":                     {Kind: BulkString, IsNull: true},
+		"*-1\r\n":                     {Kind: Array, IsNull: true},
+		"_\r\n":                       {Kind: Null},
+		"#t\r\n":                      {Kind: Boolean, Bool: true},
+		",3.14\r\n":                   {Kind: Double, Str: "3.14"},
+		"=7\r\ntxt:abc\r\n":           {Kind: Verbatim, Str: "txt:abc"},
+		"%1\r\n+k\r\n:1\r\n":          {Kind: Map, Elems: []Value{{Kind: SimpleString, Str: "k"}, {Kind: Integer, Int: 1}}},
+		"|1\r\n+a\r\n+b\r\n:7\r\n":    {Kind: Integer, Int: 7},
+		"$5\r\na\r\nbc\r\n":           {Kind: BulkString, Bulk: []byte("a\r\nbc")},
+		"~2\r\n$1\r\na\r\n$0\r\n\r\n": {Kind: Set, Elems: []Value{{Kind: BulkString, Bulk: []byte("a")}, {Kind: BulkString, Bulk: []byte{}}}},
+		">1\r\n+message\r\n":          {Kind: Push, Elems: []Value{{Kind: SimpleString, Str: "message"}}},
+		"*2\r\n*1\r\n:1\r\n$-1\r\n":   {Kind: Array, Elems: []Value{{Kind: Array, Elems: []Value{{Kind: Integer, Int: 1}}}, {Kind: BulkString, IsNull: true}}},
+	}
+	for in, want := range tests {
+		got, err := NewReader(strings.NewReader(in)).ReadValue()
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %+v, want %+v", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"?\r\n", ":abc\r\n", "$3\r\nabcd\r\n", "$-2\r\n", "#x\r\n", "*9999999999\r\n"} {
+		if _, err := NewReader(strings.NewReader(in)).ReadValue(); !errors.Is(err, ErrProtocol) {
+			t.Errorf("%q: expect protocol error, got %v", in, err)
+		}
+	}
+}
+
+func TestReader_Limits(t *testing.T) {
+	// 声明的长度远大于实际数据时，内存只随收到的数据增长
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := NewReader(strings.NewReader("$536870000\r\nabc")).ReadValue()
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expect unexpected EOF, got %v", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Fatalf("expect allocation bounded by received data, got %d bytes", n)
+	}
+
+	deep := strings.Repeat("*1\r\n", MaxDepth+1) + ":1\r\n"
+	if _, err := NewReader(strings.NewReader(deep)).ReadValue(); !errors.Is(err, ErrProtocol) {
+		t.Fatalf("expect protocol error for deep nesting, got %v", err)
+	}
+	if _, err := NewReader(strings.NewReader("*1\r\n*1\r\n$1\r\na\r\n")).ReadCommand(); !errors.Is(err, ErrProtocol) {
+		t.Fatalf("expect nested command rejected, got %v", err)
+	}
+
+	limited := func(in string) *Reader {
+		r := NewReader(strings.NewReader(in))
+		r.Limits = Limits{MaxBulkLen: 4, MaxArrayLen: 2}
+		return r
+	}
+	for _, in := range []string{"$5\r\nhello\r\n", "*3\r\n:1\r\n:2\r\n:3\r\n"} {
+		if _, err := limited(in).ReadValue(); !errors.Is(err, ErrProtocol) {
+			t.Errorf("%q: expect protocol error, got %v", in, err)
+		}
+	}
+	if v, err := limited("*2\r\n$4\r\nabcd\r\n:1\r\n").ReadValue(); err != nil || len(v.Elems) != 2 {
+		t.Fatalf("expect value within limits read, got %+v %v", v, err)
+	}
+
+	// 超过一个块的bulk string完整读取
+	big := strings.Repeat("y", 3*bulkChunk+7)
+	if v, err := NewReader(strings.NewReader("$" + strconv.Itoa(len(big)) + "\r\n" + big + "\r\n")).ReadValue(); err != nil || string(v.Bulk) != big {
+		t.Fatalf("expect chunked bulk string read, got %d bytes %v", len(v.Bulk), err)
+	}
+}
+
+func TestWriter_Proto(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.WriteNull()
+	w.WriteMap(1)
+	w.WriteBulkString("k")
+	w.WriteInt(1)
+	w.Proto = 3
+	w.WriteNull()
+	w.WriteMap(1)
+	w.WriteError("ERR a\r\nb")
+	w.Flush()
+
+	want := "$-1\r\n*2\r\n$1\r\nk\r\n:1\r\n_\r\n%1\r\n-ERR a  b\r\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriter_RoundTrip(t *testing.T) {
+	v := Value{Kind: Map, Elems: []Value{
+		{Kind: BulkString, Bulk: []byte("list")},
+		{Kind: Array, Elems: []Value{{Kind: Integer, Int: 1}, {Kind: SimpleString, Str: "two"}, {Kind: Null}}},
+	}}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Proto = 3
+	w.WriteValue(v)
+	w.Flush()
+
+	got, err := NewReader(&buf).ReadValue()
+	if err != nil || !reflect.DeepEqual(got, v) {
+		t.Fatalf("got %+v %v, want %+v", got, err, v)
+	}
+}
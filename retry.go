@@ -0,0 +1,80 @@
+package gocache
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy 幂等请求(Get/Delete)的重试策略
+type RetryPolicy struct {
+	MaxAttempts    int           // 包含首次请求在内的最大尝试次数
+	InitialBackoff time.Duration // 首次重试前的等待时间
+	MaxBackoff     time.Duration // 最大等待时间
+	Multiplier     float64       // 每次重试等待时间的增长倍数
+	Jitter         float64       // 随机抖动比例，取值0~1
+}
+
+// DefaultRetryPolicy 默认重试策略
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 50 * time.Millisecond,
+	MaxBackoff:     time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// backoff 计算第n次重试(从1开始)前的等待时间
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	d := float64(p.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	return time.Duration(d)
+}
+
+// isRetryable 判断错误是否值得重试，超时说明整体deadline已用完，不再重试
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+// sleepContext 等待d或ctx结束
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// HedgePolicy Get请求的对冲策略：首个请求在Delay内未返回时向哈希环上的下一个peer再发出一个请求，取先返回的结果。
+// 对冲请求经过目标peer的熔断器，目标peer回源后不写入缓存。只对ClientPicker创建的Client生效
+type HedgePolicy struct {
+	Delay     time.Duration // 固定对冲延迟，为0时使用观测到的p95延迟
+	MinDelay  time.Duration // 使用p95时的下限，避免样本不足时过早对冲
+	MaxHedges int           // 额外发出的最大请求数，每个请求发往不同的peer
+}
+
+// DefaultHedgePolicy 默认对冲策略
+var DefaultHedgePolicy = &HedgePolicy{
+	MinDelay:  10 * time.Millisecond,
+	MaxHedges: 1,
+}
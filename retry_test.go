@@ -0,0 +1,27 @@
+package gocache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := &RetryPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+		Multiplier:     2,
+	}
+	expected := []time.Duration{10, 20, 40, 50, 50}
+	for i, want := range expected {
+		if got := p.backoff(i + 1); got != want*time.Millisecond {
+			t.Errorf("retry %d: expect %v, got %v", i+1, want*time.Millisecond, got)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.backoff(1); d < 5*time.Millisecond || d > 15*time.Millisecond {
+			t.Fatalf("jittered backoff out of range: %v", d)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"gocache/logger"
+	"time"
+)
+
+// RevalidateConfig 软过期配置，只作用于从Getter加载的条目
+type RevalidateConfig struct {
+	// SoftTTL 条目加载后超过SoftTTL变为陈旧，Get立即返回旧值并在后台刷新
+	SoftTTL time.Duration
+	// HardTTL 条目加载后超过HardTTL被删除，之后的Get需要等待Getter。
+	// 为0时使用默认TTL，Getter返回的过期时间优先
+	HardTTL time.Duration
+	// RefreshAhead 大于0时，变为陈旧前RefreshAhead内被访问的条目提前在后台刷新。
+	// 只有访问频繁的key会在这段时间内被访问，不常用的key不会被刷新
+	RefreshAhead time.Duration
+}
+
+// WithStaleWhileRevalidate 开启软过期，陈旧的条目在硬过期前仍可读取，同一个key同时只有一次后台刷新。
+// 刷新失败时继续返回旧值，直到条目硬过期
+func WithStaleWhileRevalidate(cfg RevalidateConfig) GroupOption {
+	return func(g *Group) {
+		g.revalidate = cfg
+	}
+}
+
+// revalidateAfterHit 本地命中后检查条目，陈旧、即将陈旧或按XFetch需要提前过期时在后台刷新
+func (g *Group) revalidateAfterHit(key string, value ByteView) {
+	now := time.Now()
+	if !value.s.IsZero() && now.After(value.s) {
+		g.counters.staleHits.Add(1)
+		g.refresh(key, value.c)
+		return
+	}
+	ahead := !value.s.IsZero() && g.revalidate.RefreshAhead > 0 && value.s.Sub(now) < g.revalidate.RefreshAhead
+	if ahead || g.expireEarly(value, now) {
+		g.refresh(key, value.c)
+	}
+}
+
+// refresh 在后台调用Getter重新加载key，与前台的加载相互独立，前台请求不会等待刷新。
+// 只有条目的版本号仍为cas时才写入结果，刷新期间的写入和删除不会被覆盖；Getter中已不存在时删除该条目
+func (g *Group) refresh(key string, cas uint64) {
+	g.refresher.Go(key, func() (interface{}, error) {
+		g.counters.refreshes.Add(1)
+		v, err := g.loadFromGetter(context.Background(), key, cas)
+		if errors.Is(err, ErrNotFound) {
+			g.mainCache.drop(key, cas)
+		}
+		if err != nil {
+			g.logger.Debug("background refresh failed", logger.Key(key), "error", err)
+		}
+		return v, err
+	})
+}
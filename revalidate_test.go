@@ -0,0 +1,150 @@
+package gocache
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// versionGetter 每次加载返回递增的版本号，release不为nil时等待其关闭
+func versionGetter(loads *atomic.Int64, release <-chan struct{}) Getter {
+	return GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		n := loads.Add(1)
+		if release != nil && n > 1 {
+			<-release
+		}
+		return []byte(fmt.Sprintf("v%d", n)), true, time.Time{}
+	})
+}
+
+func TestRevalidate_StaleWhileRevalidate(t *testing.T) {
+	var loads atomic.Int64
+	release := make(chan struct{})
+	g := NewGroup("swr", 1<<10, versionGetter(&loads, release),
+		WithStaleWhileRevalidate(RevalidateConfig{SoftTTL: 30 * time.Millisecond, HardTTL: time.Hour}))
+	t.Cleanup(func() { DestroyGroup("swr") })
+	ctx := context.Background()
+
+	if v, _ := g.Get(ctx, "k"); v.String() != "v1" || v.TTL() <= 59*time.Minute {
+		t.Fatalf("expect hard ttl applied to loaded entry, got %q %v", v.String(), v.TTL())
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// Getter阻塞时陈旧的值仍立即返回，并且只有一次后台刷新
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		if v, err := g.Get(ctx, "k"); err != nil || v.String() != "v1" || time.Since(start) > 20*time.Millisecond {
+			t.Fatalf("expect stale value served immediately, got %q %v", v.String(), err)
+		}
+	}
+	close(release)
+	waitFor(t, func() bool {
+		v, _ := g.Get(ctx, "k")
+		return v.String() == "v2"
+	})
+	if stats := g.Stats(); loads.Load() != 2 || stats.Refreshes != 1 || stats.StaleHits < 5 {
+		t.Fatalf("expect a single refresh, got %d loads %+v", loads.Load(), stats)
+	}
+
+	// 直接写入的值没有软过期
+	g.Set(ctx, "set", []byte("x"))
+	time.Sleep(50 * time.Millisecond)
+	g.Get(ctx, "set")
+	if loads.Load() != 2 {
+		t.Fatal("expect written values not refreshed")
+	}
+}
+
+func TestRevalidate_HardTTL(t *testing.T) {
+	var loads atomic.Int64
+	g := NewGroup("swr-hard", 1<<10, versionGetter(&loads, nil),
+		WithStaleWhileRevalidate(RevalidateConfig{SoftTTL: 10 * time.Millisecond, HardTTL: 30 * time.Millisecond}))
+	t.Cleanup(func() { DestroyGroup("swr-hard") })
+	ctx := context.Background()
+
+	g.Get(ctx, "k")
+	time.Sleep(50 * time.Millisecond)
+	if v, _ := g.Get(ctx, "k"); v.String() != "v2" || g.Stats().StaleHits != 0 {
+		t.Fatalf("expect entry reloaded after hard ttl, got %q", v.String())
+	}
+}
+
+func TestRevalidate_RefreshAhead(t *testing.T) {
+	var loads atomic.Int64
+	g := NewGroup("refresh-ahead", 1<<10, versionGetter(&loads, nil), WithStaleWhileRevalidate(RevalidateConfig{
+		SoftTTL:      100 * time.Millisecond,
+		RefreshAhead: 80 * time.Millisecond,
+	}))
+	t.Cleanup(func() { DestroyGroup("refresh-ahead") })
+	ctx := context.Background()
+
+	g.Get(ctx, "hot")
+	g.Get(ctx, "cold")
+	time.Sleep(40 * time.Millisecond)
+	// 距软过期不足RefreshAhead时被访问，仍返回当前值并在后台刷新
+	if v, _ := g.Get(ctx, "hot"); v.String() != "v1" {
+		t.Fatalf("expect current value served while refreshing ahead, got %q", v.String())
+	}
+	waitFor(t, func() bool {
+		v, _ := g.Get(ctx, "hot")
+		return v.String() == "v3"
+	})
+	if stats := g.Stats(); loads.Load() != 3 || stats.Refreshes != 1 || stats.StaleHits != 0 {
+		t.Fatalf("expect only the hot key refreshed before going stale, got %d loads %+v", loads.Load(), stats)
+	}
+}
+
+func TestRevalidate_RefreshRace(t *testing.T) {
+	ctx := context.Background()
+	for name, mutate := range map[string]func(g *Group){
+		"set":    func(g *Group) { g.Set(ctx, "k", []byte("written")) },
+		"delete": func(g *Group) { g.Delete(ctx, "k") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var loads atomic.Int64
+			release := make(chan struct{})
+			g := NewGroup("swr-race", 1<<10, versionGetter(&loads, release),
+				WithStaleWhileRevalidate(RevalidateConfig{SoftTTL: 10 * time.Millisecond, HardTTL: time.Hour}))
+			t.Cleanup(func() { DestroyGroup("swr-race") })
+
+			g.Get(ctx, "k")
+			time.Sleep(20 * time.Millisecond)
+			g.Get(ctx, "k")
+			// 刷新期间的写入和删除不会被刷新结果覆盖
+			mutate(g)
+			close(release)
+			g.refresher.Do("k", func() (interface{}, error) { return nil, nil })
+			v, ok := g.mainCache.get("k")
+			if name == "set" && (!ok || v.String() != "written") || name == "delete" && ok {
+				t.Fatalf("expect refresh result discarded, got %q %v", v.String(), ok)
+			}
+		})
+	}
+
+	// Getter中已不存在时删除陈旧的条目
+	var gone atomic.Bool
+	g := NewGroup("swr-gone", 1<<10, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		return []byte("v"), !gone.Load(), time.Time{}
+	}), WithStaleWhileRevalidate(RevalidateConfig{SoftTTL: 10 * time.Millisecond, HardTTL: time.Hour}))
+	t.Cleanup(func() { DestroyGroup("swr-gone") })
+	g.Get(ctx, "k")
+	gone.Store(true)
+	time.Sleep(20 * time.Millisecond)
+	g.Get(ctx, "k")
+	waitFor(t, func() bool {
+		_, ok := g.mainCache.get("k")
+		return !ok
+	})
+}
+
+// waitFor 等待后台任务完成，最多1秒
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+	}
+}
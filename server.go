@@ -2,13 +2,25 @@ package gocache
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"gocache/auth"
+	"gocache/logger"
+	pb "gocache/pb"
+	"log/slog"
 	"net"
+	"net/http"
 	"strings"
 	"sync"
-	pb "github.com/juguagua/lcache/pb"
-	"github.com/sirupsen/logrus"
+	"time"
+
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -17,38 +29,131 @@ const (
 )
 
 type Server struct {
-	pb.UnimplementedLCacheServer
-	svcName string
-	svcAddr string
-	status  bool
-	mu      sync.Mutex
-	stopCh  chan error
+	pb.UnimplementedGoCacheServer
+	svcName    string
+	svcAddr    string
+	status     bool
+	ready      chan struct{} // Run开始监听后关闭
+	readyOnce  sync.Once
+	mu         sync.Mutex
+	grpcServer *grpc.Server
+	health     *health.Server
+	tlsConfig  *tls.Config
+	logger     *slog.Logger
+	baseLogger *slog.Logger // 前端在此基础上创建各自的logger
+
+	metricsAddr   string
+	metricsServer *http.Server
+
+	admin         bool
+	insecureAdmin bool
+	picker        *ClientPicker
+	redis         *redisServer
+	memcache      *memcacheServer
+	gateway       *httpGateway
+
+	authn        auth.Authenticator
+	acl          *auth.ACL
+	interceptors []grpc.UnaryServerInterceptor
 }
 
 type ServerOptions func(server *Server)
 
+// WithServerLogger 设置Server及其Redis、memcached和HTTP前端使用的logger
+func WithServerLogger(l *slog.Logger) ServerOptions {
+	return func(server *Server) {
+		server.baseLogger = l
+	}
+}
+
+// WithServerServiceName 设置服务名，需与ClientPicker的服务名一致，健康检查按该名称上报状态
+func WithServerServiceName(name string) ServerOptions {
+	return func(server *Server) {
+		server.svcName = name
+	}
+}
+
+// WithAuth 开启认证和按group授权，未授权的调用返回PermissionDenied
+func WithAuth(authn auth.Authenticator, acl *auth.ACL) ServerOptions {
+	return func(server *Server) {
+		server.authn, server.acl = authn, acl
+		server.interceptors = append(server.interceptors, auth.UnaryServerInterceptor(authn, acl, resolveServerOp))
+	}
+}
+
+// resolveServerOp 解析GoCache和Admin服务的方法
+func resolveServerOp(fullMethod string, req interface{}) (string, auth.Operation, bool) {
+	if group, op, ok := resolveCacheOp(fullMethod, req); ok {
+		return group, op, ok
+	}
+	return resolveAdminOp(fullMethod, req)
+}
+
+// resolveCacheOp 将GoCache服务的方法映射为ACL中的操作，其它服务(如健康检查)不做鉴权
+func resolveCacheOp(fullMethod string, req interface{}) (string, auth.Operation, bool) {
+	var op auth.Operation
+	switch fullMethod {
+	case pb.GoCache_Get_FullMethodName:
+		op = auth.OpRead
+	case pb.GoCache_Set_FullMethodName:
+		op = auth.OpWrite
+	case pb.GoCache_Delete_FullMethodName:
+		op = auth.OpDelete
+	case pb.GoCache_Stats_FullMethodName:
+		op = auth.OpRead
+	default:
+		return "", 0, false
+	}
+	r, _ := req.(interface{ GetGroup() string })
+	if r == nil {
+		return "", op, true
+	}
+	return r.GetGroup(), op, true
+}
+
+// WithMetricsAddr 在addr上启动HTTP服务，通过/metrics暴露Prometheus指标
+func WithMetricsAddr(addr string) ServerOptions {
+	return func(server *Server) {
+		server.metricsAddr = addr
+	}
+}
+
+// WithServerTLS 使用TLS提供服务，config要求校验客户端证书时即为mTLS
+func WithServerTLS(config *tls.Config) ServerOptions {
+	return func(server *Server) {
+		server.tlsConfig = config
+	}
+}
+
 func NewServer(addr string, opts ...ServerOptions) (*Server, error) {
 	if addr == "" {
 		addr = defaultAddr
 	}
 	if !ValidPeerAddr(addr) {
-		logrus.Errorf("invalid addr: %s", addr)
 		return nil, fmt.Errorf("invalid addr: %s", addr)
 	}
 	server := &Server{
 		svcAddr: addr,
 		svcName: defaultSvcName,
+		ready:   make(chan struct{}),
 	}
 	for _, opt := range opts {
 		opt(server)
 	}
+	if server.admin && server.authn == nil && !server.insecureAdmin {
+		return nil, fmt.Errorf("admin service requires WithAuth or WithInsecureAdmin")
+	}
+	server.logger = logger.New("server", server.baseLogger).With("addr", addr)
+	if server.admin && server.authn == nil {
+		server.logger.Warn("admin service is enabled without authentication")
+	}
 
 	return server, nil
 }
 
 func (s *Server) Get(ctx context.Context, in *pb.Request) (*pb.ResponseForGet, error) {
 	group, key := in.GetGroup(), in.GetKey()
-	logrus.Infof("lcache %s receive rpc requset, group: %s, key: %s", s.svcAddr, group, key)
+	s.logger.Debug("received get request", "group", group, logger.Key(key))
 
 	if key == "" {
 		return nil, fmt.Errorf("key is empty")
@@ -58,19 +163,86 @@ func (s *Server) Get(ctx context.Context, in *pb.Request) (*pb.ResponseForGet, e
 		return nil, fmt.Errorf("group %s not exist", group)
 	}
 
-	view, err := g.Get(key)
+	var view ByteView
+	var err error
+	if in.GetCacheOnly() {
+		view, err = g.lookup(ctx, key)
+	} else {
+		view, err = g.Get(ctx, key)
+	}
+	if errors.Is(err, ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "key not found")
+	}
 	if err != nil {
-		logrus.Errorf("lcache %s get key %s error: %v", s.svcAddr, key, err)
+		s.logger.Warn("get failed", "group", group, logger.Key(key), "error", err)
+		return nil, err
+	}
+	resp := &pb.ResponseForGet{
+		TtlMs:       ttlMillis(view.TTL()),
+		Flags:       view.Flags(),
+		Cas:         view.CAS(),
+		ContentType: view.ContentType(),
+	}
+	// 客户端能够解压时直接发送压缩的值
+	if in.GetAcceptCompressed() && view.z != CompressionNone {
+		resp.Value, resp.Compression = view.b, string(view.z)
+	} else {
+		resp.Value = view.ByteSlice()
+	}
+	return resp, nil
+}
+
+func (s *Server) Set(ctx context.Context, in *pb.Request) (*pb.ResponseForGet, error) {
+	group, key := in.GetGroup(), in.GetKey()
+	s.logger.Debug("received set request", "group", group, logger.Key(key))
+
+	if key == "" {
+		return nil, fmt.Errorf("key is empty")
+	}
+	g := GetGroup(group)
+	if g == nil {
+		return nil, fmt.Errorf("group %s not exist", group)
+	}
+
+	cas, err := g.Write(ctx, key, in.GetValue(), WriteOptions{
+		TTL:         time.Duration(in.GetTtlMs()) * time.Millisecond,
+		Flags:       in.GetFlags(),
+		CAS:         in.GetCas(),
+		Mode:        WriteMode(in.GetMode()),
+		ContentType: in.GetContentType(),
+		Expire:      in.GetExpire(),
+	})
+	switch {
+	case errors.Is(err, ErrNotStored):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrCASConflict):
+		return nil, status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, ErrNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case err != nil:
+		s.logger.Warn("set failed", "group", group, logger.Key(key), "error", err)
 		return nil, err
 	}
 	return &pb.ResponseForGet{
-		Value: view.ByteSlice(),
+		Value: in.GetValue(),
+		Cas:   cas,
 	}, nil
 }
 
+// ttlMillis 将剩余TTL转换为毫秒，不足1ms的按1ms计，避免被当作不过期
+func ttlMillis(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	if ms := ttl.Milliseconds(); ms > 0 {
+		return ms
+	}
+	return 1
+}
+
 func (s *Server) Delete(ctx context.Context, in *pb.Request) (*pb.ResponseForDelete, error) {
 	group, key := in.GetGroup(), in.GetKey()
-	logrus.Infof("lcache %s receive rpc requset, group: %s, key: %s", s.svcAddr, group, key)
+	s.logger.Debug("received delete request", "group", group, logger.Key(key))
 
 	if key == "" {
 		return nil, fmt.Errorf("key is empty")
@@ -80,9 +252,9 @@ func (s *Server) Delete(ctx context.Context, in *pb.Request) (*pb.ResponseForDel
 		return nil, fmt.Errorf("group %s not exist", group)
 	}
 
-	success, err := g.Delete(key)
+	success, err := g.Delete(ctx, key)
 	if err != nil {
-		logrus.Errorf("lcache %s delete key %s error: %v", s.svcAddr, key, err)
+		s.logger.Warn("delete failed", "group", group, logger.Key(key), "error", err)
 		return nil, err
 	}
 	return &pb.ResponseForDelete{
@@ -90,27 +262,205 @@ func (s *Server) Delete(ctx context.Context, in *pb.Request) (*pb.ResponseForDel
 	}, nil
 }
 
+// Stats 返回指定Group的统计快照
+func (s *Server) Stats(ctx context.Context, in *pb.StatsRequest) (*pb.ResponseForStats, error) {
+	g := GetGroup(in.GetGroup())
+	if g == nil {
+		return nil, fmt.Errorf("group %s not exist", in.GetGroup())
+	}
+	return statsToPB(g.Stats()), nil
+}
+
+func statsToPB(stats Stats) *pb.ResponseForStats {
+	return &pb.ResponseForStats{
+		Group:               stats.Name,
+		Gets:                stats.Gets,
+		Hits:                stats.Hits,
+		Misses:              stats.Misses,
+		PeerLoads:           stats.PeerLoads,
+		PeerErrors:          stats.PeerErrors,
+		LocalLoads:          stats.LocalLoads,
+		LocalLoadErrors:     stats.LocalLoadErrors,
+		Dedups:              stats.Dedups,
+		StaleHits:           stats.StaleHits,
+		Refreshes:           stats.Refreshes,
+		LoadLatencyP50Us:    stats.LoadLatencyP50.Microseconds(),
+		LoadLatencyP90Us:    stats.LoadLatencyP90.Microseconds(),
+		LoadLatencyP99Us:    stats.LoadLatencyP99.Microseconds(),
+		Bytes:               stats.Bytes,
+		Items:               int64(stats.Items),
+		Evictions:           stats.Evictions,
+		Expirations:         stats.Expirations,
+		CompressionInBytes:  stats.CompressionInBytes,
+		CompressionOutBytes: stats.CompressionOutBytes,
+	}
+}
+
+func statsFromPB(resp *pb.ResponseForStats) Stats {
+	return Stats{
+		Name:                resp.GetGroup(),
+		Gets:                resp.GetGets(),
+		Hits:                resp.GetHits(),
+		Misses:              resp.GetMisses(),
+		PeerLoads:           resp.GetPeerLoads(),
+		PeerErrors:          resp.GetPeerErrors(),
+		LocalLoads:          resp.GetLocalLoads(),
+		LocalLoadErrors:     resp.GetLocalLoadErrors(),
+		Dedups:              resp.GetDedups(),
+		StaleHits:           resp.GetStaleHits(),
+		Refreshes:           resp.GetRefreshes(),
+		LoadLatencyP50:      time.Duration(resp.GetLoadLatencyP50Us()) * time.Microsecond,
+		LoadLatencyP90:      time.Duration(resp.GetLoadLatencyP90Us()) * time.Microsecond,
+		LoadLatencyP99:      time.Duration(resp.GetLoadLatencyP99Us()) * time.Microsecond,
+		Bytes:               resp.GetBytes(),
+		Items:               int(resp.GetItems()),
+		Evictions:           resp.GetEvictions(),
+		Expirations:         resp.GetExpirations(),
+		CompressionInBytes:  resp.GetCompressionInBytes(),
+		CompressionOutBytes: resp.GetCompressionOutBytes(),
+	}
+}
+
+// Run 启动grpc服务，阻塞直到Stop被调用
 func (s *Server) Run() error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	if s.status {
+		s.mu.Unlock()
 		return fmt.Errorf("server is running")
 	}
-	s.status = true
-	s.stopCh = make(chan error)
 
 	port := strings.Split(s.svcAddr, ":")[1]
-	_, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
 	if err != nil {
-		return fmt.Errorf("listen %s error: %v", fmt.Sprintf("%s:%s", s.svcAddr, port), err)
+		s.mu.Unlock()
+		return fmt.Errorf("listen %s error: %v", s.svcAddr, err)
+	}
+
+	var serverOpts []grpc.ServerOption
+	if s.tlsConfig != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(s.tlsConfig)))
+	}
+	interceptors := append([]grpc.UnaryServerInterceptor{tracingServerInterceptor, forwardingServerInterceptor}, s.interceptors...)
+	serverOpts = append(serverOpts, grpc.ChainUnaryInterceptor(interceptors...))
+	s.grpcServer = grpc.NewServer(serverOpts...)
+	pb.RegisterGoCacheServer(s.grpcServer, s)
+	if s.admin {
+		pb.RegisterAdminServer(s.grpcServer, &adminServer{server: s})
+	}
+
+	// 注册健康检查服务，供其他节点探测
+	s.health = health.NewServer()
+	healthpb.RegisterHealthServer(s.grpcServer, s.health)
+	s.health.SetServingStatus(s.svcName, healthpb.HealthCheckResponse_SERVING)
+
+	if s.metricsAddr != "" {
+		if err := s.startMetrics(); err != nil {
+			lis.Close()
+			s.mu.Unlock()
+			return err
+		}
+	}
+	if err := s.startFrontends(); err != nil {
+		lis.Close()
+		s.stopMetrics()
+		s.mu.Unlock()
+		return err
 	}
 
-	server := grpc.NewServer()
-	pb.RegisterLCacheServer(server, s)
+	s.status = true
+	grpcServer := s.grpcServer
+	s.mu.Unlock()
+	// 监听已经建立，此后的连接在Serve开始前由内核排队
+	s.readyOnce.Do(func() { close(s.ready) })
 
+	s.logger.Info("server is running")
+	if err := grpcServer.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("serve %s error: %v", s.svcAddr, err)
+	}
 	return nil
 }
 
+// Ready 返回的channel在Run开始监听后关闭。应在之后再注册到服务发现，避免其它节点连接尚未监听的地址
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// stopMetrics 关闭metrics HTTP服务，调用方需持有s.mu
+func (s *Server) stopMetrics() {
+	if s.metricsServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.metricsServer.Shutdown(ctx); err != nil {
+		s.logger.Warn("shutdown metrics server failed", "error", err)
+	}
+	s.metricsServer = nil
+}
+
+// Stop 优雅关闭服务，先将健康状态置为不可用，让其他节点尽快摘除本节点
 func (s *Server) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.status {
+		return
+	}
+	s.health.Shutdown()
+	s.stopFrontends()
+	s.grpcServer.GracefulStop()
+	// 所有请求结束后写入快照并将AOF落盘，重启后可以直接加载
+	saveSnapshots()
+	syncAOFs()
+	s.stopMetrics()
+	s.status = false
+}
+
+// startFrontends 启动Redis、memcached和HTTP前端，任一失败时关闭已启动的前端，调用方需持有s.mu
+func (s *Server) startFrontends() error {
+	var err error
+	if s.redis != nil {
+		err = s.redis.start(s)
+	}
+	if err == nil && s.memcache != nil {
+		err = s.memcache.start(s)
+	}
+	if err == nil && s.gateway != nil {
+		err = s.gateway.start(s)
+	}
+	if err != nil {
+		s.stopFrontends()
+	}
+	return err
+}
+
+// stopFrontends 关闭协议前端，未启动的前端直接跳过
+func (s *Server) stopFrontends() {
+	if s.redis != nil {
+		s.redis.stop()
+	}
+	if s.memcache != nil {
+		s.memcache.stop()
+	}
+	if s.gateway != nil {
+		s.gateway.stop()
+	}
+}
+
+// startMetrics 启动指标HTTP服务，调用方需持有锁
+func (s *Server) startMetrics() error {
+	lis, err := net.Listen("tcp", s.metricsAddr)
+	if err != nil {
+		return fmt.Errorf("listen metrics %s error: %v", s.metricsAddr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", MetricsHandler())
+	s.metricsServer = &http.Server{Handler: mux}
 
+	go func(srv *http.Server) {
+		if err := srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("metrics server failed", "metrics_addr", s.metricsAddr, "error", err)
+		}
+	}(s.metricsServer)
+	s.logger.Info("metrics server is running", "metrics_addr", s.metricsAddr)
+	return nil
 }
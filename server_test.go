@@ -0,0 +1,111 @@
+package gocache
+
+import (
+	"bytes"
+	"context"
+	pb "gocache/pb"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServer_SetGet(t *testing.T) {
+	NewGroup("server-set", 1<<10, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		return nil, false, time.Time{}
+	}))
+	defer DestroyGroup("server-set")
+	s, err := NewServer("localhost:9999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := s.Set(ctx, &pb.Request{Group: "server-set", Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if resp, err := s.Get(ctx, &pb.Request{Group: "server-set", Key: "k"}); err != nil || string(resp.GetValue()) != "v" {
+		t.Fatalf("expect v, got %v", err)
+	}
+	if _, err := s.Get(ctx, &pb.Request{Group: "server-set", Key: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expect NotFound, got %v", err)
+	}
+	if _, err := s.Set(ctx, &pb.Request{Group: "server-set", Key: "k", Expire: true}); err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	if _, err := s.Get(ctx, &pb.Request{Group: "server-set", Key: "k", CacheOnly: true}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expect expired entry removed, got %v", err)
+	}
+	if _, err := s.Set(ctx, &pb.Request{Group: "no-such-group", Key: "k"}); err == nil {
+		t.Fatal("expect error for unknown group")
+	}
+}
+
+func TestServer_FrontendsUseServerLogger(t *testing.T) {
+	var buf bytes.Buffer
+	s, err := NewServer("localhost:9999",
+		WithServerLogger(slog.New(slog.NewTextHandler(&buf, nil))),
+		WithRedis(RedisConfig{Addr: "127.0.0.1:0", Databases: []string{"logs"}}),
+		WithMemcache(MemcacheConfig{Addr: "127.0.0.1:0", Group: "logs"}),
+		WithHTTP(HTTPConfig{Addr: "127.0.0.1:0"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	err = s.startFrontends()
+	s.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.stopFrontends()
+
+	for _, l := range []*slog.Logger{s.logger, s.redis.logger, s.memcache.logger, s.gateway.logger} {
+		l.Info("frontend ready")
+	}
+	for _, component := range []string{"server", "redis", "memcache", "http"} {
+		if !strings.Contains(buf.String(), "component="+component) {
+			t.Fatalf("expect %s logs written to the server logger, got:\n%s", component, buf.String())
+		}
+	}
+}
+
+func TestServer_Ready(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	s, err := NewServer(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-s.Ready():
+		t.Fatal("expect not ready before Run")
+	default:
+	}
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.Run() }()
+	t.Cleanup(s.Stop)
+
+	// Ready之后可以立即连接
+	select {
+	case <-s.Ready():
+	case err := <-errCh:
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for ready")
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("expect listener accepting after ready: %v", err)
+	}
+	conn.Close()
+}
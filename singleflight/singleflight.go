@@ -5,9 +5,17 @@ import (
 )
 
 type call struct {
-	wg  sync.WaitGroup
-	val interface{}
-	err error
+	wg    sync.WaitGroup
+	val   interface{}
+	err   error
+	chans []chan<- Result // DoChan的等待者
+}
+
+// Result DoChan返回的结果
+type Result struct {
+	Val    interface{}
+	Err    error
+	Shared bool
 }
 
 type Group struct {
@@ -15,7 +23,8 @@ type Group struct {
 	m  map[string]*call
 }
 
-func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+// Do 执行fn，相同key的并发请求只执行一次，shared表示结果是否来自其他请求
+func (g *Group) Do(key string, fn func() (interface{}, error)) (v interface{}, err error, shared bool) {
 	g.mu.Lock()
 	if g.m == nil {
 		g.m = make(map[string]*call)
@@ -25,22 +34,73 @@ func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, err
 		// 如果有，等待之前key的结果就行
 		g.mu.Unlock()
 		c.wg.Wait()
-		return c.val, c.err
+		return c.val, c.err, true
 	}
-	
+
 	// 注册一个请求
 	c := new(call)
 	c.wg.Add(1)
 	g.m[key] = c
 	g.mu.Unlock()
 
+	g.doCall(c, key, fn)
+	return c.val, c.err, false
+}
+
+// DoChan 与Do相同，但不阻塞，结果在fn完成后发送到返回的channel。
+// 调用方不再等待时可以直接返回，fn会继续执行，结果仍提供给其它等待者
+func (g *Group) DoChan(key string, fn func() (interface{}, error)) <-chan Result {
+	ch := make(chan Result, 1)
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		c.chans = append(c.chans, ch)
+		g.mu.Unlock()
+		return ch
+	}
+	c := new(call)
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	go func() {
+		g.doCall(c, key, fn)
+		ch <- Result{Val: c.val, Err: c.err}
+	}()
+	return ch
+}
+
+// doCall 执行fn，唤醒所有等待者并清除key
+func (g *Group) doCall(c *call, key string, fn func() (interface{}, error)) {
 	c.val, c.err = fn()
 	c.wg.Done()
 
 	// 清除
 	g.mu.Lock()
 	delete(g.m, key)
+	for _, ch := range c.chans {
+		ch <- Result{Val: c.val, Err: c.err, Shared: true}
+	}
+	g.mu.Unlock()
+}
+
+// Go 在后台执行fn，相同key已有请求在处理时不再执行，返回是否启动了新的请求
+func (g *Group) Go(key string, fn func() (interface{}, error)) bool {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if _, ok := g.m[key]; ok {
+		g.mu.Unlock()
+		return false
+	}
+	c := new(call)
+	c.wg.Add(1)
+	g.m[key] = c
 	g.mu.Unlock()
 
-	return c.val, c.err
-}
\ No newline at end of file
+	go g.doCall(c, key, fn)
+	return true
+}
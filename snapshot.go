@@ -0,0 +1,280 @@
+package gocache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotMagic 快照文件头，最后一个字节为格式版本。版本2在每个条目前增加标记字节，仍可读取版本1
+var snapshotMagic = []byte("GCSNAP\x02")
+
+// snapshotSealed 条目标记：value为加密后的密文
+const snapshotSealed byte = 1
+
+// SnapshotConfig Group的快照配置
+type SnapshotConfig struct {
+	// Path 快照文件路径，目录不存在时自动创建
+	Path string
+	// Interval 定期写入快照的间隔，为0时只在Server.Stop和调用SaveSnapshot时写入
+	Interval time.Duration
+}
+
+// WithSnapshot 开启快照持久化。NewGroup时如果快照文件存在则加载，跳过已过期的条目，
+// 超出容量时只保留最近使用的条目
+func WithSnapshot(cfg SnapshotConfig) GroupOption {
+	return func(g *Group) {
+		g.snapshot = &snapshotter{cfg: cfg, stop: make(chan struct{})}
+	}
+}
+
+// snapshotter Group的快照状态
+type snapshotter struct {
+	cfg  SnapshotConfig
+	stop chan struct{}
+}
+
+// snapshotEntry 快照中的一个条目
+type snapshotEntry struct {
+	key   string
+	value ByteView
+}
+
+// SaveSnapshot 将本节点上该Group的缓存按LRU顺序写入快照文件，未开启快照时返回错误
+func (g *Group) SaveSnapshot() error {
+	if g.snapshot == nil {
+		return fmt.Errorf("snapshot is not enabled for group %s", g.name)
+	}
+	g.snapshotMu.Lock()
+	defer g.snapshotMu.Unlock()
+
+	start := time.Now()
+	entries, err := g.mainCache.entries()
+	if err != nil {
+		return err
+	}
+	if err := writeSnapshot(g.snapshot.cfg.Path, entries); err != nil {
+		return err
+	}
+	g.logger.Info("snapshot saved", "path", g.snapshot.cfg.Path, "items", len(entries), "elapsed", time.Since(start))
+	return nil
+}
+
+// LoadSnapshot 从快照文件加载条目，返回加载的条目数。已存在的key保留当前值
+func (g *Group) LoadSnapshot() (int, error) {
+	if g.snapshot == nil {
+		return 0, fmt.Errorf("snapshot is not enabled for group %s", g.name)
+	}
+	entries, err := readSnapshot(g.snapshot.cfg.Path)
+	if err != nil {
+		return 0, err
+	}
+	n := g.mainCache.restore(entries)
+	g.logger.Info("snapshot loaded", "path", g.snapshot.cfg.Path, "items", n, "skipped", len(entries)-n)
+	return n, nil
+}
+
+// startSnapshot 加载已有的快照并启动定期写入，由NewGroup调用
+func (g *Group) startSnapshot() {
+	if _, err := g.LoadSnapshot(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		g.logger.Warn("load snapshot failed", "path", g.snapshot.cfg.Path, "error", err)
+	}
+	if g.snapshot.cfg.Interval <= 0 {
+		return
+	}
+	go func(s *snapshotter) {
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := g.SaveSnapshot(); err != nil {
+					g.logger.Warn("save snapshot failed", "path", s.cfg.Path, "error", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}(g.snapshot)
+}
+
+// saveSnapshots 为所有开启了快照的Group写入快照，由Server.Stop调用
+func saveSnapshots() {
+	for _, name := range ListGroups() {
+		g := GetGroup(name)
+		if g == nil || g.snapshot == nil {
+			continue
+		}
+		if err := g.SaveSnapshot(); err != nil {
+			g.logger.Warn("save snapshot failed", "path", g.snapshot.cfg.Path, "error", err)
+		}
+	}
+}
+
+// writeSnapshot 先写入临时文件再重命名，保证快照文件总是完整的
+func writeSnapshot(path string, entries []snapshotEntry) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create snapshot dir: %v", err)
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create snapshot: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	err = encodeSnapshot(f, entries)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write snapshot: %v", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("rename snapshot: %v", err)
+	}
+	return nil
+}
+
+func readSnapshot(path string) ([]snapshotEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := decodeSnapshot(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode snapshot %s: %v", path, err)
+	}
+	return entries, nil
+}
+
+// encodeSnapshot 快照格式：文件头、条目数、按LRU顺序排列的标记字节和条目，最后是前面所有内容的CRC32
+func encodeSnapshot(w io.Writer, entries []snapshotEntry) error {
+	h := crc32.NewIEEE()
+	bw := bufio.NewWriter(io.MultiWriter(w, h))
+	bw.Write(snapshotMagic)
+	bw.Write(binary.AppendUvarint(nil, uint64(len(entries))))
+	var buf []byte
+	for _, e := range entries {
+		var flags byte
+		if e.value.x {
+			flags |= snapshotSealed
+		}
+		buf = appendEntry(append(buf[:0], flags), e)
+		bw.Write(buf)
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	_, err := w.Write(binary.BigEndian.AppendUint32(nil, h.Sum32()))
+	return err
+}
+
+func decodeSnapshot(data []byte) ([]snapshotEntry, error) {
+	n := len(snapshotMagic)
+	if len(data) < n+4 || !bytes.Equal(data[:n-1], snapshotMagic[:n-1]) || data[n-1] == 0 || data[n-1] > snapshotMagic[n-1] {
+		return nil, fmt.Errorf("invalid snapshot header")
+	}
+	version := data[n-1]
+	body, sum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, fmt.Errorf("checksum mismatch")
+	}
+
+	r := bytes.NewReader(body[len(snapshotMagic):])
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if count > uint64(r.Len()) {
+		return nil, fmt.Errorf("invalid entry count %d", count)
+	}
+	entries := make([]snapshotEntry, 0, count)
+	for i := uint64(0); i < count; i++ {
+		var flags byte
+		if version >= 2 {
+			if flags, err = r.ReadByte(); err != nil {
+				return nil, err
+			}
+		}
+		e, err := readEntry(r)
+		if err != nil {
+			return nil, err
+		}
+		e.value.x = flags&snapshotSealed != 0
+		entries = append(entries, e)
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("unexpected trailing data")
+	}
+	return entries, nil
+}
+
+// appendEntry 编码一个条目，依次为key、value、过期时间(UnixNano，0表示不过期)、flags和Content-Type
+func appendEntry(b []byte, e snapshotEntry) []byte {
+	var expire int64
+	if !e.value.e.IsZero() {
+		expire = e.value.e.UnixNano()
+	}
+	b = appendBytes(b, []byte(e.key))
+	b = appendBytes(b, e.value.b)
+	b = binary.AppendVarint(b, expire)
+	b = binary.AppendUvarint(b, uint64(e.value.f))
+	return appendBytes(b, []byte(e.value.t))
+}
+
+func readEntry(r *bytes.Reader) (snapshotEntry, error) {
+	key, err := readBytes(r)
+	if err != nil {
+		return snapshotEntry{}, err
+	}
+	value, err := readBytes(r)
+	if err != nil {
+		return snapshotEntry{}, err
+	}
+	expire, err := binary.ReadVarint(r)
+	if err != nil {
+		return snapshotEntry{}, err
+	}
+	flags, err := binary.ReadUvarint(r)
+	if err != nil {
+		return snapshotEntry{}, err
+	}
+	contentType, err := readBytes(r)
+	if err != nil {
+		return snapshotEntry{}, err
+	}
+	view := ByteView{b: value, f: uint32(flags), t: string(contentType)}
+	if expire != 0 {
+		view.e = time.Unix(0, expire)
+	}
+	return snapshotEntry{key: string(key), value: view}, nil
+}
+
+func appendBytes(b, data []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(r, b)
+	return b, err
+}
@@ -0,0 +1,96 @@
+package gocache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshot_WarmRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snap", "scores.snap")
+	ctx := context.Background()
+	g := NewGroup("snapshot-restart", 1<<20, missGetter(), WithSnapshot(SnapshotConfig{Path: path}))
+	t.Cleanup(func() { DestroyGroup("snapshot-restart") })
+
+	g.Write(ctx, "tom", []byte("630"), WriteOptions{Flags: 7, ContentType: "text/plain"})
+	g.Write(ctx, "jack", []byte("589"), WriteOptions{TTL: time.Hour})
+	g.Write(ctx, "gone", []byte("x"), WriteOptions{TTL: 50 * time.Millisecond})
+	if err := g.SaveSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	DestroyGroup("snapshot-restart")
+
+	g = NewGroup("snapshot-restart", 1<<20, missGetter(), WithSnapshot(SnapshotConfig{Path: path}))
+	if items := g.Stats().Items; items != 2 {
+		t.Fatalf("expect 2 items restored, got %d", items)
+	}
+	v, err := g.Get(ctx, "tom")
+	if err != nil || v.String() != "630" || v.Flags() != 7 || v.ContentType() != "text/plain" || !v.Expire().IsZero() {
+		t.Fatalf("unexpected restored entry %+v %v", v, err)
+	}
+	if v, err := g.Get(ctx, "jack"); err != nil || v.TTL() <= 59*time.Minute {
+		t.Fatalf("expect expiry kept, got ttl %v %v", v.TTL(), err)
+	}
+	if _, err := g.Get(ctx, "gone"); err != ErrNotFound {
+		t.Fatalf("expect expired entry skipped, got %v", err)
+	}
+}
+
+func TestSnapshot_MaxBytes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lru.snap")
+	g := NewGroup("snapshot-lru", 1<<20, missGetter(), WithSnapshot(SnapshotConfig{Path: path}))
+	t.Cleanup(func() { DestroyGroup("snapshot-lru") })
+	ctx := context.Background()
+	for _, key := range []string{"k1", "k2", "k3", "k4"} {
+		g.Set(ctx, key, []byte("vv"))
+	}
+	g.Get(ctx, "k1") // k1成为最近使用的条目
+	if err := g.SaveSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 容量只够保留最近使用的两个条目
+	small := NewGroup("snapshot-lru-small", 8, missGetter(), WithSnapshot(SnapshotConfig{Path: path}))
+	t.Cleanup(func() { DestroyGroup("snapshot-lru-small") })
+	stats := small.Stats()
+	if stats.Items != 2 || stats.Evictions != 0 {
+		t.Fatalf("expect 2 items without eviction, got %+v", stats)
+	}
+	for _, key := range []string{"k4", "k1"} {
+		if _, ok := small.mainCache.get(key); !ok {
+			t.Fatalf("expect %s restored", key)
+		}
+	}
+}
+
+func TestSnapshot_Corrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.snap")
+	entries := []snapshotEntry{{key: "k", value: ByteView{b: []byte("v")}}}
+	if err := writeSnapshot(path, entries); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	data[len(data)-5] ^= 0xff
+	os.WriteFile(path, data, 0o644)
+
+	if _, err := readSnapshot(path); err == nil {
+		t.Fatal("expect checksum error")
+	}
+	g := NewGroup("snapshot-bad", 1<<20, missGetter(), WithSnapshot(SnapshotConfig{Path: path}))
+	t.Cleanup(func() { DestroyGroup("snapshot-bad") })
+	if items := g.Stats().Items; items != 0 {
+		t.Fatalf("expect empty cache for corrupted snapshot, got %d", items)
+	}
+	if err := (&Group{name: "plain"}).SaveSnapshot(); err == nil {
+		t.Fatal("expect error without snapshot config")
+	}
+}
+
+func missGetter() Getter {
+	return GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		return nil, false, time.Time{}
+	})
+}
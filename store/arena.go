@@ -0,0 +1,450 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	maxArenaShards     = 64
+	minArenaShardBytes = 1 << 20
+	// arenaHeaderSize 条目头：key哈希、过期时间(UnixNano)、key长度、value长度
+	arenaHeaderSize = 22
+	maxArenaKeyLen  = 1<<16 - 1
+)
+
+// arenaStore 将条目编码后保存在预分配的字节数组中，按key的哈希分片，每个分片是一个环形缓冲区。
+// 堆上只有整数索引，缓存再大也不会增加GC扫描的负担。分片写满后从最旧的条目开始覆盖，
+// 位于最旧四分之一区域的条目被命中时重新写到队尾，近似LRU。Options.OnEvicted不会被调用
+type arenaStore struct {
+	shards          []*arenaShard
+	codec           Codec
+	onExpired       func(key string, value Value)
+	cleanupInterval time.Duration
+	stop            chan struct{}
+	closeOnce       sync.Once
+}
+
+// arenaShard 一个环形缓冲区。位置是只增不减的绝对偏移，对容量取模后得到在buf中的下标
+type arenaShard struct {
+	mu          sync.Mutex
+	buf         []byte
+	index       map[uint64]uint64 // key哈希 -> 条目位置，不含指针，GC不会扫描
+	head        uint64            // 最旧条目的位置
+	tail        uint64            // 下一个条目的写入位置
+	entries     int
+	bytes       int64 // 存活条目的key和value字节数
+	evictions   uint64
+	expirations uint64
+}
+
+// arenaHeader 解析后的条目头
+type arenaHeader struct {
+	hash    uint64
+	expire  int64
+	keyLen  int
+	dataLen int
+}
+
+func (h arenaHeader) size() uint64 {
+	return uint64(arenaHeaderSize + h.keyLen + h.dataLen)
+}
+
+func (h arenaHeader) expired(now int64) bool {
+	return h.expire != 0 && now > h.expire
+}
+
+// NewArenaStore 创建Arena存储，opts.MaxBytes为所有分片的总容量，在创建时一次性分配。
+// 单个条目编码后不能超过一个分片的容量
+func NewArenaStore(opts Options) (*arenaStore, error) {
+	if opts.MaxBytes <= 0 {
+		return nil, fmt.Errorf("arena max bytes must be positive")
+	}
+	if opts.Codec == nil {
+		return nil, fmt.Errorf("arena codec is required")
+	}
+	n := maxArenaShards
+	for n > 1 && opts.MaxBytes/int64(n) < minArenaShardBytes {
+		n /= 2
+	}
+	s := &arenaStore{
+		shards:          make([]*arenaShard, n),
+		codec:           opts.Codec,
+		onExpired:       opts.OnExpired,
+		cleanupInterval: opts.CleanupInterval,
+		stop:            make(chan struct{}),
+	}
+	for i := range s.shards {
+		s.shards[i] = &arenaShard{
+			buf:   make([]byte, opts.MaxBytes/int64(n)),
+			index: make(map[uint64]uint64),
+		}
+	}
+	if s.cleanupInterval <= 0 {
+		s.cleanupInterval = time.Minute
+	}
+
+	go s.cleanupLoop()
+	return s, nil
+}
+
+// hashKey FNV-1a哈希，不分配内存
+func hashKey(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+func (s *arenaStore) shard(hash uint64) *arenaShard {
+	return s.shards[hash&uint64(len(s.shards)-1)]
+}
+
+// Get 实现Store接口
+func (s *arenaStore) Get(key string) (Value, bool) {
+	hash := hashKey(key)
+	sh := s.shard(hash)
+	sh.mu.Lock()
+	pos, h, ok := sh.lookup(hash, key)
+	if !ok {
+		sh.mu.Unlock()
+		return nil, false
+	}
+	data := sh.read(pos+arenaHeaderSize+uint64(h.keyLen), h.dataLen)
+	if h.expired(time.Now().UnixNano()) {
+		sh.remove(hash, h)
+		sh.expirations++
+		sh.mu.Unlock()
+		s.expire(key, data)
+		return nil, false
+	}
+	// 即将被覆盖的条目重新写到队尾
+	if pos-sh.head < uint64(len(sh.buf))/4 {
+		entry := sh.read(pos, int(h.size()))
+		sh.remove(hash, h)
+		sh.insert(hash, h, entry)
+	}
+	sh.mu.Unlock()
+
+	value, err := s.codec.Decode(data)
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// Set 实现Store接口
+func (s *arenaStore) Set(key string, value Value) error {
+	return s.SetWithExpiration(key, value, 0)
+}
+
+// SetWithExpiration 实现Store接口，条目超过分片容量时删除旧值并返回错误
+func (s *arenaStore) SetWithExpiration(key string, value Value, expiration time.Duration) error {
+	if len(key) > maxArenaKeyLen {
+		return fmt.Errorf("key too long: %d bytes", len(key))
+	}
+	data, err := s.codec.Encode(value)
+	if err != nil {
+		return fmt.Errorf("encode value: %v", err)
+	}
+	h := arenaHeader{hash: hashKey(key), keyLen: len(key), dataLen: len(data)}
+	if expiration > 0 {
+		h.expire = time.Now().Add(expiration).UnixNano()
+	}
+	entry := make([]byte, arenaHeaderSize, h.size())
+	binary.BigEndian.PutUint64(entry, h.hash)
+	binary.BigEndian.PutUint64(entry[8:], uint64(h.expire))
+	binary.BigEndian.PutUint16(entry[16:], uint16(h.keyLen))
+	binary.BigEndian.PutUint32(entry[18:], uint32(h.dataLen))
+	entry = append(append(entry, key...), data...)
+
+	sh := s.shard(h.hash)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if len(sh.buf) == 0 {
+		return fmt.Errorf("arena store is closed")
+	}
+	if h.size() > uint64(len(sh.buf)) {
+		return fmt.Errorf("entry too large: %d bytes, shard size %d", h.size(), len(sh.buf))
+	}
+	// 哈希冲突时旧key被覆盖，写入失败时保留旧key
+	if pos, ok := sh.index[h.hash]; ok {
+		sh.remove(h.hash, sh.header(pos))
+	}
+	sh.insert(h.hash, h, entry)
+	return nil
+}
+
+// Delete 实现Store接口
+func (s *arenaStore) Delete(key string) bool {
+	hash := hashKey(key)
+	sh := s.shard(hash)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, h, ok := sh.lookup(hash, key); ok {
+		sh.remove(hash, h)
+		return true
+	}
+	return false
+}
+
+// Clear 实现Store接口，保留已分配的字节数组
+func (s *arenaStore) Clear() {
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		sh.index = make(map[uint64]uint64)
+		sh.head, sh.tail = 0, 0
+		sh.entries, sh.bytes = 0, 0
+		sh.mu.Unlock()
+	}
+}
+
+// Len 实现Store接口
+func (s *arenaStore) Len() int {
+	n := 0
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		n += sh.entries
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+// SetMaxBytes 实现Resizer接口，重新分配每个分片并按写入顺序搬移条目，缩容时丢弃最旧的条目。
+// 分片数在创建时确定，不随容量变化
+func (s *arenaStore) SetMaxBytes(maxBytes int64) {
+	if maxBytes <= 0 {
+		return
+	}
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		sh.resize(maxBytes / int64(len(s.shards)))
+		sh.mu.Unlock()
+	}
+}
+
+// Range 实现Ranger接口，逐个分片按写入顺序遍历，不同分片之间没有先后顺序
+func (s *arenaStore) Range(fn func(key string, value Value, expire time.Time) bool) {
+	now := time.Now().UnixNano()
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		ok := sh.each(func(pos uint64, h arenaHeader) bool {
+			if h.expired(now) {
+				return true
+			}
+			key := sh.read(pos+arenaHeaderSize, h.keyLen)
+			value, err := s.codec.Decode(sh.read(pos+arenaHeaderSize+uint64(h.keyLen), h.dataLen))
+			if err != nil {
+				return true
+			}
+			var expire time.Time
+			if h.expire != 0 {
+				expire = time.Unix(0, h.expire)
+			}
+			return fn(string(key), value, expire)
+		})
+		sh.mu.Unlock()
+		if !ok {
+			return
+		}
+	}
+}
+
+// Stats 实现Store接口
+func (s *arenaStore) Stats() Stats {
+	var stats Stats
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		stats.Bytes += sh.bytes
+		stats.Entries += sh.entries
+		stats.Evictions += sh.evictions
+		stats.Expirations += sh.expirations
+		sh.mu.Unlock()
+	}
+	return stats
+}
+
+// Close 停止后台清理并释放字节数组，之后的写入都会失败
+func (s *arenaStore) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		for _, sh := range s.shards {
+			sh.mu.Lock()
+			sh.buf = nil
+			sh.index = make(map[uint64]uint64)
+			sh.head, sh.tail = 0, 0
+			sh.entries, sh.bytes = 0, 0
+			sh.mu.Unlock()
+		}
+	})
+	return nil
+}
+
+// expire 通知条目过期，在分片的锁外调用
+func (s *arenaStore) expire(key string, data []byte) {
+	if s.onExpired == nil {
+		return
+	}
+	if value, err := s.codec.Decode(data); err == nil {
+		s.onExpired(key, value)
+	}
+}
+
+// cleanupLoop 定期清理过期条目，释放的空间要等环形缓冲区写到该位置时才会复用
+func (s *arenaStore) cleanupLoop() {
+	ticker := time.NewTicker(s.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		for _, sh := range s.shards {
+			type expiredEntry struct {
+				key  string
+				data []byte
+			}
+			var expired []expiredEntry
+			now := time.Now().UnixNano()
+			sh.mu.Lock()
+			sh.each(func(pos uint64, h arenaHeader) bool {
+				if h.expired(now) {
+					key := sh.read(pos+arenaHeaderSize, h.keyLen)
+					data := sh.read(pos+arenaHeaderSize+uint64(h.keyLen), h.dataLen)
+					expired = append(expired, expiredEntry{string(key), data})
+					sh.remove(h.hash, h)
+					sh.expirations++
+				}
+				return true
+			})
+			sh.mu.Unlock()
+			for _, e := range expired {
+				s.expire(e.key, e.data)
+			}
+		}
+	}
+}
+
+// lookup 查找key对应的条目，哈希相同但key不同时视为未命中，调用方需持有mu
+func (sh *arenaShard) lookup(hash uint64, key string) (uint64, arenaHeader, bool) {
+	pos, ok := sh.index[hash]
+	if !ok {
+		return 0, arenaHeader{}, false
+	}
+	h := sh.header(pos)
+	if h.keyLen != len(key) || !sh.equal(pos+arenaHeaderSize, key) {
+		return 0, arenaHeader{}, false
+	}
+	return pos, h, true
+}
+
+// insert 把完整的条目写到队尾，空间不足时覆盖最旧的条目，调用方需持有mu
+func (sh *arenaShard) insert(hash uint64, h arenaHeader, entry []byte) {
+	size := uint64(len(entry))
+	for sh.tail+size-sh.head > uint64(len(sh.buf)) {
+		sh.evictHead()
+	}
+	sh.write(sh.tail, entry)
+	sh.index[hash] = sh.tail
+	sh.tail += size
+	sh.entries++
+	sh.bytes += int64(h.keyLen + h.dataLen)
+}
+
+// remove 从索引中删除条目，占用的空间随环形缓冲区前进被覆盖，调用方需持有mu
+func (sh *arenaShard) remove(hash uint64, h arenaHeader) {
+	delete(sh.index, hash)
+	sh.entries--
+	sh.bytes -= int64(h.keyLen + h.dataLen)
+}
+
+// evictHead 丢弃最旧的条目，已删除或被覆盖的条目只移动head，调用方需持有mu
+func (sh *arenaShard) evictHead() {
+	h := sh.header(sh.head)
+	if pos, ok := sh.index[h.hash]; ok && pos == sh.head {
+		sh.remove(h.hash, h)
+		if h.expired(time.Now().UnixNano()) {
+			sh.expirations++
+		} else {
+			sh.evictions++
+		}
+	}
+	sh.head += h.size()
+}
+
+// each 按写入顺序遍历索引中的条目，fn返回false时停止，fn中可以调用remove，调用方需持有mu
+func (sh *arenaShard) each(fn func(pos uint64, h arenaHeader) bool) bool {
+	for pos := sh.head; pos < sh.tail; {
+		h := sh.header(pos)
+		if idx, ok := sh.index[h.hash]; ok && idx == pos {
+			if !fn(pos, h) {
+				return false
+			}
+		}
+		pos += h.size()
+	}
+	return true
+}
+
+// resize 换成新容量的字节数组，按写入顺序重新写入存活的条目，调用方需持有mu
+func (sh *arenaShard) resize(capacity int64) {
+	old := &arenaShard{buf: sh.buf, index: sh.index, head: sh.head, tail: sh.tail}
+	sh.buf = make([]byte, capacity)
+	sh.index = make(map[uint64]uint64, len(old.index))
+	sh.head, sh.tail = 0, 0
+	sh.entries, sh.bytes = 0, 0
+	old.each(func(pos uint64, h arenaHeader) bool {
+		if h.size() > uint64(capacity) {
+			sh.evictions++
+			return true
+		}
+		sh.insert(h.hash, h, old.read(pos, int(h.size())))
+		return true
+	})
+}
+
+// header 解析pos处的条目头，调用方需持有mu
+func (sh *arenaShard) header(pos uint64) arenaHeader {
+	var b [arenaHeaderSize]byte
+	sh.readAt(pos, b[:])
+	return arenaHeader{
+		hash:    binary.BigEndian.Uint64(b[:]),
+		expire:  int64(binary.BigEndian.Uint64(b[8:])),
+		keyLen:  int(binary.BigEndian.Uint16(b[16:])),
+		dataLen: int(binary.BigEndian.Uint32(b[18:])),
+	}
+}
+
+// read 复制pos开始的n个字节
+func (sh *arenaShard) read(pos uint64, n int) []byte {
+	b := make([]byte, n)
+	sh.readAt(pos, b)
+	return b
+}
+
+// readAt 将pos开始的len(b)个字节复制到b，处理环形缓冲区的回绕
+func (sh *arenaShard) readAt(pos uint64, b []byte) {
+	off := int(pos % uint64(len(sh.buf)))
+	n := copy(b, sh.buf[off:])
+	copy(b[n:], sh.buf)
+}
+
+// equal 比较pos处保存的key，不分配内存
+func (sh *arenaShard) equal(pos uint64, key string) bool {
+	off := int(pos % uint64(len(sh.buf)))
+	n := min(len(key), len(sh.buf)-off)
+	return string(sh.buf[off:off+n]) == key[:n] && string(sh.buf[:len(key)-n]) == key[n:]
+}
+
+// write 将b写到pos，处理环形缓冲区的回绕
+func (sh *arenaShard) write(pos uint64, b []byte) {
+	off := int(pos % uint64(len(sh.buf)))
+	n := copy(sh.buf[off:], b)
+	copy(sh.buf, b[n:])
+}
@@ -0,0 +1,204 @@
+package store
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newArena(t testing.TB, maxBytes int64) *arenaStore {
+	t.Helper()
+	s, err := NewArenaStore(Options{MaxBytes: maxBytes, Codec: stringCodec{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestArena_SetGet(t *testing.T) {
+	var expired []string
+	s := newArena(t, 1<<10)
+	s.onExpired = func(key string, value Value) { expired = append(expired, key+"="+string(value.(String))) }
+
+	s.Set("k1", String("v1"))
+	s.Set("k1", String("v1-new"))
+	s.SetWithExpiration("k2", String("v2"), 20*time.Millisecond)
+	if v, ok := s.Get("k1"); !ok || v.(String) != "v1-new" {
+		t.Fatalf("expect updated k1, got %v %v", v, ok)
+	}
+	if _, ok := s.Get("missing"); ok {
+		t.Fatal("expect miss")
+	}
+	if !s.Delete("k1") || s.Delete("k1") {
+		t.Fatal("unexpected delete result")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := s.Get("k2"); ok {
+		t.Fatal("expect expired entry to miss")
+	}
+	if len(expired) != 1 || expired[0] != "k2=v2" {
+		t.Fatalf("unexpected expired callbacks %v", expired)
+	}
+	if stats := s.Stats(); stats.Entries != 0 || stats.Bytes != 0 || stats.Expirations != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestArena_Eviction(t *testing.T) {
+	// 单个条目占22+2+30=54字节，200字节的分片只能放下三个
+	value := String(strings.Repeat("x", 30))
+	s := newArena(t, 200)
+	s.Set("k1", value)
+	s.Set("k2", value)
+	s.Set("k3", value)
+	s.Get("k1") // k1位于最旧的区域，命中后重新写到队尾
+	s.Set("k4", value)
+
+	if _, ok := s.Get("k2"); ok {
+		t.Fatal("expect oldest k2 evicted")
+	}
+	for _, key := range []string{"k1", "k3", "k4"} {
+		if _, ok := s.Get(key); !ok {
+			t.Fatalf("expect %s kept", key)
+		}
+	}
+	if stats := s.Stats(); stats.Entries != 3 || stats.Evictions != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	if err := s.Set("k1", String(strings.Repeat("x", 200))); err == nil {
+		t.Fatal("expect error for entry larger than shard")
+	}
+	// 写入失败时保留已有的值
+	if v, ok := s.Get("k1"); !ok || v.(String) != value {
+		t.Fatalf("expect old value kept when new value is too large, got %v %v", v, ok)
+	}
+	s.Close()
+	if err := s.Set("k1", value); err == nil {
+		t.Fatal("expect error after close")
+	}
+}
+
+func TestArena_Wraparound(t *testing.T) {
+	s := newArena(t, 1000)
+	for i := 0; i < 500; i++ {
+		s.Set(fmt.Sprintf("key%d", i%50), String(strings.Repeat("v", i%37)))
+	}
+	hits := 0
+	for i := 450; i < 500; i++ {
+		v, ok := s.Get(fmt.Sprintf("key%d", i%50))
+		if !ok {
+			continue
+		}
+		if v.(String) != String(strings.Repeat("v", i%37)) {
+			t.Fatalf("key%d: unexpected value %q", i%50, v)
+		}
+		hits++
+	}
+	if hits == 0 || hits != s.Len() {
+		t.Fatalf("expect every indexed entry readable, got %d hits and %d entries", hits, s.Len())
+	}
+}
+
+func TestArena_SetMaxBytes(t *testing.T) {
+	value := String(strings.Repeat("x", 30))
+	s := newArena(t, 1000)
+	for i := 0; i < 10; i++ {
+		s.SetWithExpiration(fmt.Sprintf("k%d", i), value, time.Hour)
+	}
+
+	s.SetMaxBytes(200)
+	var keys []string
+	s.Range(func(key string, v Value, expire time.Time) bool {
+		if v.(String) != value || expire.IsZero() {
+			t.Fatalf("unexpected entry %s %v %v", key, v, expire)
+		}
+		keys = append(keys, key)
+		return true
+	})
+	if strings.Join(keys, ",") != "k7,k8,k9" {
+		t.Fatalf("expect newest entries kept in write order, got %v", keys)
+	}
+
+	s.SetMaxBytes(1000)
+	s.Set("k10", value)
+	if s.Len() != 4 {
+		t.Fatalf("expect 4 entries after growing, got %d", s.Len())
+	}
+	s.Clear()
+	if s.Len() != 0 {
+		t.Fatal("expect empty store after clear")
+	}
+}
+
+func TestNewArenaStore_Invalid(t *testing.T) {
+	if _, err := NewArenaStore(Options{Codec: stringCodec{}}); err == nil {
+		t.Fatal("expect error without max bytes")
+	}
+	if _, err := NewArenaStore(Options{MaxBytes: 1 << 20}); err == nil {
+		t.Fatal("expect error without codec")
+	}
+	s := newArena(t, 256<<20)
+	if len(s.shards) != maxArenaShards {
+		t.Fatalf("expect %d shards, got %d", maxArenaShards, len(s.shards))
+	}
+}
+
+const benchEntries = 1 << 20
+
+// fillStore 写入benchEntries个条目，结束时清空，避免LRU的清理协程让条目留在堆上影响后续基准
+func fillStore(b *testing.B, s Store) {
+	b.Cleanup(s.Clear)
+	value := String(strings.Repeat("x", 64))
+	for i := 0; i < benchEntries; i++ {
+		s.Set(fmt.Sprintf("key-%d", i), value)
+	}
+	runtime.GC()
+	b.ResetTimer()
+}
+
+// benchmarkGC 每次迭代执行一次完整的GC，ns/op为标记整个堆所需的时间，pause-ns/op为STW停顿时间
+func benchmarkGC(b *testing.B, s Store) {
+	fillStore(b, s)
+	var before, after debug.GCStats
+	debug.ReadGCStats(&before)
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+	}
+	b.StopTimer()
+	debug.ReadGCStats(&after)
+	b.ReportMetric(float64(after.PauseTotal-before.PauseTotal)/float64(b.N), "pause-ns/op")
+	runtime.KeepAlive(s)
+}
+
+func BenchmarkGC_LRU(b *testing.B) {
+	benchmarkGC(b, NewLRUCache(Options{}))
+}
+
+func BenchmarkGC_Arena(b *testing.B) {
+	benchmarkGC(b, newArena(b, 256<<20))
+}
+
+func benchmarkGet(b *testing.B, s Store) {
+	fillStore(b, s)
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			s.Get(fmt.Sprintf("key-%d", i%benchEntries))
+			i++
+		}
+	})
+}
+
+func BenchmarkGet_LRU(b *testing.B) {
+	benchmarkGet(b, NewLRUCache(Options{}))
+}
+
+func BenchmarkGet_Arena(b *testing.B) {
+	benchmarkGet(b, newArena(b, 256<<20))
+}
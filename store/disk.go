@@ -0,0 +1,334 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSegmentBytes = 64 << 20
+	// diskHeaderSize 记录头：CRC32、key长度、value长度、过期时间(UnixNano)
+	diskHeaderSize = 20
+	// compactRatio 封存的段中存活数据低于该比例时，把存活的记录搬到当前段后删除该段
+	compactRatio = 0.5
+)
+
+// diskStatus 磁盘层查找的结果
+type diskStatus int
+
+const (
+	diskMissing diskStatus = iota
+	diskFound
+	diskExpired
+)
+
+// diskLoc 条目在磁盘层中的位置
+type diskLoc struct {
+	seg    *segment
+	off    int64
+	size   int64 // 整条记录的字节数
+	expire time.Time
+}
+
+// segment 只追加写入的日志段文件
+type segment struct {
+	id   int
+	f    *os.File
+	size int64 // 文件大小
+	live int64 // 仍被索引引用的字节数
+	keys map[string]struct{}
+}
+
+// diskTier 日志结构的磁盘层，索引全部在内存中。磁盘层只作为内存的扩展，打开时清空已有的段
+type diskTier struct {
+	mu          sync.Mutex
+	opts        DiskOptions
+	index       map[string]*diskLoc
+	segments    []*segment // 按创建顺序排列，最后一个为当前写入的段
+	nextID      int
+	bytes       int64 // 所有段文件的总大小
+	live        int64
+	evictions   uint64
+	expirations uint64
+}
+
+func openDiskTier(opts DiskOptions) (*diskTier, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("disk dir is required")
+	}
+	if opts.Codec == nil {
+		return nil, fmt.Errorf("disk codec is required")
+	}
+	if opts.SegmentBytes <= 0 {
+		opts.SegmentBytes = defaultSegmentBytes
+	}
+	// 至少保留两个段，丢弃最旧的段时不会清空整个磁盘层
+	if opts.MaxBytes > 0 && opts.SegmentBytes > opts.MaxBytes/2 {
+		opts.SegmentBytes = max(opts.MaxBytes/2, 1)
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create disk dir: %v", err)
+	}
+	old, _ := filepath.Glob(filepath.Join(opts.Dir, "*.seg"))
+	for _, path := range old {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove old segment: %v", err)
+		}
+	}
+
+	d := &diskTier{opts: opts, index: make(map[string]*diskLoc)}
+	if err := d.rotate(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// put 写入条目，覆盖已有的记录
+func (d *diskTier) put(key string, value Value, expire time.Time) error {
+	data, err := d.opts.Codec.Encode(value)
+	if err != nil {
+		return fmt.Errorf("encode value: %v", err)
+	}
+	var expireNano int64
+	if !expire.IsZero() {
+		expireNano = expire.UnixNano()
+	}
+	rec := make([]byte, diskHeaderSize, diskHeaderSize+len(key)+len(data))
+	binary.BigEndian.PutUint32(rec[4:], uint32(len(key)))
+	binary.BigEndian.PutUint32(rec[8:], uint32(len(data)))
+	binary.BigEndian.PutUint64(rec[12:], uint64(expireNano))
+	rec = append(append(rec, key...), data...)
+	binary.BigEndian.PutUint32(rec, crc32.ChecksumIEEE(rec[4:]))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.segments) == 0 {
+		return fmt.Errorf("disk tier is closed")
+	}
+	d.remove(key)
+	seg := d.segments[len(d.segments)-1]
+	if seg.size > 0 && seg.size+int64(len(rec)) > d.opts.SegmentBytes {
+		if err := d.rotate(); err != nil {
+			return err
+		}
+		seg = d.segments[len(d.segments)-1]
+	}
+	if err := d.append(seg, key, rec, expire); err != nil {
+		return err
+	}
+	d.enforceLimit()
+	return nil
+}
+
+// append 将一条完整的记录追加到seg并更新索引，调用方需持有mu
+func (d *diskTier) append(seg *segment, key string, rec []byte, expire time.Time) error {
+	if _, err := seg.f.WriteAt(rec, seg.size); err != nil {
+		return fmt.Errorf("write segment: %v", err)
+	}
+	size := int64(len(rec))
+	d.index[key] = &diskLoc{seg: seg, off: seg.size, size: size, expire: expire}
+	seg.keys[key] = struct{}{}
+	seg.size += size
+	seg.live += size
+	d.bytes += size
+	d.live += size
+	return nil
+}
+
+// get 读取条目，已过期的条目会被删除并返回diskExpired
+func (d *diskTier) get(key string) (Value, time.Time, diskStatus, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	loc, ok := d.index[key]
+	if !ok {
+		return nil, time.Time{}, diskMissing, nil
+	}
+	rec, err := d.read(loc)
+	if err != nil {
+		d.remove(key)
+		return nil, time.Time{}, diskMissing, err
+	}
+	value, err := d.opts.Codec.Decode(rec[diskHeaderSize+len(key):])
+	if err != nil {
+		d.remove(key)
+		return nil, time.Time{}, diskMissing, fmt.Errorf("decode value: %v", err)
+	}
+	if !loc.expire.IsZero() && time.Now().After(loc.expire) {
+		d.remove(key)
+		d.expirations++
+		return value, loc.expire, diskExpired, nil
+	}
+	return value, loc.expire, diskFound, nil
+}
+
+// read 读取并校验一条记录，调用方需持有mu
+func (d *diskTier) read(loc *diskLoc) ([]byte, error) {
+	rec := make([]byte, loc.size)
+	if _, err := loc.seg.f.ReadAt(rec, loc.off); err != nil {
+		return nil, fmt.Errorf("read segment: %v", err)
+	}
+	if crc32.ChecksumIEEE(rec[4:]) != binary.BigEndian.Uint32(rec) {
+		return nil, fmt.Errorf("segment %d: checksum mismatch at %d", loc.seg.id, loc.off)
+	}
+	return rec, nil
+}
+
+func (d *diskTier) delete(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.remove(key)
+}
+
+// remove 从索引中删除key，不再有存活数据的封存段立即删除，调用方需持有mu
+func (d *diskTier) remove(key string) bool {
+	loc, ok := d.index[key]
+	if !ok {
+		return false
+	}
+	delete(d.index, key)
+	delete(loc.seg.keys, key)
+	loc.seg.live -= loc.size
+	d.live -= loc.size
+	if loc.seg.live == 0 && loc.seg != d.active() {
+		d.drop(loc.seg)
+	}
+	return true
+}
+
+// active 返回当前写入的段，关闭后返回nil，调用方需持有mu
+func (d *diskTier) active() *segment {
+	if len(d.segments) == 0 {
+		return nil
+	}
+	return d.segments[len(d.segments)-1]
+}
+
+// rotate 封存当前段并创建新段，然后压缩存活数据过少的封存段，调用方需持有mu
+func (d *diskTier) rotate() error {
+	d.nextID++
+	path := filepath.Join(d.opts.Dir, fmt.Sprintf("%06d.seg", d.nextID))
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("create segment: %v", err)
+	}
+	active := &segment{id: d.nextID, f: f, keys: make(map[string]struct{})}
+	d.segments = append(d.segments, active)
+
+	for _, seg := range append([]*segment(nil), d.segments[:len(d.segments)-1]...) {
+		if float64(seg.live) >= float64(seg.size)*compactRatio {
+			continue
+		}
+		for key := range seg.keys {
+			loc := d.index[key]
+			rec, err := d.read(loc)
+			if err != nil {
+				continue
+			}
+			d.live -= loc.size
+			if err := d.append(active, key, rec, loc.expire); err != nil {
+				// 写入失败时key仍指向旧段，随旧段一起丢弃
+				d.live += loc.size
+			}
+		}
+		d.drop(seg)
+	}
+	return nil
+}
+
+// drop 删除段文件，段中仍存活的条目一并丢弃，调用方需持有mu
+func (d *diskTier) drop(seg *segment) {
+	for key := range seg.keys {
+		if loc := d.index[key]; loc != nil && loc.seg == seg {
+			delete(d.index, key)
+			d.live -= loc.size
+			d.evictions++
+		}
+	}
+	seg.f.Close()
+	os.Remove(seg.f.Name())
+	d.bytes -= seg.size
+	for i, s := range d.segments {
+		if s == seg {
+			d.segments = append(d.segments[:i], d.segments[i+1:]...)
+			break
+		}
+	}
+}
+
+// enforceLimit 超出容量时丢弃最旧的段，调用方需持有mu
+func (d *diskTier) enforceLimit() {
+	for d.opts.MaxBytes > 0 && d.bytes > d.opts.MaxBytes && len(d.segments) > 1 {
+		d.drop(d.segments[0])
+	}
+}
+
+// rangeEntries 按写入顺序遍历未过期的条目，skip返回true的key被跳过，fn返回false时停止
+func (d *diskTier) rangeEntries(skip func(key string) bool, fn func(key string, value Value, expire time.Time) bool) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	keys := make([]string, 0, len(d.index))
+	for key := range d.index {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := d.index[keys[i]], d.index[keys[j]]
+		if a.seg.id != b.seg.id {
+			return a.seg.id < b.seg.id
+		}
+		return a.off < b.off
+	})
+
+	now := time.Now()
+	for _, key := range keys {
+		loc := d.index[key]
+		if skip(key) || (!loc.expire.IsZero() && now.After(loc.expire)) {
+			continue
+		}
+		rec, err := d.read(loc)
+		if err != nil {
+			continue
+		}
+		value, err := d.opts.Codec.Decode(rec[diskHeaderSize+len(key):])
+		if err != nil {
+			continue
+		}
+		if !fn(key, value, loc.expire) {
+			return false
+		}
+	}
+	return true
+}
+
+// clear 删除所有段并创建新的空段
+func (d *diskTier) clear() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.index = make(map[string]*diskLoc)
+	d.live = 0
+	for len(d.segments) > 0 {
+		d.drop(d.segments[0])
+	}
+	return d.rotate()
+}
+
+// close 关闭并删除所有段
+func (d *diskTier) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for len(d.segments) > 0 {
+		d.drop(d.segments[0])
+	}
+}
+
+func (d *diskTier) stats() (entries int, live int64, evictions, expirations uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.index), d.live, d.evictions, d.expirations
+}
@@ -3,19 +3,34 @@ package store
 import (
 	"container/list"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// removeReason 条目被移除的原因
+type removeReason int
+
+const (
+	removeDeleted removeReason = iota
+	removeEvicted
+	removeExpired
+)
+
 // lruCache LRU缓存实现
 type lruCache struct {
-	mu              sync.RWMutex
-	list            *list.List
-	items           map[string]*list.Element
-	expires         map[string]time.Time
-	maxBytes        int64
-	usedBytes       int64
-	onEvicted       func(key string, value Value)
+	mu        sync.RWMutex
+	list      *list.List
+	items     map[string]*list.Element
+	expires   map[string]time.Time
+	maxBytes  int64
+	usedBytes int64
+	onEvicted func(key string, value Value)
+	onExpired func(key string, value Value)
+	// onDemote 条目因容量不足被淘汰时调用，两级存储用它把条目降级到磁盘
+	onDemote        func(key string, value Value, expire time.Time)
 	cleanupInterval time.Duration
+	evictions       atomic.Uint64
+	expirations     atomic.Uint64
 }
 
 type lruEntry struct {
@@ -31,6 +46,7 @@ func NewLRUCache(opts Options) *lruCache {
 		expires:         make(map[string]time.Time),
 		maxBytes:        opts.MaxBytes,
 		onEvicted:       opts.OnEvicted,
+		onExpired:       opts.OnExpired,
 		cleanupInterval: opts.CleanupInterval,
 	}
 
@@ -42,10 +58,10 @@ func NewLRUCache(opts Options) *lruCache {
 	return c
 }
 
-// Get 实现Store接口
+// Get 实现Store接口，命中时需要调整链表顺序，因此持有写锁
 func (c *lruCache) Get(key string) (Value, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
 	if elem, ok := c.items[key]; ok {
 		if expTime, hasExp := c.expires[key]; hasExp && time.Now().After(expTime) {
@@ -93,7 +109,7 @@ func (c *lruCache) Delete(key string) bool {
 	defer c.mu.Unlock()
 
 	if elem, ok := c.items[key]; ok {
-		c.removeElement(elem)
+		c.removeElement(elem, removeDeleted)
 		return true
 	}
 	return false
@@ -117,14 +133,65 @@ func (c *lruCache) Len() int {
 	return c.list.Len()
 }
 
+// SetMaxBytes 实现Resizer接口，缩容时立即淘汰超出的条目
+func (c *lruCache) SetMaxBytes(maxBytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxBytes = maxBytes
+	c.evict()
+}
+
+// Range 实现Ranger接口
+func (c *lruCache) Range(fn func(key string, value Value, expire time.Time) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Now()
+	for elem := c.list.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*lruEntry)
+		expTime, hasExp := c.expires[entry.key]
+		if hasExp && now.After(expTime) {
+			continue
+		}
+		if !fn(entry.key, entry.value, expTime) {
+			return
+		}
+	}
+}
+
+// Stats 实现Store接口
+func (c *lruCache) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return Stats{
+		Bytes:       c.usedBytes,
+		Entries:     c.list.Len(),
+		Evictions:   c.evictions.Load(),
+		Expirations: c.expirations.Load(),
+	}
+}
+
 // removeElement 删除缓存元素
-func (c *lruCache) removeElement(elem *list.Element) {
+func (c *lruCache) removeElement(elem *list.Element, reason removeReason) {
 	entry := elem.Value.(*lruEntry)
+	if reason == removeEvicted && c.onDemote != nil {
+		c.onDemote(entry.key, entry.value, c.expires[entry.key])
+	}
 	c.list.Remove(elem)
 	delete(c.items, entry.key)
 	delete(c.expires, entry.key)
 	c.usedBytes -= int64(len(entry.key) + entry.value.Len())
 
+	switch reason {
+	case removeEvicted:
+		c.evictions.Add(1)
+	case removeExpired:
+		c.expirations.Add(1)
+		if c.onExpired != nil {
+			c.onExpired(entry.key, entry.value)
+		}
+	}
+
 	if c.onEvicted != nil {
 		c.onEvicted(entry.key, entry.value)
 	}
@@ -136,7 +203,7 @@ func (c *lruCache) evict() {
 	for key, expTime := range c.expires {
 		if now.After(expTime) {
 			if elem, ok := c.items[key]; ok {
-				c.removeElement(elem)
+				c.removeElement(elem, removeExpired)
 			}
 		}
 	}
@@ -144,7 +211,7 @@ func (c *lruCache) evict() {
 	for c.maxBytes > 0 && c.usedBytes > c.maxBytes {
 		elem := c.list.Front()
 		if elem != nil {
-			c.removeElement(elem)
+			c.removeElement(elem, removeEvicted)
 		}
 	}
 }
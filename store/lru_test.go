@@ -112,4 +112,58 @@ func TestLRU_Update(t *testing.T) {
 	if lru.usedBytes != 5 {
 		t.Fatalf("usedBytes update failed, expect 5, got %d", lru.usedBytes)
 	}
-}
\ No newline at end of file
+}
+func TestLRU_Stats(t *testing.T) {
+	lru := NewLRUCache(Options{MaxBytes: 10})
+	lru.Set("k1", String("v1"))
+	lru.Set("k2", String("v2"))
+	lru.Set("k3", String("v3"))
+	lru.SetWithExpiration("k4", String("v4"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	lru.mu.Lock()
+	lru.evict()
+	lru.mu.Unlock()
+
+	stats := lru.Stats()
+	if stats.Evictions != 2 || stats.Expirations != 1 {
+		t.Fatalf("expect 2 evictions and 1 expiration, got %+v", stats)
+	}
+	if stats.Entries != 1 || stats.Bytes != 4 {
+		t.Fatalf("expect 1 entry of 4 bytes, got %+v", stats)
+	}
+}
+
+func TestLRU_SetMaxBytes(t *testing.T) {
+	lru := NewLRUCache(Options{MaxBytes: 100})
+	lru.Set("k1", String("v1"))
+	lru.Set("k2", String("v2"))
+	lru.SetMaxBytes(4)
+	if _, ok := lru.Get("k1"); ok {
+		t.Fatal("k1 should be evicted after shrinking")
+	}
+	if _, ok := lru.Get("k2"); !ok {
+		t.Fatal("k2 should still exist")
+	}
+}
+
+func TestLRU_Range(t *testing.T) {
+	lru := NewLRUCache(Options{})
+	lru.Set("k1", String("v1"))
+	lru.SetWithExpiration("k2", String("v2"), time.Hour)
+	lru.SetWithExpiration("k3", String("v3"), time.Millisecond)
+	lru.Set("k4", String("v4"))
+	lru.Get("k1")
+	time.Sleep(5 * time.Millisecond)
+
+	var keys []string
+	lru.Range(func(key string, value Value, expire time.Time) bool {
+		keys = append(keys, key)
+		if key == "k2" && expire.IsZero() {
+			t.Fatalf("expect expiration for k2")
+		}
+		return true
+	})
+	if want := []string{"k2", "k4", "k1"}; len(keys) != len(want) || keys[0] != want[0] || keys[1] != want[1] || keys[2] != want[2] {
+		t.Fatalf("expect %v in LRU order, got %v", want, keys)
+	}
+}
@@ -15,14 +15,37 @@ type Store interface {
 	Delete(key string) bool
 	Clear()
 	Len() int
+	Stats() Stats
+}
+
+// Resizer 支持运行时调整容量的存储
+type Resizer interface {
+	SetMaxBytes(maxBytes int64)
+}
+
+// Ranger 支持按LRU顺序遍历的存储，用于生成快照
+type Ranger interface {
+	// Range 从最久未使用到最近使用依次遍历未过期的条目，expire为零值表示不过期，fn返回false时停止。
+	// 遍历期间持有存储的锁，fn中不能再访问该存储
+	Range(fn func(key string, value Value, expire time.Time) bool)
+}
+
+// Stats 存储统计信息
+type Stats struct {
+	Bytes       int64  // 已使用的字节数
+	Entries     int    // 条目数
+	Evictions   uint64 // 因容量不足被淘汰的条目数
+	Expirations uint64 // 因过期被清理的条目数
 }
 
 // CacheType 缓存类型
 type CacheType string
 
 const (
-	LRU CacheType = "lru"
-	LFU CacheType = "lfu"
+	LRU    CacheType = "lru"
+	LFU    CacheType = "lfu"
+	Tiered CacheType = "tiered" // 内存LRU+磁盘两级存储，需要配置Options.Disk
+	Arena  CacheType = "arena"  // 条目编码后保存在预分配的字节数组中，需要配置Options.Codec
 )
 
 // Options 通用缓存配置选项
@@ -30,6 +53,26 @@ type Options struct {
 	MaxBytes        int64
 	CleanupInterval time.Duration
 	OnEvicted       func(key string, value Value)
+	// OnExpired 条目因过期被清理时调用，在OnEvicted之前
+	OnExpired func(key string, value Value)
+	// Disk 磁盘层配置，只用于Tiered
+	Disk DiskOptions
+	// Codec 值的编解码，只用于Arena
+	Codec Codec
+}
+
+// Codec 值的编解码，磁盘层和Arena用它把条目保存为字节
+type Codec interface {
+	Encode(value Value) ([]byte, error)
+	Decode(data []byte) (Value, error)
+}
+
+// DiskOptions 两级存储的磁盘层配置
+type DiskOptions struct {
+	Dir          string // 日志段所在目录，打开时清空
+	MaxBytes     int64  // 磁盘层容量，超出时丢弃最旧的段，为0时不限制
+	SegmentBytes int64  // 单个日志段的大小，默认64MB
+	Codec        Codec
 }
 
 // NewStore 创建缓存存储实例
@@ -39,6 +82,19 @@ func NewStore(cacheType CacheType, opts Options) Store {
 		return NewLRUCache(opts)
 	case LFU:
 		return nil
+	case Tiered:
+		// 打开磁盘层可能失败，需要错误信息时直接调用NewTieredStore
+		s, err := NewTieredStore(opts)
+		if err != nil {
+			return nil
+		}
+		return s
+	case Arena:
+		s, err := NewArenaStore(opts)
+		if err != nil {
+			return nil
+		}
+		return s
 	default:
 		return NewLRUCache(opts)
 	}
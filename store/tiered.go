@@ -0,0 +1,157 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// tieredStore 内存+磁盘两级存储。内存层为LRU，因容量不足被淘汰的条目降级到磁盘层，
+// 磁盘层命中时重新提升到内存。Options.OnEvicted不会被调用
+type tieredStore struct {
+	mu        sync.Mutex // 串行化跨两层的操作，避免提升旧值时覆盖并发写入的新值
+	mem       *lruCache
+	disk      *diskTier
+	onExpired func(key string, value Value)
+}
+
+// NewTieredStore 创建两级存储，opts.MaxBytes为内存层容量
+func NewTieredStore(opts Options) (*tieredStore, error) {
+	disk, err := openDiskTier(opts.Disk)
+	if err != nil {
+		return nil, err
+	}
+	t := &tieredStore{disk: disk, onExpired: opts.OnExpired}
+	t.mem = NewLRUCache(Options{
+		MaxBytes:        opts.MaxBytes,
+		CleanupInterval: opts.CleanupInterval,
+		OnExpired:       opts.OnExpired,
+	})
+	t.mem.onDemote = t.demote
+	return t, nil
+}
+
+// demote 将内存层淘汰的条目写入磁盘层，在内存层的锁内调用。写入失败时条目被丢弃
+func (t *tieredStore) demote(key string, value Value, expire time.Time) {
+	if !expire.IsZero() && time.Now().After(expire) {
+		return
+	}
+	t.disk.put(key, value, expire)
+}
+
+// Get 实现Store接口，磁盘层命中时提升到内存
+func (t *tieredStore) Get(key string) (Value, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if v, ok := t.mem.Get(key); ok {
+		return v, true
+	}
+	value, expire, status, _ := t.disk.get(key)
+	switch status {
+	case diskExpired:
+		if t.onExpired != nil {
+			t.onExpired(key, value)
+		}
+		return nil, false
+	case diskFound:
+		t.disk.delete(key)
+		if expire.IsZero() {
+			t.mem.Set(key, value)
+		} else {
+			t.mem.SetWithExpiration(key, value, time.Until(expire))
+		}
+		return value, true
+	default:
+		return nil, false
+	}
+}
+
+// Set 实现Store接口
+func (t *tieredStore) Set(key string, value Value) error {
+	return t.SetWithExpiration(key, value, 0)
+}
+
+// SetWithExpiration 实现Store接口，新值总是写入内存层
+func (t *tieredStore) SetWithExpiration(key string, value Value, expiration time.Duration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.disk.delete(key)
+	return t.mem.SetWithExpiration(key, value, expiration)
+}
+
+// Delete 实现Store接口
+func (t *tieredStore) Delete(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	inMem := t.mem.Delete(key)
+	onDisk := t.disk.delete(key)
+	return inMem || onDisk
+}
+
+// Clear 实现Store接口
+func (t *tieredStore) Clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mem.Clear()
+	t.disk.clear()
+}
+
+// Len 实现Store接口
+func (t *tieredStore) Len() int {
+	entries, _, _, _ := t.disk.stats()
+	return t.mem.Len() + entries
+}
+
+// SetMaxBytes 实现Resizer接口，只调整内存层容量，缩容时超出的条目降级到磁盘
+func (t *tieredStore) SetMaxBytes(maxBytes int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mem.SetMaxBytes(maxBytes)
+}
+
+// Range 实现Ranger接口，先遍历磁盘层再遍历内存层，磁盘层的条目总是比内存层的旧
+func (t *tieredStore) Range(fn func(key string, value Value, expire time.Time) bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	type memEntry struct {
+		key    string
+		value  Value
+		expire time.Time
+	}
+	var entries []memEntry
+	inMem := make(map[string]bool)
+	t.mem.Range(func(key string, value Value, expire time.Time) bool {
+		entries = append(entries, memEntry{key, value, expire})
+		inMem[key] = true
+		return true
+	})
+	if !t.disk.rangeEntries(func(key string) bool { return inMem[key] }, fn) {
+		return
+	}
+	for _, e := range entries {
+		if !fn(e.key, e.value, e.expire) {
+			return
+		}
+	}
+}
+
+// Stats 实现Store接口，Evictions只统计从磁盘层丢弃的条目，降级到磁盘不计入
+func (t *tieredStore) Stats() Stats {
+	mem := t.mem.Stats()
+	entries, live, evictions, expirations := t.disk.stats()
+	return Stats{
+		Bytes:       mem.Bytes + live,
+		Entries:     mem.Entries + entries,
+		Evictions:   evictions,
+		Expirations: mem.Expirations + expirations,
+	}
+}
+
+// Close 删除磁盘层的所有段文件
+func (t *tieredStore) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.disk.close()
+	return nil
+}
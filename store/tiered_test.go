@@ -0,0 +1,162 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stringCodec struct{}
+
+func (stringCodec) Encode(v Value) ([]byte, error) { return []byte(v.(String)), nil }
+func (stringCodec) Decode(b []byte) (Value, error) { return String(b), nil }
+
+func newTiered(t *testing.T, memBytes int64, disk DiskOptions) *tieredStore {
+	t.Helper()
+	disk.Dir = t.TempDir()
+	disk.Codec = stringCodec{}
+	s, err := NewTieredStore(Options{MaxBytes: memBytes, Disk: disk})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func segments(t *testing.T, s *tieredStore) int {
+	files, _ := filepath.Glob(filepath.Join(s.disk.opts.Dir, "*.seg"))
+	return len(files)
+}
+
+func TestTiered_DemoteAndPromote(t *testing.T) {
+	s := newTiered(t, 8, DiskOptions{})
+	s.Set("k1", String("v1"))
+	s.SetWithExpiration("k2", String("v2"), time.Hour)
+	s.Set("k3", String("v3")) // 内存层只能放下两个条目，k1降级到磁盘
+
+	if s.mem.Len() != 2 || s.Len() != 3 {
+		t.Fatalf("expect 2 entries in memory and 3 in total, got %d %d", s.mem.Len(), s.Len())
+	}
+	if v, ok := s.Get("k1"); !ok || v.(String) != "v1" {
+		t.Fatalf("expect k1 promoted from disk, got %v %v", v, ok)
+	}
+	if _, ok := s.mem.Get("k1"); !ok {
+		t.Fatal("expect k1 in memory after promotion")
+	}
+	// 提升k1时k2被降级，过期时间随条目保存
+	if loc := s.disk.index["k2"]; loc == nil || loc.expire.IsZero() {
+		t.Fatalf("expect k2 demoted with expiration, got %+v", loc)
+	}
+
+	s.Set("k2", String("new"))
+	if v, _ := s.Get("k2"); v.(String) != "new" {
+		t.Fatalf("expect write to replace disk entry, got %v", v)
+	}
+	if !s.Delete("k3") || s.Delete("missing") {
+		t.Fatal("unexpected delete result")
+	}
+	stats := s.Stats()
+	if stats.Entries != s.Len() || stats.Evictions != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	var keys []string
+	s.Range(func(key string, value Value, expire time.Time) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if len(keys) != s.Len() {
+		t.Fatalf("expect range over both tiers, got %v", keys)
+	}
+
+	s.Clear()
+	if s.Len() != 0 || segments(t, s) != 1 {
+		t.Fatalf("expect empty store after clear, got %d entries, %d segments", s.Len(), segments(t, s))
+	}
+}
+
+func TestTiered_DiskExpiration(t *testing.T) {
+	var expired []string
+	s := newTiered(t, 4, DiskOptions{})
+	s.onExpired = func(key string, value Value) { expired = append(expired, key) }
+	s.SetWithExpiration("k1", String("v1"), 20*time.Millisecond)
+	s.Set("k2", String("v2"))
+	time.Sleep(50 * time.Millisecond)
+
+	if _, ok := s.Get("k1"); ok {
+		t.Fatal("expect expired entry on disk to miss")
+	}
+	if len(expired) != 1 || s.Stats().Expirations != 1 {
+		t.Fatalf("expect one expiration, got %v %+v", expired, s.Stats())
+	}
+}
+
+func TestTiered_SegmentGC(t *testing.T) {
+	value := String(strings.Repeat("x", 100))
+	s := newTiered(t, 1, DiskOptions{SegmentBytes: 512, MaxBytes: 2048})
+
+	// 内存层放不下任何条目，所有写入都直接降级到磁盘
+	for i := 0; i < 20; i++ {
+		s.Set(fmt.Sprintf("k%d", i), value)
+	}
+	if s.disk.bytes > 2048 || segments(t, s) != len(s.disk.segments) {
+		t.Fatalf("expect disk usage within limit, got %d bytes in %d segments", s.disk.bytes, segments(t, s))
+	}
+	if evictions := s.Stats().Evictions; evictions == 0 || s.Len() != 20-int(evictions) {
+		t.Fatalf("expect oldest segments dropped, got %d evictions and %d entries", evictions, s.Len())
+	}
+
+	// 删除一个封存段的所有条目后该段立即被删除
+	first := s.disk.segments[0]
+	for key := range first.keys {
+		s.Delete(key)
+	}
+	if _, err := os.Stat(first.f.Name()); !os.IsNotExist(err) {
+		t.Fatalf("expect dead segment removed, got %v", err)
+	}
+	for i := 10; i < 20; i++ {
+		if v, ok := s.Get(fmt.Sprintf("k%d", i)); ok && v.(String) != value {
+			t.Fatalf("unexpected value for k%d", i)
+		}
+	}
+}
+
+func TestTiered_Compaction(t *testing.T) {
+	value := String(strings.Repeat("x", 100))
+	s := newTiered(t, 1, DiskOptions{SegmentBytes: 512})
+	for i := 0; i < 4; i++ {
+		s.Set(fmt.Sprintf("k%d", i), value)
+	}
+	// 覆盖写入使第一个段只剩一个存活条目，下次切换段时被压缩
+	for i := 1; i < 4; i++ {
+		s.Set(fmt.Sprintf("k%d", i), value)
+	}
+	for i := 4; i < 12; i++ {
+		s.Set(fmt.Sprintf("k%d", i), value)
+	}
+	if s.disk.segments[0].id == 1 {
+		t.Fatal("expect first segment compacted")
+	}
+	if v, ok := s.Get("k0"); !ok || v.(String) != value {
+		t.Fatal("expect live entry moved during compaction")
+	}
+	var live int64
+	for _, seg := range s.disk.segments {
+		live += seg.live
+	}
+	if live != s.disk.live {
+		t.Fatalf("live bytes mismatch: %d != %d", live, s.disk.live)
+	}
+}
+
+func TestNewTieredStore_Invalid(t *testing.T) {
+	if _, err := NewTieredStore(Options{Disk: DiskOptions{Codec: stringCodec{}}}); err == nil {
+		t.Fatal("expect error without dir")
+	}
+	if _, err := NewTieredStore(Options{Disk: DiskOptions{Dir: t.TempDir()}}); err == nil {
+		t.Fatal("expect error without codec")
+	}
+}
@@ -0,0 +1,252 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultReloadInterval = 30 * time.Second
+
+// Config 节点间TLS配置
+type Config struct {
+	CertFile          string        // 本节点证书
+	KeyFile           string        // 本节点私钥
+	CAFile            string        // 用于校验对端证书的CA，为空时使用系统根证书
+	ServerName        string        // 客户端校验服务端证书时使用的主机名，为空时使用连接的主机名或IP
+	ClientAuth        bool          // 服务端是否要求并校验客户端证书(mTLS)
+	AllowedIdentities []string      // 允许的对端身份(证书CN、DNS SAN或URI SAN)，为空时不限制，客户端和服务端都会检查
+	ReloadInterval    time.Duration // 检查证书文件变化的间隔
+}
+
+// Reloader 从磁盘加载证书，并在文件变化时自动重新加载
+type Reloader struct {
+	config    *Config
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+	allowed   map[string]struct{}
+}
+
+// NewReloader 创建Reloader并立即加载一次证书
+func NewReloader(config *Config) (*Reloader, error) {
+	if config == nil {
+		return nil, errors.New("nil tls config")
+	}
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, errors.New("cert file and key file are required")
+	}
+	r := &Reloader{
+		config:   config,
+		modTimes: make(map[string]time.Time),
+		allowed:  make(map[string]struct{}, len(config.AllowedIdentities)),
+	}
+	for _, id := range config.AllowedIdentities {
+		r.allowed[id] = struct{}{}
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ServerConfig 返回服务端使用的tls.Config
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.maybeReload()
+			cert, pool := r.current()
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				ClientCAs:    pool,
+			}
+			if r.config.ClientAuth {
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+				cfg.VerifyConnection = r.verifyIdentity
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// ClientConfig 返回客户端使用的tls.Config，Config.ServerName为空时按握手的SNI校验主机名，
+// 连接IP地址时没有SNI，需要使用 ClientConfigFor。
+// 为了支持CA热更新，证书链由 verifyServer 使用当前CA手动校验
+func (r *Reloader) ClientConfig() *tls.Config {
+	return r.ClientConfigFor("")
+}
+
+// ClientConfigFor 返回连接addr(host或host:port)使用的tls.Config，Config.ServerName为空时按addr中的主机名或IP校验服务端证书
+func (r *Reloader) ClientConfigFor(addr string) *tls.Config {
+	name := r.config.ServerName
+	if name == "" && addr != "" {
+		name = addr
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			name = host
+		}
+	}
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true,
+		ServerName:         name,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			r.maybeReload()
+			cert, _ := r.current()
+			return cert, nil
+		},
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			return r.verifyServer(cs, name)
+		},
+	}
+}
+
+// verifyServer 校验服务端证书链、主机名和身份，name为空时使用SNI，无法确定主机名时拒绝连接
+func (r *Reloader) verifyServer(cs tls.ConnectionState, name string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("no server certificate")
+	}
+	if name == "" {
+		name = cs.ServerName
+	}
+	if name == "" {
+		return errors.New("server name is required to verify the server certificate")
+	}
+	r.maybeReload()
+	_, pool := r.current()
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: intermediates,
+		DNSName:       name,
+	})
+	if err != nil {
+		return fmt.Errorf("verify server certificate: %v", err)
+	}
+	// 身份白名单与服务端是否要求客户端证书无关，客户端总是检查
+	return r.verifyIdentity(cs)
+}
+
+// verifyIdentity 检查对端证书身份是否在白名单内
+func (r *Reloader) verifyIdentity(cs tls.ConnectionState) error {
+	if len(r.allowed) == 0 {
+		return nil
+	}
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("no peer certificate")
+	}
+	for _, id := range Identities(cs.PeerCertificates[0]) {
+		if _, ok := r.allowed[id]; ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("peer identity %v is not allowed", Identities(cs.PeerCertificates[0]))
+}
+
+// Identities 返回证书中可用于鉴权的身份：CN、DNS SAN和URI SAN
+func Identities(cert *x509.Certificate) []string {
+	ids := make([]string, 0, 1+len(cert.DNSNames)+len(cert.URIs))
+	if cert.Subject.CommonName != "" {
+		ids = append(ids, cert.Subject.CommonName)
+	}
+	ids = append(ids, cert.DNSNames...)
+	for _, uri := range cert.URIs {
+		ids = append(ids, uri.String())
+	}
+	return ids
+}
+
+// current 返回当前证书和CA
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, r.pool
+}
+
+// maybeReload 距离上次检查超过间隔时检查文件是否变化，变化则重新加载
+func (r *Reloader) maybeReload() {
+	interval := r.config.ReloadInterval
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+
+	r.mu.Lock()
+	if time.Since(r.lastCheck) < interval {
+		r.mu.Unlock()
+		return
+	}
+	r.lastCheck = time.Now()
+	changed := false
+	for _, file := range r.files() {
+		if info, err := os.Stat(file); err == nil && !info.ModTime().Equal(r.modTimes[file]) {
+			changed = true
+			break
+		}
+	}
+	r.mu.Unlock()
+
+	if changed {
+		// 加载失败时继续使用旧证书，避免证书轮换过程中的中间状态导致握手失败
+		_ = r.reload()
+	}
+}
+
+// reload 从磁盘加载证书和CA
+func (r *Reloader) reload() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("stat %s: %v", file, err)
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %v", err)
+	}
+
+	var pool *x509.CertPool
+	if r.config.CAFile != "" {
+		pem, err := os.ReadFile(r.config.CAFile)
+		if err != nil {
+			return fmt.Errorf("read ca file: %v", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", r.config.CAFile)
+		}
+	} else if pool, err = x509.SystemCertPool(); err != nil {
+		return fmt.Errorf("load system cert pool: %v", err)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.pool = pool
+	r.modTimes = modTimes
+	r.lastCheck = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// files 返回需要监控的文件
+func (r *Reloader) files() []string {
+	files := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.CAFile != "" {
+		files = append(files, r.config.CAFile)
+	}
+	return files
+}
@@ -0,0 +1,217 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue 签发证书并写入dir，返回证书和私钥路径
+func (ca *testCA) issue(t *testing.T, dir, name string, serial int64) (string, string) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	return certFile, keyFile
+}
+
+// handshake 在本地TCP连接上完成一次TLS握手，返回客户端看到的服务端证书和双方错误
+func handshake(server, client *tls.Config) (*x509.Certificate, error, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err, err
+	}
+	defer lis.Close()
+	cc, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		return nil, err, err
+	}
+	defer cc.Close()
+	sc, err := lis.Accept()
+	if err != nil {
+		return nil, err, err
+	}
+	defer sc.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		conn := tls.Server(sc, server)
+		err := conn.Handshake()
+		if err != nil {
+			cc.Close()
+		}
+		errCh <- err
+	}()
+	conn := tls.Client(cc, client)
+	clientErr := conn.Handshake()
+	if clientErr != nil {
+		sc.Close()
+	}
+	serverErr := <-errCh
+	if clientErr != nil {
+		return nil, serverErr, clientErr
+	}
+	return conn.ConnectionState().PeerCertificates[0], serverErr, nil
+}
+
+func TestReloader_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.crt")
+	os.WriteFile(caFile, ca.pem, 0600)
+
+	serverCert, serverKey := ca.issue(t, dir, "node-a", 2)
+	clientCert, clientKey := ca.issue(t, dir, "node-b", 3)
+	rogueCert, rogueKey := ca.issue(t, dir, "rogue", 4)
+
+	server, err := NewReloader(&Config{
+		CertFile:          serverCert,
+		KeyFile:           serverKey,
+		CAFile:            caFile,
+		ClientAuth:        true,
+		AllowedIdentities: []string{"node-b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewReloader(&Config{
+		CertFile:          clientCert,
+		KeyFile:           clientKey,
+		CAFile:            caFile,
+		ServerName:        "node-a",
+		AllowedIdentities: []string{"node-a"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, serverErr, clientErr := handshake(server.ServerConfig(), client.ClientConfig()); serverErr != nil || clientErr != nil {
+		t.Fatalf("handshake failed: server=%v client=%v", serverErr, clientErr)
+	}
+
+	rogue, err := NewReloader(&Config{CertFile: rogueCert, KeyFile: rogueKey, CAFile: caFile, ServerName: "node-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, serverErr, _ := handshake(server.ServerConfig(), rogue.ClientConfig()); serverErr == nil {
+		t.Fatal("server should reject identity not in allow list")
+	}
+
+	wrongName, _ := NewReloader(&Config{CertFile: clientCert, KeyFile: clientKey, CAFile: caFile, ServerName: "node-c"})
+	if _, _, clientErr := handshake(server.ServerConfig(), wrongName.ClientConfig()); clientErr == nil {
+		t.Fatal("client should reject server name mismatch")
+	}
+}
+
+func TestReloader_ClientVerification(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.crt")
+	os.WriteFile(caFile, ca.pem, 0600)
+	serverCert, serverKey := ca.issue(t, dir, "node-a", 2)
+	clientCert, clientKey := ca.issue(t, dir, "node-b", 3)
+
+	// 服务端不要求客户端证书
+	server, _ := NewReloader(&Config{CertFile: serverCert, KeyFile: serverKey, CAFile: caFile})
+	client, _ := NewReloader(&Config{CertFile: clientCert, KeyFile: clientKey, CAFile: caFile})
+
+	if _, _, clientErr := handshake(server.ServerConfig(), client.ClientConfig()); clientErr == nil {
+		t.Fatal("client should reject handshake without a server name")
+	}
+	if _, _, clientErr := handshake(server.ServerConfig(), client.ClientConfigFor("node-a:9999")); clientErr != nil {
+		t.Fatalf("expect name derived from addr, got %v", clientErr)
+	}
+	if _, _, clientErr := handshake(server.ServerConfig(), client.ClientConfigFor("127.0.0.1:9999")); clientErr == nil {
+		t.Fatal("client should reject certificate without the dialed IP")
+	}
+
+	strict, _ := NewReloader(&Config{CertFile: clientCert, KeyFile: clientKey, CAFile: caFile, AllowedIdentities: []string{"node-c"}})
+	if _, _, clientErr := handshake(server.ServerConfig(), strict.ClientConfigFor("node-a")); clientErr == nil {
+		t.Fatal("client should reject server identity not in allow list")
+	}
+}
+
+func TestReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.crt")
+	os.WriteFile(caFile, ca.pem, 0600)
+	certFile, keyFile := ca.issue(t, dir, "node-a", 2)
+
+	r, err := NewReloader(&Config{
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		CAFile:         caFile,
+		ReloadInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, _ := r.current()
+
+	// 重新签发同名证书，并确保修改时间变化
+	ca.issue(t, dir, "node-a", 5)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	time.Sleep(2 * time.Millisecond)
+
+	r.maybeReload()
+	cur, _ := r.current()
+	if cur == old {
+		t.Fatal("certificate should be reloaded")
+	}
+	leaf, _ := x509.ParseCertificate(cur.Certificate[0])
+	if leaf.SerialNumber.Int64() != 5 {
+		t.Fatalf("expect serial 5, got %d", leaf.SerialNumber.Int64())
+	}
+}
@@ -0,0 +1,104 @@
+package gocache
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// instrumentationName 通过otel.SetTracerProvider配置导出方式，未配置时为no-op
+const instrumentationName = "gocache"
+
+// span属性
+var (
+	attrGroup       = attribute.Key("gocache.group")
+	attrCacheHit    = attribute.Key("gocache.cache_hit")
+	attrDedup       = attribute.Key("gocache.singleflight.shared")
+	attrPeerAddr    = attribute.Key("gocache.peer.address")
+	attrRPCMethod   = attribute.Key("rpc.method")
+	attrRPCGRPCCode = attribute.Key("rpc.grpc.status_code")
+)
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// endSpan 记录错误并结束span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// metadataCarrier 让propagator读写grpc metadata
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// tracingClientInterceptor 为发往peer的RPC创建client span，并将trace上下文写入metadata
+func tracingClientInterceptor(addr string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := tracer().Start(ctx, method,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attrRPCMethod.String(method), attrPeerAddr.String(addr)),
+		)
+
+		md, ok := metadata.FromOutgoingContext(ctx)
+		if ok {
+			md = md.Copy()
+		} else {
+			md = metadata.MD{}
+		}
+		otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+		ctx = metadata.NewOutgoingContext(ctx, md)
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		span.SetAttributes(attrRPCGRPCCode.Int(int(status.Code(err))))
+		endSpan(span, err)
+		return err
+	}
+}
+
+// tracingServerInterceptor 从metadata中恢复trace上下文并创建server span
+func tracingServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	}
+	ctx, span := tracer().Start(ctx, info.FullMethod,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrRPCMethod.String(info.FullMethod)),
+	)
+	if r, ok := req.(interface{ GetGroup() string }); ok {
+		span.SetAttributes(attrGroup.String(r.GetGroup()))
+	}
+
+	resp, err := handler(ctx, req)
+	span.SetAttributes(attrRPCGRPCCode.Int(int(status.Code(err))))
+	endSpan(span, err)
+	return resp, err
+}
@@ -0,0 +1,90 @@
+package gocache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// setupTracing 使用内存中的exporter替代OTLP collector
+func setupTracing(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return exporter
+}
+
+func findSpan(spans tracetest.SpanStubs, name string) *tracetest.SpanStub {
+	for i := range spans {
+		if spans[i].Name == name {
+			return &spans[i]
+		}
+	}
+	return nil
+}
+
+func TestTracing_GroupLoad(t *testing.T) {
+	exporter := setupTracing(t)
+	g := NewGroup("tracing-test", 1<<10, GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		return []byte("v"), true, time.Time{}
+	}))
+	defer DestroyGroup("tracing-test")
+
+	g.Get(context.Background(), "k")
+	spans := exporter.GetSpans()
+	load, local, getter := findSpan(spans, "Group.load"), findSpan(spans, "Group.getLocally"), findSpan(spans, "Getter.Get")
+	if load == nil || local == nil || getter == nil {
+		t.Fatalf("missing spans, got %d spans", len(spans))
+	}
+	if local.Parent.SpanID() != load.SpanContext.SpanID() || getter.Parent.SpanID() != local.SpanContext.SpanID() {
+		t.Fatal("spans should be nested load -> getLocally -> Getter.Get")
+	}
+	for _, attr := range local.Attributes {
+		if attr.Key == attrCacheHit && attr.Value.AsBool() {
+			t.Fatal("first load should be a cache miss")
+		}
+	}
+}
+
+func TestTracing_Propagation(t *testing.T) {
+	exporter := setupTracing(t)
+
+	server := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		// 模拟网络传输：将client写入的metadata作为server收到的metadata
+		md, _ := metadata.FromOutgoingContext(ctx)
+		ctx = metadata.NewIncomingContext(context.Background(), md)
+		_, err := tracingServerInterceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+		return err
+	}
+	client := tracingClientInterceptor("127.0.0.1:8001")
+	if err := client(context.Background(), "/proto.GoCache/Get", nil, nil, nil, server); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expect client and server spans, got %d", len(spans))
+	}
+	serverSpan, clientSpan := spans[0], spans[1]
+	if serverSpan.SpanContext.TraceID() != clientSpan.SpanContext.TraceID() {
+		t.Fatal("server span should join client trace")
+	}
+	if serverSpan.Parent.SpanID() != clientSpan.SpanContext.SpanID() {
+		t.Fatal("server span should be child of client span")
+	}
+}
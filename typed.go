@@ -0,0 +1,207 @@
+package gocache
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec 在T和缓存中保存的字节之间转换
+type Codec[T any] interface {
+	Marshal(v T) ([]byte, error)
+	Unmarshal(data []byte) (T, error)
+}
+
+// JSONCodec 使用encoding/json编解码
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Marshal(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// GobCodec 使用encoding/gob编解码，每个值单独编码，包含完整的类型信息
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Marshal(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+// ProtoCodec 使用protobuf编解码，T为生成的消息指针类型，如*pb.Request
+type ProtoCodec[T proto.Message] struct{}
+
+func (ProtoCodec[T]) Marshal(v T) ([]byte, error) {
+	return proto.Marshal(v)
+}
+
+func (ProtoCodec[T]) Unmarshal(data []byte) (T, error) {
+	var zero T
+	v := zero.ProtoReflect().Type().New().Interface().(T)
+	err := proto.Unmarshal(data, v)
+	return v, err
+}
+
+// TypedGroup 在Group之上按Codec读写T类型的值
+type TypedGroup[T any] struct {
+	group   *Group
+	codec   Codec[T]
+	decoded *decodedCache[T] // 未开启时为nil
+}
+
+type typedOptions struct {
+	decodedCacheSize int
+}
+
+// TypedGroupOption 定义TypedGroup的配置选项
+type TypedGroupOption func(*typedOptions)
+
+// WithDecodedCache 在本地保存最近读取的最多size个解码后的值，条目的CAS版本号不变时直接返回，不再解码。
+// 多次Get可能返回同一个值，调用方不能修改返回的值
+func WithDecodedCache(size int) TypedGroupOption {
+	return func(o *typedOptions) {
+		o.decodedCacheSize = size
+	}
+}
+
+// NewTypedGroup 用codec包装g，g的Getter需要返回codec编码后的数据
+func NewTypedGroup[T any](g *Group, codec Codec[T], opts ...TypedGroupOption) *TypedGroup[T] {
+	var o typedOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	t := &TypedGroup[T]{group: g, codec: codec}
+	if o.decodedCacheSize > 0 {
+		t.decoded = newDecodedCache[T](o.decodedCacheSize)
+	}
+	return t
+}
+
+// Group 返回底层的Group
+func (t *TypedGroup[T]) Group() *Group {
+	return t.group
+}
+
+// Get 读取key并解码，错误与Group.Get相同，解码失败时返回codec的错误
+func (t *TypedGroup[T]) Get(ctx context.Context, key string) (T, error) {
+	var zero T
+	view, err := t.group.Get(ctx, key)
+	if err != nil {
+		return zero, err
+	}
+	cas := view.CAS()
+	if t.decoded != nil && cas != 0 {
+		if v, ok := t.decoded.get(key, cas); ok {
+			return v, nil
+		}
+	}
+	v, err := t.codec.Unmarshal(view.ByteSlice())
+	if err != nil {
+		return zero, fmt.Errorf("decode %s: %v", key, err)
+	}
+	if t.decoded != nil && cas != 0 {
+		t.decoded.add(key, cas, v)
+	}
+	return v, nil
+}
+
+// Set 编码后使用默认TTL写入key
+func (t *TypedGroup[T]) Set(ctx context.Context, key string, v T) error {
+	return t.SetWithTTL(ctx, key, v, 0)
+}
+
+// SetWithTTL 编码后写入key，ttl<=0时使用默认TTL
+func (t *TypedGroup[T]) SetWithTTL(ctx context.Context, key string, v T, ttl time.Duration) error {
+	data, err := t.codec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode %s: %v", key, err)
+	}
+	return t.group.SetWithTTL(ctx, key, data, ttl)
+}
+
+// Delete 删除key，同时丢弃本地解码后的值
+func (t *TypedGroup[T]) Delete(ctx context.Context, key string) (bool, error) {
+	if t.decoded != nil {
+		t.decoded.remove(key)
+	}
+	return t.group.Delete(ctx, key)
+}
+
+// decodedCache 按条目数淘汰的解码结果缓存，cas为解码时条目的版本号
+type decodedCache[T any] struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type decodedEntry[T any] struct {
+	key   string
+	cas   uint64
+	value T
+}
+
+func newDecodedCache[T any](size int) *decodedCache[T] {
+	return &decodedCache[T]{size: size, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+// get 返回版本号为cas的解码结果，版本号不同说明条目已被修改
+func (c *decodedCache[T]) get(key string, cas uint64) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		if entry := e.Value.(*decodedEntry[T]); entry.cas == cas {
+			c.ll.MoveToFront(e)
+			return entry.value, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+func (c *decodedCache[T]) add(key string, cas uint64, v T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		entry := e.Value.(*decodedEntry[T])
+		entry.cas, entry.value = cas, v
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&decodedEntry[T]{key: key, cas: cas, value: v})
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*decodedEntry[T]).key)
+	}
+}
+
+func (c *decodedCache[T]) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.ll.Remove(e)
+		delete(c.items, key)
+	}
+}
@@ -0,0 +1,85 @@
+package gocache
+
+import (
+	"context"
+	pb "gocache/pb"
+	"sync/atomic"
+	"testing"
+)
+
+type profile struct {
+	Name string
+	Tags []string
+}
+
+// countingCodec 统计Unmarshal调用次数
+type countingCodec[T any] struct {
+	Codec[T]
+	unmarshals atomic.Int64
+}
+
+func (c *countingCodec[T]) Unmarshal(data []byte) (T, error) {
+	c.unmarshals.Add(1)
+	return c.Codec.Unmarshal(data)
+}
+
+func TestTypedGroup_Codecs(t *testing.T) {
+	g := NewGroup("typed", 1<<20, missGetter())
+	t.Cleanup(func() { DestroyGroup("typed") })
+	ctx := context.Background()
+	want := profile{Name: "alice", Tags: []string{"a", "b"}}
+
+	for name, codec := range map[string]Codec[profile]{"json": JSONCodec[profile]{}, "gob": GobCodec[profile]{}} {
+		tg := NewTypedGroup(g, codec)
+		if err := tg.Set(ctx, name, want); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := tg.Get(ctx, name); err != nil || got.Name != want.Name || len(got.Tags) != 2 {
+			t.Fatalf("%s: unexpected value %+v %v", name, got, err)
+		}
+	}
+
+	pg := NewTypedGroup(g, ProtoCodec[*pb.Request]{})
+	pg.Set(ctx, "proto", &pb.Request{Group: "g", Key: "k", TtlMs: 5})
+	if got, err := pg.Get(ctx, "proto"); err != nil || got.GetKey() != "k" || got.GetTtlMs() != 5 {
+		t.Fatalf("proto: unexpected value %v %v", got, err)
+	}
+
+	g.Set(ctx, "garbage", []byte("{"))
+	if _, err := NewTypedGroup(g, JSONCodec[profile]{}).Get(ctx, "garbage"); err == nil {
+		t.Fatal("expect decode error")
+	}
+}
+
+func TestTypedGroup_DecodedCache(t *testing.T) {
+	g := NewGroup("typed-decoded", 1<<20, missGetter())
+	t.Cleanup(func() { DestroyGroup("typed-decoded") })
+	ctx := context.Background()
+	codec := &countingCodec[profile]{Codec: JSONCodec[profile]{}}
+	tg := NewTypedGroup[profile](g, codec, WithDecodedCache(1))
+
+	tg.Set(ctx, "k", profile{Name: "v1"})
+	tg.Get(ctx, "k")
+	tg.Get(ctx, "k")
+	if n := codec.unmarshals.Load(); n != 1 {
+		t.Fatalf("expect decoded value reused, got %d unmarshals", n)
+	}
+
+	// 通过Group写入的新值也能被发现
+	g.Set(ctx, "k", []byte(`{"Name":"v2"}`))
+	if got, _ := tg.Get(ctx, "k"); got.Name != "v2" || codec.unmarshals.Load() != 2 {
+		t.Fatalf("expect changed entry decoded again, got %+v", got)
+	}
+
+	tg.Set(ctx, "other", profile{Name: "o"})
+	tg.Get(ctx, "other")
+	tg.Get(ctx, "k")
+	if n := codec.unmarshals.Load(); n != 4 {
+		t.Fatalf("expect oldest decoded value evicted, got %d unmarshals", n)
+	}
+
+	tg.Delete(ctx, "k")
+	if _, err := tg.Get(ctx, "k"); err == nil {
+		t.Fatal("expect miss after delete")
+	}
+}